@@ -29,6 +29,7 @@ import (
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
 	"github.com/codeready-toolchain/toolchain-common/pkg/status"
+	oauthv1 "github.com/openshift/api/oauth/v1"
 	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -179,7 +180,8 @@ func main() {
 		LeaderElectionID:       "2fc71baf.toolchain.member.operator",
 		// disable caching of Node metrics in the client to avoid getting the following error every second
 		// "failed to watch *v1beta1.NodeMetrics: the server does not allow this method on the requested resource (get nodes.metrics.k8s.io)"
-		Client: client.Options{Cache: &client.CacheOptions{DisableFor: []client.Object{&kmetrics.NodeMetrics{}}}},
+		// also disable caching of the OAuth tokens, which are only listed when a UserAccount is disabled or deleted
+		Client: client.Options{Cache: &client.CacheOptions{DisableFor: []client.Object{&kmetrics.NodeMetrics{}, &oauthv1.OAuthAccessToken{}, &oauthv1.OAuthAuthorizeToken{}}}},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -222,6 +224,7 @@ func main() {
 	}
 
 	hostClientInitializer := host.NewCachedHostClientInitializer(scheme, cluster.GetHostCluster)
+	hostClientInitializer.OnTemplateChange(nstemplateset.InvalidateTierTemplateRenderCache)
 
 	// Setup all Controllers
 	if err = (&toolchainclusterresources.Reconciler{
@@ -280,13 +283,15 @@ func main() {
 		AllNamespacesClient:  allNamespacesCluster.GetClient(),
 		Scheme:               mgr.GetScheme(),
 		GetHostClusterClient: hostClientInitializer.GetHostClient,
+		Recorder:             mgr.GetEventRecorderFor("nstemplateset-controller"),
 	})).SetupWithManager(mgr, allNamespacesCluster, discoveryClient); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NSTemplateSet")
 		os.Exit(1)
 	}
 	if err = (&useraccount.Reconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "UserAccount")
 		os.Exit(1)
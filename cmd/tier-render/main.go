@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/codeready-toolchain/member-operator/controllers/nstemplateset"
+	"github.com/codeready-toolchain/member-operator/pkg/apis"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/yaml"
+)
+
+// tier-render renders a TierTemplate or a TierTemplateRevision the way the member operator does when provisioning a space,
+// prints the rendered objects and reports the problems found in the template.
+//
+// Usage: tier-render -f <file> [-space-name <name>] [-username <name>] [-namespace <name>] [-features <features>] [-filter <filter>]
+func main() {
+	file := flag.String("f", "", "the YAML file of the TierTemplate or TierTemplateRevision to render")
+	opts := nstemplateset.RenderOptions{}
+	flag.StringVar(&opts.SpaceName, "space-name", "", "the value of the SPACE_NAME parameter")
+	flag.StringVar(&opts.Username, "username", "", "the value of the USERNAME parameter")
+	flag.StringVar(&opts.Namespace, "namespace", "", "the value of the NAMESPACE parameter")
+	features := flag.String("features", "", "the comma-separated features enabled on the NSTemplateSet, possibly with parameters (eg, 'quota-boost;cpu=4')")
+	flag.StringVar(&opts.Filter, "filter", "", "the filter applied on the objects of the template: 'namespaces' or 'all-but-namespaces' (default: all the objects)")
+	memberOperatorNS := flag.String("member-operator-namespace", "toolchain-member-operator", "the value of the MEMBER_OPERATOR_NAMESPACE parameter")
+	flag.Parse()
+	opts.Features = utils.SplitCommaSeparatedList(*features)
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "missing template file (-f)")
+		flag.Usage()
+		os.Exit(2)
+	}
+	// the MEMBER_OPERATOR_NAMESPACE parameter is set from the namespace watched by the operator
+	if err := os.Setenv(commonconfig.WatchNamespaceEnvVar, *memberOperatorNS); err != nil {
+		exitOnError(err, "unable to set the member operator namespace")
+	}
+
+	scheme := runtime.NewScheme()
+	if err := apis.AddToScheme(scheme); err != nil {
+		exitOnError(err, "adding apis to scheme failed")
+	}
+	content, err := os.ReadFile(*file)
+	if err != nil {
+		exitOnError(err, "unable to read the template file")
+	}
+	tmpl, _, err := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode(content, nil, nil)
+	if err != nil {
+		exitOnError(err, "unable to decode the template file")
+	}
+
+	objs, problems, err := nstemplateset.RenderTierTemplate(scheme, tmpl, opts)
+	if err != nil {
+		printProblems(problems)
+		exitOnError(err, "unable to render the template")
+	}
+	for _, obj := range objs {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			exitOnError(err, "unable to print the rendered object")
+		}
+		fmt.Printf("---\n%s", out)
+	}
+	// the informational problems (eg, the unknown kinds of the optional resources) don't fail the command
+	if printProblems(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printProblems prints the given problems and returns the number of problems which are not informational
+func printProblems(problems []nstemplateset.RenderProblem) int {
+	count := 0
+	for _, problem := range problems {
+		if problem.Informational {
+			fmt.Fprintf(os.Stderr, "info: %s\n", problem.Message)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "problem: %s\n", problem.Message)
+		count++
+	}
+	return count
+}
+
+func exitOnError(err error, msg string) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", msg, err)
+	os.Exit(1)
+}
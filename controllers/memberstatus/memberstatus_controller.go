@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/constants"
+	"github.com/codeready-toolchain/member-operator/pkg/tierrollout"
 	"github.com/codeready-toolchain/member-operator/version"
 	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
@@ -35,7 +37,7 @@ import (
 type statusComponentTag string
 
 const (
-	MemberStatusName = "toolchain-member-status"
+	MemberStatusName = constants.MemberStatusName
 
 	memberOperatorTag statusComponentTag = "memberOperator"
 	hostConnectionTag statusComponentTag = "hostConnection"
@@ -122,6 +124,7 @@ func (r *Reconciler) aggregateAndUpdateStatus(ctx context.Context, memberStatus
 		{name: hostConnectionTag, handleStatus: r.hostConnectionHandleStatus},
 		{name: resourceUsageTag, handleStatus: r.loadCurrentResourceUsage},
 		{name: routesTag, handleStatus: r.routesHandleStatus},
+		{name: tierRolloutTag, handleStatus: r.tierRolloutHandleStatus},
 	}
 
 	// Track components that are not ready
@@ -347,6 +350,12 @@ func (r *Reconciler) updateStatusConditions(ctx context.Context, memberStatus *t
 		condition.LastUpdatedTime = &metav1.Time{Time: condition.LastTransitionTime.Time}
 		conditionsWithTimestamps = append(conditionsWithTimestamps, condition)
 	}
+	// keep the informational conditions set by the status handlers (eg, the halt of the tier rollout)
+	for _, condition := range memberStatus.Status.Conditions {
+		if condition.Type == tierrollout.HaltedConditionType {
+			conditionsWithTimestamps = append(conditionsWithTimestamps, condition)
+		}
+	}
 	memberStatus.Status.Conditions = conditionsWithTimestamps
 	return r.Client.Status().Update(ctx, memberStatus)
 }
@@ -9,6 +9,7 @@ import (
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/member-operator/pkg/apis"
+	"github.com/codeready-toolchain/member-operator/pkg/tierrollout"
 	. "github.com/codeready-toolchain/member-operator/test"
 	"github.com/codeready-toolchain/member-operator/version"
 	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
@@ -508,6 +509,46 @@ func TestOverallStatusCondition(t *testing.T) {
 				HasRoutes("", consoleRouteUnavailable("routes.route.openshift.io \"console\" not found"))
 		})
 
+		t.Run("tier rollout halted", func(t *testing.T) {
+			// given
+			memberStatus := newMemberStatus()
+			memberStatus.Annotations = map[string]string{
+				tierrollout.HaltedAnnotationKey: `{"failedSpaces":3,"totalSpaces":10,"haltTime":"2024-01-02T03:04:05Z"}`,
+			}
+			reconciler, req, fakeClient := prepareReconcile(t, requestName, getHostClusterFunc, allNamespacesCl, mockLastGitHubAPICall, defaultGitHubClient, append(nodeAndMetrics, memberOperatorDeployment, memberStatus)...)
+
+			// when
+			res, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, requeueResult, res)
+			AssertThatMemberStatus(t, req.Namespace, requestName, fakeClient).
+				HasConditions(ComponentsReady(), toolchainv1alpha1.Condition{
+					Type:   tierrollout.HaltedConditionType,
+					Status: corev1.ConditionTrue,
+					Reason: tierrollout.HaltedReason,
+					Message: "the update of 3 out of 10 spaces failed (it is resumed once the failed updates are fixed, " +
+						"or when the 'toolchain.dev.openshift.com/tier-rollout-halted' annotation is removed)",
+				})
+
+			t.Run("condition removed once the rollout is resumed", func(t *testing.T) {
+				// given
+				memberStatus := &toolchainv1alpha1.MemberStatus{}
+				require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, memberStatus))
+				delete(memberStatus.Annotations, tierrollout.HaltedAnnotationKey)
+				require.NoError(t, fakeClient.Update(context.TODO(), memberStatus))
+
+				// when
+				_, err := reconciler.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				AssertThatMemberStatus(t, req.Namespace, requestName, fakeClient).
+					HasConditions(ComponentsReady())
+			})
+		})
+
 	})
 
 	t.Run("member operator deployment revision check", func(t *testing.T) {
@@ -0,0 +1,40 @@
+package memberstatus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/tierrollout"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const tierRolloutTag statusComponentTag = "tierRollout"
+
+// tierRolloutHandleStatus sets the TierRolloutHalted condition on the MemberStatus while the tier rollout on the member cluster is halted,
+// and removes it once the rollout is resumed. A halted rollout does not make the MemberStatus not ready, since the spaces which are
+// already provisioned on the member cluster are not affected, and new spaces can still be placed on it.
+func (r *Reconciler) tierRolloutHandleStatus(_ context.Context, memberStatus *toolchainv1alpha1.MemberStatus, _ membercfg.Configuration) error {
+	halt, halted, err := tierrollout.GetHalt(memberStatus)
+	if !halted {
+		memberStatus.Status.Conditions = slices.DeleteFunc(memberStatus.Status.Conditions, func(c toolchainv1alpha1.Condition) bool {
+			return c.Type == tierrollout.HaltedConditionType
+		})
+		return nil
+	}
+	message := fmt.Sprintf("the update of %d out of %d spaces failed (it is resumed once the failed updates are fixed, or when the '%s' annotation is removed)",
+		halt.FailedSpaces, halt.TotalSpaces, tierrollout.HaltedAnnotationKey)
+	if err != nil {
+		message = err.Error()
+	}
+	memberStatus.Status.Conditions, _ = condition.AddOrUpdateStatusConditions(memberStatus.Status.Conditions, toolchainv1alpha1.Condition{
+		Type:    tierrollout.HaltedConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  tierrollout.HaltedReason,
+		Message: message,
+	})
+	return nil
+}
@@ -0,0 +1,67 @@
+package memberstatus
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/tierrollout"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTierRolloutHandleStatus(t *testing.T) {
+	// given
+	r := &Reconciler{}
+
+	t.Run("not halted", func(t *testing.T) {
+		// given
+		memberStatus := newMemberStatus()
+		memberStatus.Status.Conditions = []toolchainv1alpha1.Condition{{Type: tierrollout.HaltedConditionType, Status: corev1.ConditionTrue}}
+
+		// when
+		err := r.tierRolloutHandleStatus(context.TODO(), memberStatus, membercfg.Configuration{})
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, memberStatus.Status.Conditions)
+	})
+
+	t.Run("halted", func(t *testing.T) {
+		// given
+		memberStatus := newMemberStatus()
+		memberStatus.Annotations = map[string]string{
+			tierrollout.HaltedAnnotationKey: `{"failedSpaces":3,"totalSpaces":10,"haltTime":"2024-01-02T03:04:05Z"}`,
+		}
+
+		// when
+		err := r.tierRolloutHandleStatus(context.TODO(), memberStatus, membercfg.Configuration{})
+
+		// then
+		require.NoError(t, err) // the MemberStatus is still ready
+		halted, found := condition.FindConditionByType(memberStatus.Status.Conditions, tierrollout.HaltedConditionType)
+		require.True(t, found)
+		assert.Equal(t, corev1.ConditionTrue, halted.Status)
+		assert.Equal(t, tierrollout.HaltedReason, halted.Reason)
+		assert.Equal(t, "the update of 3 out of 10 spaces failed (it is resumed once the failed updates are fixed, "+
+			"or when the 'toolchain.dev.openshift.com/tier-rollout-halted' annotation is removed)", halted.Message)
+	})
+
+	t.Run("halted with invalid details", func(t *testing.T) {
+		// given
+		memberStatus := newMemberStatus()
+		memberStatus.Annotations = map[string]string{tierrollout.HaltedAnnotationKey: "true"}
+
+		// when
+		err := r.tierRolloutHandleStatus(context.TODO(), memberStatus, membercfg.Configuration{})
+
+		// then
+		require.NoError(t, err)
+		halted, found := condition.FindConditionByType(memberStatus.Status.Conditions, tierrollout.HaltedConditionType)
+		require.True(t, found)
+		assert.Contains(t, halted.Message, "invalid value of the")
+	})
+}
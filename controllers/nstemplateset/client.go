@@ -7,10 +7,12 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/member-operator/pkg/host"
 	applycl "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	errs "github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -21,11 +23,18 @@ type APIClient struct {
 	Scheme               *runtime.Scheme
 	GetHostClusterClient host.ClientGetter
 	AvailableAPIGroups   []metav1.APIGroup
+	Recorder             record.EventRecorder
 }
 
-// ApplyToolchainObjects applies the given ToolchainObjects with the given labels.
+// ApplyToolchainObjects applies the given ToolchainObjects with the given labels, in the given order (the callers apply the objects wave by wave).
 // If any object is marked as optional, then it checks if the API group is available - if not, then it skips the object.
+// If the update of an object fails because an immutable field changed and the object is recreatable, then the object is deleted and re-created
+// (or a *recreatePendingError is returned if the deleted object is still present in the cluster).
 func (c APIClient) ApplyToolchainObjects(ctx context.Context, toolchainObjects []runtimeclient.Object, newLabels map[string]string) (bool, error) {
+	return c.applyToolchainObjects(ctx, toolchainObjects, newLabels, nil)
+}
+
+func (c APIClient) applyToolchainObjects(ctx context.Context, toolchainObjects []runtimeclient.Object, newLabels map[string]string, onRecreated recreatedFunc) (bool, error) {
 	applyClient := applycl.NewApplyClient(c.Client)
 	anyApplied := false
 	logger := log.FromContext(ctx)
@@ -45,13 +54,13 @@ func (c APIClient) ApplyToolchainObjects(ctx context.Context, toolchainObjects [
 			sa := object.DeepCopyObject().(runtimeclient.Object)
 			err := applyClient.Get(ctx, runtimeclient.ObjectKeyFromObject(object), sa)
 			if err != nil && !errors.IsNotFound(err) {
-				return anyApplied, err
+				return anyApplied, newObjectError(applyOperation, object, err)
 			}
 			if err != nil {
 				logger.Info("the ServiceAccount does not exists - creating...")
 				applycl.MergeLabels(object, newLabels)
 				if err := applyClient.Create(ctx, object); err != nil {
-					return anyApplied, err
+					return anyApplied, newObjectError(applyOperation, object, err)
 				}
 			} else {
 				logger.Info("the ServiceAccount already exists - updating labels and annotations...")
@@ -60,7 +69,7 @@ func (c APIClient) ApplyToolchainObjects(ctx context.Context, toolchainObjects [
 				applycl.MergeAnnotations(sa, object.GetAnnotations()) // add new annotations from template
 				err = applyClient.Update(ctx, sa)
 				if err != nil {
-					return anyApplied, err
+					return anyApplied, newObjectError(applyOperation, object, err)
 				}
 			}
 			anyApplied = true
@@ -69,7 +78,19 @@ func (c APIClient) ApplyToolchainObjects(ctx context.Context, toolchainObjects [
 		logger.Info("applying object", "object_namespace", object.GetNamespace(), "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName())
 		_, err := applyClient.Apply(ctx, []runtimeclient.Object{object}, newLabels)
 		if err != nil {
-			return anyApplied, err
+			if !isImmutableFieldError(err) || !isRecreatable(object) {
+				return anyApplied, newObjectError(applyOperation, object, err)
+			}
+			cause := errs.Cause(err)
+			deleted, err := c.recreate(ctx, applyClient, object, newLabels, cause)
+			if deleted && onRecreated != nil {
+				if err := onRecreated(object, cause); err != nil {
+					return anyApplied, err
+				}
+			}
+			if err != nil {
+				return anyApplied, newObjectError(applyOperation, object, err)
+			}
 		}
 		anyApplied = true
 	}
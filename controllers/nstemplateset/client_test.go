@@ -17,6 +17,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -330,6 +331,8 @@ func newOptionalDeployment(name, owner string) *appsv1.Deployment {
 }
 
 func prepareAPIClient(t *testing.T, initObjs ...runtimeclient.Object) (*APIClient, *test.FakeClient) {
+	// the fake client sets the same resource version on all the initial objects, so the rendered templates must not be shared between the tests
+	tierTemplateRenderCache.purge()
 	s := scheme.Scheme
 	err := apis.AddToScheme(s)
 	require.NoError(t, err)
@@ -374,6 +377,7 @@ func prepareAPIClient(t *testing.T, initObjs ...runtimeclient.Object) (*APIClien
 			newAPIGroup("rbac.authorization.k8s.io", "v1"),
 			newAPIGroup("toolchain.dev.openshift.com", "v1alpha1"),
 			newAPIGroup("", "v1")),
+		Recorder: record.NewFakeRecorder(100),
 	}, fakeClient
 }
 
@@ -22,10 +22,11 @@ type clusterResourcesManager struct {
 // ensure ensures that the cluster resources exist.
 // Returns `true, nil` if something was changed, `false, nil` if nothing changed, `false, err` if an error occurred
 //
-// NOTE: This method makes 1 important assumption: all the cluster-scoped resources of the NSTemplateSet are uniquely
-// associated with it. I.e. the logic doesn't work correctly if 2 NSTemplateSets declare the very same cluster-scoped
-// resource. It is assumed that if a cluster-scoped resource should be common to more than 1 nstemplateset, it should
-// be deployed externally from Devsandbox, e.g. using ArgoCD.
+// NOTE: By default, all the cluster-scoped resources of the NSTemplateSet are assumed to be uniquely associated with it.
+// A cluster-scoped resource that should be common to more than 1 NSTemplateSet must be annotated with
+// `toolchain.dev.openshift.com/shared: "true"` in the template. Such a resource is applied once and the spaces referencing
+// it are tracked, along with the templateRef they applied, in its `toolchain.dev.openshift.com/shared-by` annotation.
+// It is deleted only when the last referencing NSTemplateSet releases it.
 func (r *clusterResourcesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
 	logger := log.FromContext(ctx, "spacename", nsTmplSet.GetName(), "tier", nsTmplSet.Spec.TierName)
 	logger.Info("ensuring cluster resources")
@@ -36,13 +37,14 @@ func (r *clusterResourcesManager) ensure(ctx context.Context, nsTmplSet *toolcha
 		return nil
 	}
 
-	newTierTemplate, newObjs, err := r.processTierTemplate(ctx, nsTmplSet.Spec.ClusterResources, nsTmplSet.Name)
+	_, features := featureAnnotationNeedsUpdate(nsTmplSet)
+	newTierTemplate, newObjs, err := r.processTierTemplate(ctx, nsTmplSet.Spec.ClusterResources, nsTmplSet.Name, features)
 	if err != nil {
 		return r.wrapErrorWithStatusUpdateForClusterResourceFailure(ctx, nsTmplSet, err,
 			"failed to process the template for the to-be-applied cluster resources with the name '%s'", newTemplateRef)
 	}
 
-	_, curObjs, err := r.processTierTemplate(ctx, nsTmplSet.Status.ClusterResources, nsTmplSet.Name)
+	_, curObjs, err := r.processTierTemplate(ctx, nsTmplSet.Status.ClusterResources, nsTmplSet.Name, nsTmplSet.Status.FeatureToggles)
 	if err != nil {
 		return r.wrapErrorWithStatusUpdateForClusterResourceFailure(ctx, nsTmplSet, err,
 			"failed to process the template for the last-applied cluster resources with the name '%s'", oldTemplateRef)
@@ -50,11 +52,25 @@ func (r *clusterResourcesManager) ensure(ctx context.Context, nsTmplSet *toolcha
 
 	objectApplier := newObjectApplier(r, nsTmplSet, curObjs, newTierTemplate)
 
-	for _, newObj := range newObjs {
-		if err = objectApplier.Apply(ctx, newObj); err != nil {
-			return err
+	waves, err := groupByWave(newObjs)
+	if err != nil {
+		return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, objectApplier.failureStatusReason, err, "failure while syncing cluster resources")
+	}
+	// apply the objects wave by wave, and wait for the objects which require it to be ready before moving to the next wave
+	for _, wave := range waves {
+		for _, newObj := range wave.objects {
+			if err = objectApplier.Apply(ctx, newObj); err != nil {
+				return err
+			}
+		}
+		if err := checkReadiness(ctx, r.Client, wave); err != nil {
+			return r.wrapErrorWithPendingStatusUpdate(ctx, nsTmplSet, err, objectApplier.failureStatusReason, "failure while syncing cluster resources")
 		}
 	}
+	// all waves were applied, clear the message about the pending wave (if any)
+	if err := r.clearWavePendingStatus(ctx, nsTmplSet); err != nil {
+		return err
+	}
 
 	return objectApplier.Cleanup(ctx)
 }
@@ -72,7 +88,8 @@ func getOldAndNewTemplateRefsIfChanged(nstt *toolchainv1alpha1.NSTemplateSet) (o
 	return oldTemplateRef, newTemplateRef, changed
 }
 
-func (r *clusterResourcesManager) processTierTemplate(ctx context.Context, clusterResources *toolchainv1alpha1.NSTemplateSetClusterResources, spacename string) (*tierTemplate, []runtimeclient.Object, error) {
+// processTierTemplate processes the template of the given cluster resources, with the parameters of the given features (see featureParams)
+func (r *clusterResourcesManager) processTierTemplate(ctx context.Context, clusterResources *toolchainv1alpha1.NSTemplateSetClusterResources, spacename string, features []string) (*tierTemplate, []runtimeclient.Object, error) {
 	if clusterResources == nil {
 		return nil, nil, nil
 	}
@@ -85,7 +102,9 @@ func (r *clusterResourcesManager) processTierTemplate(ctx context.Context, clust
 		if err != nil {
 			return nil, nil, err
 		}
-		objs, err = tierTemplate.process(r.Scheme, map[string]string{SpaceName: spacename})
+		params := featureParams(features)
+		params[SpaceName] = spacename
+		objs, err = tierTemplate.process(r.Scheme, params)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -110,7 +129,7 @@ func (r *clusterResourcesManager) apply(ctx context.Context, nsTmplSet *toolchai
 	// see https://issues.redhat.com/browse/CRT-429
 
 	log.FromContext(ctx).Info("applying cluster resource", "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName())
-	createdOrModified, err := r.ApplyToolchainObjects(ctx, []runtimeclient.Object{object}, labels)
+	createdOrModified, err := r.applyTemplateObjects(ctx, nsTmplSet, []runtimeclient.Object{object}, labels)
 	if err != nil {
 		return false, errs.Wrapf(err, "failed to apply cluster resource")
 	}
@@ -123,12 +142,20 @@ func (r *clusterResourcesManager) delete(ctx context.Context, nsTmplSet *toolcha
 		return nil
 	}
 
-	_, currentObjects, err := r.processTierTemplate(ctx, nsTmplSet.Status.ClusterResources, nsTmplSet.Name)
+	_, currentObjects, err := r.processTierTemplate(ctx, nsTmplSet.Status.ClusterResources, nsTmplSet.Name, nsTmplSet.Status.FeatureToggles)
 	if err != nil {
 		return r.wrapErrorWithStatusUpdateForClusterResourceFailure(ctx, nsTmplSet, err,
 			"failed to process the existing cluster resources")
 	}
 
+	sharedObjects, currentObjects := splitShared(currentObjects)
+	for _, toRelease := range sharedObjects {
+		if err := r.releaseShared(ctx, nsTmplSet, toRelease); err != nil {
+			return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusTerminatingFailed, err,
+				"failed to release the shared cluster resource '%s' (GVK '%s') while deleting cluster resources", toRelease.GetName(), toRelease.GetObjectKind().GroupVersionKind())
+		}
+	}
+
 	for _, toDelete := range currentObjects {
 		var err error
 		if err = r.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(toDelete), toDelete); err != nil && !errors.IsNotFound(err) {
@@ -146,7 +173,7 @@ func (r *clusterResourcesManager) delete(ctx context.Context, nsTmplSet *toolcha
 			continue
 		} else if err != nil {
 			// report an error only if the resource could not be deleted (but ignore if the resource did not exist anymore)
-			return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusTerminatingFailed, err, "failed to delete cluster resource '%s'", toDelete.GetName())
+			return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusTerminatingFailed, newObjectError(deleteOperation, toDelete, err), "failed to delete cluster resource '%s'", toDelete.GetName())
 		}
 	}
 	return nil
@@ -225,9 +252,16 @@ func (oa *objectApplier) Apply(ctx context.Context, obj runtimeclient.Object) er
 	}
 
 	// create or update the resource
+	if isShared(obj) {
+		if err := oa.r.applyShared(ctx, oa.nstt, oa.newTierTemplate, obj); err != nil {
+			err := fmt.Errorf("failed to apply changes to the shared cluster resource %s, %s: %w", obj.GetName(), obj.GetObjectKind().GroupVersionKind().String(), err)
+			return oa.r.wrapErrorWithStatusUpdate(ctx, oa.nstt, oa.failureStatusReason, err, "failure while syncing cluster resources")
+		}
+		return nil
+	}
 	if _, err := oa.r.apply(ctx, oa.nstt, oa.newTierTemplate, obj); err != nil {
 		err := fmt.Errorf("failed to apply changes to the cluster resource %s, %s: %w", obj.GetName(), obj.GetObjectKind().GroupVersionKind().String(), err)
-		return oa.r.wrapErrorWithStatusUpdate(ctx, oa.nstt, oa.failureStatusReason, err, "failure while syncing cluster resources")
+		return oa.r.wrapErrorWithPendingStatusUpdate(ctx, oa.nstt, err, oa.failureStatusReason, "failure while syncing cluster resources")
 	}
 
 	return nil
@@ -243,8 +277,14 @@ func (oa *objectApplier) Cleanup(ctx context.Context) error {
 	}
 
 	// what we're left with here is the list of currently existing objects that are no longer present in the template.
-	// we need to delete them
-	if err := deleteObsoleteObjects(ctx, oa.r.Client, oa.currentObjects, nil); err != nil {
+	// we need to delete them, or only release them if they are shared with other spaces
+	sharedObjects, notSharedObjects := splitShared(oa.currentObjects)
+	for _, obj := range sharedObjects {
+		if err := oa.r.releaseShared(ctx, oa.nstt, obj); err != nil {
+			return oa.r.wrapErrorWithStatusUpdate(ctx, oa.nstt, oa.failureStatusReason, err, "failure while syncing cluster resources")
+		}
+	}
+	if err := deleteObsoleteObjects(ctx, oa.r.Client, notSharedObjects, nil); err != nil {
 		return oa.r.wrapErrorWithStatusUpdate(ctx, oa.nstt, oa.failureStatusReason, err, "failure while syncing cluster resources")
 	}
 
@@ -843,3 +843,45 @@ func TestDeleteFeatureFromNSTemplateSet(t *testing.T) {
 	AssertThatCluster(t, cl).
 		HasNoResource(crqFeatured.Name, &quotav1.ClusterResourceQuota{}) // The featured object is now deleted because the feature was disabled in the NSTemplateSet
 }
+
+func TestEnsureClusterResourcesWithFeatureParameters(t *testing.T) {
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+
+	// given
+	ctx := context.TODO()
+	spaceName := "johnsmith"
+	namespaceName := "toolchain-member"
+	nsTmplSet := newNSTmplSet(namespaceName, spaceName, "boost",
+		withClusterResources("abcde11"),
+		withNSTemplateSetFeatureAnnotation("feature=quota-boost;BOOST_CPU=4"))
+	manager, cl := prepareClusterResourcesManager(t, nsTmplSet)
+
+	// when
+	err := manager.ensure(ctx, nsTmplSet)
+
+	// then
+	require.NoError(t, err)
+	crq := &quotav1.ClusterResourceQuota{}
+	AssertThatCluster(t, cl).
+		HasResource("for-"+spaceName, &quotav1.ClusterResourceQuota{}).
+		HasResource("quota-boost-for-"+spaceName, crq)
+	assert.Equal(t, resource.MustParse("4"), crq.Spec.Quota.Hard["limits.cpu"])
+
+	t.Run("parameter changed", func(t *testing.T) {
+		// given
+		require.NoError(t, manager.updateStatusClusterResourcesRevisions(ctx, nsTmplSet))
+		assert.Equal(t, []string{"quota-boost;BOOST_CPU=4"}, nsTmplSet.Status.FeatureToggles)
+		nsTmplSet.Annotations[toolchainv1alpha1.FeatureToggleNameAnnotationKey] = "quota-boost;BOOST_CPU=8"
+
+		// when
+		err := manager.ensure(ctx, nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		crq := &quotav1.ClusterResourceQuota{}
+		AssertThatCluster(t, cl).
+			HasResource("quota-boost-for-"+spaceName, crq)
+		assert.Equal(t, resource.MustParse("8"), crq.Spec.Quota.Hard["limits.cpu"])
+	})
+}
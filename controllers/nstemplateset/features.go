@@ -1,12 +1,75 @@
 package nstemplateset
 
 import (
+	"maps"
+	"slices"
+	"strings"
+
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/utils"
-	"k8s.io/utils/strings/slices"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// featureToggle is an entry of the feature annotation of the NSTemplateSet. Besides its name, a feature can carry parameters
+// which are passed to the templates, eg: `quota-boost;cpu=4` (or `feature=quota-boost;cpu=4`)
+type featureToggle struct {
+	name   string
+	params map[string]string
+}
+
+// parseFeatureToggle parses the given entry of the feature annotation. The segments which are not in the `key=value` form are ignored.
+func parseFeatureToggle(entry string) featureToggle {
+	segments := strings.Split(entry, ";")
+	name := strings.TrimSpace(segments[0])
+	name = strings.TrimSpace(strings.TrimPrefix(name, "feature="))
+	toggle := featureToggle{name: name}
+	for _, segment := range segments[1:] {
+		key, value, found := strings.Cut(segment, "=")
+		if key = strings.TrimSpace(key); !found || key == "" {
+			continue
+		}
+		if toggle.params == nil {
+			toggle.params = map[string]string{}
+		}
+		toggle.params[key] = strings.TrimSpace(value)
+	}
+	return toggle
+}
+
+// String returns the canonical form of the feature toggle, ie, its name followed by its parameters sorted by key
+func (f featureToggle) String() string {
+	entry := f.name
+	for _, key := range slices.Sorted(maps.Keys(f.params)) {
+		entry += ";" + key + "=" + f.params[key]
+	}
+	return entry
+}
+
+// parseFeatureToggles parses the given entries of the feature annotation
+func parseFeatureToggles(entries []string) []featureToggle {
+	toggles := make([]featureToggle, 0, len(entries))
+	for _, entry := range entries {
+		if toggle := parseFeatureToggle(entry); toggle.name != "" {
+			toggles = append(toggles, toggle)
+		}
+	}
+	return toggles
+}
+
+// featureParams returns the parameters of all the given feature toggles, to be passed to the templates.
+// If several features have a parameter with the same key, then the value of the last feature (in alphabetical order) wins.
+func featureParams(entries []string) map[string]string {
+	toggles := parseFeatureToggles(entries)
+	slices.SortFunc(toggles, func(a, b featureToggle) int {
+		return strings.Compare(a.name, b.name)
+	})
+	params := map[string]string{}
+	for _, toggle := range toggles {
+		maps.Copy(params, toggle.params)
+	}
+	return params
+}
+
 // shouldCreate checks if the object has a feature toggle annotation. If it does then check if the corresponding
 // feature is referenced in the NSTemplateSet feature annotation. Returns true if yes. It means this feature
 // should be enabled and the object should be created. It also returns true if the object doesn't have a feature annotation at all
@@ -22,10 +85,12 @@ func shouldCreate(toCreate runtimeclient.Object, nsTmplSet *toolchainv1alpha1.NS
 	if !found {
 		return false // No feature winners in the NSTemplateSet at all. Skip this object.
 	}
-	return slices.Contains(utils.SplitCommaSeparatedList(winners), feature)
+	return slices.ContainsFunc(parseFeatureToggles(utils.SplitCommaSeparatedList(winners)), func(toggle featureToggle) bool {
+		return toggle.name == feature
+	})
 }
 
-// featuresChanged returns true if the features on the NSTemplateSet changed since the last time it was applied.
+// featuresChanged returns true if the features (or their parameters) on the NSTemplateSet changed since the last time it was applied.
 func featuresChanged(nsTmplSet *toolchainv1alpha1.NSTemplateSet) bool {
 	changed, _ := featureAnnotationNeedsUpdate(nsTmplSet)
 	return changed
@@ -71,6 +71,18 @@ func TestShouldCreate(t *testing.T) {
 			nsTemplateSetFeatures: p("feature-2"),
 			expectedToBeCreated:   false,
 		},
+		{
+			name:                  "object with a feature which is among enabled features with parameters should be created",
+			objFeature:            p("feature-2"),
+			nsTemplateSetFeatures: p("feature-1;cpu=2,feature-2;cpu=4"),
+			expectedToBeCreated:   true,
+		},
+		{
+			name:                  "object with a feature which is not among enabled features with parameters should not be created",
+			objFeature:            p("cpu=4"),
+			nsTemplateSetFeatures: p("feature-1;cpu=4"),
+			expectedToBeCreated:   false,
+		},
 		{
 			name:                  "object with a feature which is among enabled features should be created",
 			objFeature:            p("feature-2"),
@@ -170,3 +182,33 @@ func objectWithFeature(feature *string) runtimeclient.Object {
 	}
 	return obj
 }
+
+func TestParseFeatureToggle(t *testing.T) {
+	for entry, expected := range map[string]featureToggle{
+		"feature-1":                         {name: "feature-1"},
+		"feature=quota-boost;cpu=4":         {name: "quota-boost", params: map[string]string{"cpu": "4"}},
+		" quota-boost ; cpu = 4;memory=8Gi": {name: "quota-boost", params: map[string]string{"cpu": "4", "memory": "8Gi"}},
+		"quota-boost;invalid;=4":            {name: "quota-boost"},
+	} {
+		t.Run(entry, func(t *testing.T) {
+			// when
+			toggle := parseFeatureToggle(entry)
+
+			// then
+			assert.Equal(t, expected, toggle)
+		})
+	}
+
+	t.Run("canonical form", func(t *testing.T) {
+		assert.Equal(t, "quota-boost;cpu=4;memory=8Gi", parseFeatureToggle("feature=quota-boost;memory=8Gi;cpu=4").String())
+		assert.Equal(t, "feature-1", parseFeatureToggle("feature-1").String())
+	})
+}
+
+func TestFeatureParams(t *testing.T) {
+	// when
+	params := featureParams([]string{"quota-boost;cpu=4;memory=8Gi", "another;cpu=2", "feature-1"})
+
+	// then
+	assert.Equal(t, map[string]string{"cpu": "4", "memory": "8Gi"}, params)
+}
@@ -0,0 +1,53 @@
+package nstemplateset
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/member-operator/pkg/utils/memberconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/utils"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// memberOperatorConfigDuration returns the duration set in the given annotation on the MemberOperatorConfig,
+// or the default value if the annotation is missing or invalid.
+func memberOperatorConfigDuration(ctx context.Context, key string, defaultValue time.Duration) time.Duration {
+	value := memberconfig.Annotation(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.FromContext(ctx).Info("ignoring invalid duration in MemberOperatorConfig annotation", "annotation", key, "value", value)
+		return defaultValue
+	}
+	return d
+}
+
+// memberOperatorConfigInt returns the positive integer set in the given annotation on the MemberOperatorConfig,
+// or zero if the annotation is missing or invalid.
+func memberOperatorConfigInt(ctx context.Context, key string) int {
+	value := memberconfig.Annotation(key)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		log.FromContext(ctx).Info("ignoring invalid integer in MemberOperatorConfig annotation", "annotation", key, "value", value)
+		return 0
+	}
+	return i
+}
+
+// memberOperatorConfigList returns the comma-separated values set in the given annotation on the MemberOperatorConfig
+func memberOperatorConfigList(key string) []string {
+	var values []string
+	for _, value := range utils.SplitCommaSeparatedList(memberconfig.Annotation(key)) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
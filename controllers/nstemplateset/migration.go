@@ -0,0 +1,224 @@
+package nstemplateset
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MigrationExportAnnotationKey is the annotation set (by the host) on the NSTemplateSet on the source member cluster,
+	// with the ID of the migration bundle in which the user-created objects of all the namespaces of the space are exported.
+	MigrationExportAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "migration-export"
+	// MigrationImportAnnotationKey is the annotation set (by the host) on the NSTemplateSet on the target member cluster,
+	// with the ID of the migration bundle whose objects are imported once the namespaces are provisioned.
+	MigrationImportAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "migration-import"
+	// MigrationStatusAnnotationKey is the annotation set on the NSTemplateSet with the status of the last export or import,
+	// so that the host can orchestrate the migration of the space.
+	MigrationStatusAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "migration-status"
+
+	MigrationExportedPhase     = "Exported"
+	MigrationExportFailedPhase = "ExportFailed"
+	MigrationImportedPhase     = "Imported"
+	MigrationImportFailedPhase = "ImportFailed"
+
+	// maxBundleManifestSize is the maximum size of a manifest in a migration bundle
+	maxBundleManifestSize = 1 << 20
+	// maxBundleEntries is the maximum number of files in a migration bundle
+	maxBundleEntries = 10000
+)
+
+// MigrationStatus is the status of the export or import of a migration bundle
+type MigrationStatus struct {
+	Phase    string      `json:"phase"`
+	Bundle   string      `json:"bundle"`
+	Location string      `json:"location,omitempty"`
+	Objects  int         `json:"objects,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Time     metav1.Time `json:"time"`
+}
+
+// migrate exports or imports the migration bundle requested in the annotations of the NSTemplateSet, if any (and not done yet).
+// The bundles are kept in the storage of the space backups (which must thus be shared by the member clusters, eg, an S3 bucket),
+// and contain the user-created objects of the namespaces of the space, including the values of the Secrets.
+func (r *namespacesManager) migrate(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	status := getAnnotationJSON[MigrationStatus](ctx, nsTmplSet, MigrationStatusAnnotationKey)
+	if bundle, found := nsTmplSet.GetAnnotations()[MigrationExportAnnotationKey]; found &&
+		(status.Bundle != bundle || status.Phase != MigrationExportedPhase) {
+		location, count, err := r.exportBundle(ctx, nsTmplSet, bundle)
+		return r.setMigrationStatus(ctx, nsTmplSet, bundle, MigrationExportedPhase, MigrationExportFailedPhase, location, count, err)
+	}
+	if bundle, found := nsTmplSet.GetAnnotations()[MigrationImportAnnotationKey]; found &&
+		(status.Bundle != bundle || status.Phase != MigrationImportedPhase) {
+		location, count, err := r.importBundle(ctx, nsTmplSet, bundle)
+		return r.setMigrationStatus(ctx, nsTmplSet, bundle, MigrationImportedPhase, MigrationImportFailedPhase, location, count, err)
+	}
+	return nil
+}
+
+func (r *namespacesManager) exportBundle(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, bundle string) (string, int, error) {
+	key, err := bundleKey(nsTmplSet, bundle)
+	if err != nil {
+		return "", 0, err
+	}
+	store, err := r.newBackupStore(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	if store == nil {
+		return "", 0, fmt.Errorf("no storage is configured for the migration bundles (see the '%s' annotation on the MemberOperatorConfig)", SpaceBackupAnnotationKey)
+	}
+	userNamespaces, err := fetchNamespacesByOwner(ctx, r.Client, nsTmplSet.Name)
+	if err != nil {
+		return "", 0, errs.Wrapf(err, "failed to list namespaces with label owner '%s'", nsTmplSet.Name)
+	}
+	archive, count, err := newArchive(func(tarWriter *tar.Writer) (int, error) {
+		total := 0
+		for _, ns := range userNamespaces {
+			count, err := r.archiveNamespaceObjects(ctx, tarWriter, ns.Name, ns.Name+"/", true)
+			if err != nil {
+				return 0, err
+			}
+			total += count
+		}
+		return total, nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	location, err := store.Store(ctx, key, archive)
+	if err != nil {
+		return "", 0, err
+	}
+	log.FromContext(ctx).Info("exported migration bundle", "bundle", bundle, "location", location, "objects", count)
+	return location, count, nil
+}
+
+func (r *namespacesManager) importBundle(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, bundle string) (string, int, error) {
+	key, err := bundleKey(nsTmplSet, bundle)
+	if err != nil {
+		return "", 0, err
+	}
+	store, err := r.newBackupStore(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	if store == nil {
+		return "", 0, fmt.Errorf("no storage is configured for the migration bundles (see the '%s' annotation on the MemberOperatorConfig)", SpaceBackupAnnotationKey)
+	}
+	archive, err := store.Load(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	userNamespaces, err := fetchNamespacesByOwner(ctx, r.Client, nsTmplSet.Name)
+	if err != nil {
+		return "", 0, errs.Wrapf(err, "failed to list namespaces with label owner '%s'", nsTmplSet.Name)
+	}
+	namespaces := make([]string, 0, len(userNamespaces))
+	for _, ns := range userNamespaces {
+		namespaces = append(namespaces, ns.Name)
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return "", 0, errs.Wrapf(err, "invalid migration bundle '%s'", bundle)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	count, entries := 0, 0
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, errs.Wrapf(err, "invalid migration bundle '%s'", bundle)
+		}
+		if entries++; entries > maxBundleEntries {
+			return "", 0, fmt.Errorf("the migration bundle '%s' contains more than %d files", bundle, maxBundleEntries)
+		}
+		namespace, _, _ := strings.Cut(header.Name, "/")
+		if !slices.Contains(namespaces, namespace) {
+			// never create objects outside of the namespaces of the space
+			return "", 0, fmt.Errorf("the migration bundle '%s' contains the '%s' file which does not belong to a namespace of the space", bundle, header.Name)
+		}
+		// read one more byte than the limit to detect the manifests which are too large
+		manifest, err := io.ReadAll(io.LimitReader(tarReader, maxBundleManifestSize+1))
+		if err != nil {
+			return "", 0, errs.Wrapf(err, "invalid migration bundle '%s'", bundle)
+		}
+		if len(manifest) > maxBundleManifestSize {
+			return "", 0, fmt.Errorf("the '%s' file of the migration bundle '%s' is larger than %d bytes", header.Name, bundle, maxBundleManifestSize)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(manifest, &obj.Object); err != nil {
+			return "", 0, errs.Wrapf(err, "invalid manifest '%s' in migration bundle '%s'", header.Name, bundle)
+		}
+		if !slices.Contains(backupObjectKinds, obj.GroupVersionKind()) {
+			// never create objects of other kinds than the exported ones (eg, RoleBindings) with the privileges of the operator
+			return "", 0, fmt.Errorf("the '%s' file of the migration bundle '%s' contains an object of the unsupported '%s' kind", header.Name, bundle, obj.GroupVersionKind())
+		}
+		obj.SetNamespace(namespace)
+		obj.SetOwnerReferences(nil)
+		obj.SetUID("")
+		obj.SetResourceVersion("")
+		if obj.GetKind() == "Route" {
+			// let the target cluster generate the host of the route
+			unstructured.RemoveNestedField(obj.Object, "spec", "host")
+		}
+		if err := r.Client.Create(ctx, obj); err != nil {
+			if errors.IsAlreadyExists(err) {
+				continue // imported during a previous attempt
+			}
+			return "", 0, errs.Wrapf(newObjectError(applyOperation, obj, err), "failed to import %s '%s' in namespace '%s'", obj.GetKind(), obj.GetName(), namespace)
+		}
+		count++
+	}
+	log.FromContext(ctx).Info("imported migration bundle", "bundle", bundle, "key", key, "objects", count)
+	return key, count, nil
+}
+
+// bundleKey returns the key of the given migration bundle of the space in the storage
+func bundleKey(nsTmplSet *toolchainv1alpha1.NSTemplateSet, bundle string) (string, error) {
+	if bundle == "" || strings.ContainsAny(bundle, `/\`) || bundle == "." || bundle == ".." {
+		return "", fmt.Errorf("invalid migration bundle '%s'", bundle)
+	}
+	return path.Join("migrations", nsTmplSet.Name, bundle+".tar.gz"), nil
+}
+
+// setMigrationStatus records the outcome of the export or import of the bundle in the annotations of the NSTemplateSet.
+// The given error (if any) is returned, so that the migration is retried.
+func (r *namespacesManager) setMigrationStatus(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, bundle, successPhase, failurePhase, location string, count int, migrationErr error) error {
+	status := MigrationStatus{
+		Phase:    successPhase,
+		Bundle:   bundle,
+		Location: location,
+		Objects:  count,
+		Time:     metav1.Now(),
+	}
+	if migrationErr != nil {
+		status.Phase = failurePhase
+		status.Message = migrationErr.Error()
+	}
+	if err := r.setAnnotationJSON(ctx, nsTmplSet, MigrationStatusAnnotationKey, status); err != nil {
+		if migrationErr != nil {
+			log.FromContext(ctx).Error(err, "failed to set the migration status")
+			return migrationErr
+		}
+		return err
+	}
+	return migrationErr
+}
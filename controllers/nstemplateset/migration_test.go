@@ -0,0 +1,221 @@
+package nstemplateset
+
+import (
+	"archive/tar"
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/backup"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMigration(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	// provisioned space with the `dev` and `stage` namespaces
+	provisionedSpace := func(options ...nsTmplSetOption) []runtimeclient.Object {
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", append([]nsTmplSetOption{withNamespaces("abcde11", "dev", "stage")}, options...)...)
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		stageNS := newNamespace("basic", spacename, "stage", withTemplateRefUsingRevision("abcde11"))
+		return []runtimeclient.Object{
+			nsTmplSet, devNS, stageNS,
+			newRoleBinding(devNS.Name, "crtadmin-pods", spacename),
+			newRoleBinding(stageNS.Name, "crtadmin-pods", spacename),
+		}
+	}
+	userObjects := []runtimeclient.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: spacename + "-dev"},
+			Data:       map[string]string{"color": "blue"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: spacename + "-stage"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		},
+		&routev1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: spacename + "-stage"},
+			Spec: routev1.RouteSpec{
+				Host: "app-johnsmith-stage.apps.member-1.example.com",
+				To:   routev1.RouteTargetReference{Kind: "Service", Name: "app"},
+			},
+		},
+	}
+
+	t.Run("export and import", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		config := newBackupConfig(t, map[string]string{
+			SpaceBackupAnnotationKey:          "pvc",
+			SpaceBackupDirectoryAnnotationKey: dir,
+		})
+		source, req, sourceClient := prepareReconcile(t, namespaceName, spacename,
+			append(append(provisionedSpace(withAnnotation(MigrationExportAnnotationKey, "move-1")), userObjects...), config)...)
+		_, err := membercfg.ForceLoadConfiguration(sourceClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = source.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, sourceClient).HasConditions(Provisioned())
+		status := getStoredAnnotationJSON[MigrationStatus](t, sourceClient, namespaceName, spacename, MigrationStatusAnnotationKey)
+		assert.Equal(t, MigrationExportedPhase, status.Phase)
+		assert.Equal(t, "move-1", status.Bundle)
+		assert.Equal(t, 3, status.Objects)
+		manifests := readArchive(t, status.Location)
+		assert.Contains(t, manifests, "johnsmith-dev/configmap/settings.yaml")
+		assert.Contains(t, manifests, "johnsmith-stage/secret/credentials.yaml")
+		assert.Contains(t, manifests, "johnsmith-stage/route/app.yaml")
+		assert.NotContains(t, manifests, "johnsmith-dev/rolebinding/crtadmin-pods.yaml") // template objects are not exported
+
+		t.Run("export is not repeated", func(t *testing.T) {
+			// given
+			require.NoError(t, sourceClient.Delete(context.TODO(), userObjects[0]))
+
+			// when
+			_, err := source.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, status, getStoredAnnotationJSON[MigrationStatus](t, sourceClient, namespaceName, spacename, MigrationStatusAnnotationKey))
+		})
+
+		t.Run("import on the target cluster", func(t *testing.T) {
+			// given
+			target, req, targetClient := prepareReconcile(t, namespaceName, spacename,
+				append(provisionedSpace(withAnnotation(MigrationImportAnnotationKey, "move-1")), config)...)
+
+			// when
+			_, err := target.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			status := getStoredAnnotationJSON[MigrationStatus](t, targetClient, namespaceName, spacename, MigrationStatusAnnotationKey)
+			assert.Equal(t, MigrationImportedPhase, status.Phase)
+			assert.Equal(t, "move-1", status.Bundle)
+			assert.Equal(t, 3, status.Objects)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, targetClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-dev", Name: "settings"}, cm))
+			assert.Equal(t, map[string]string{"color": "blue"}, cm.Data)
+			secret := &corev1.Secret{}
+			require.NoError(t, targetClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-stage", Name: "credentials"}, secret))
+			assert.Equal(t, map[string][]byte{"password": []byte("s3cr3t")}, secret.Data)
+			route := &routev1.Route{}
+			require.NoError(t, targetClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-stage", Name: "app"}, route))
+			assert.Empty(t, route.Spec.Host)
+			assert.Equal(t, "app", route.Spec.To.Name)
+
+			t.Run("import is retried after a failure", func(t *testing.T) {
+				// given
+				nsTmplSet := &toolchainv1alpha1.NSTemplateSet{}
+				require.NoError(t, targetClient.Get(context.TODO(), req.NamespacedName, nsTmplSet))
+				err := target.namespaces.setMigrationStatus(context.TODO(), nsTmplSet, "move-1", MigrationImportedPhase, MigrationImportFailedPhase, "", 0, assert.AnError)
+				require.ErrorIs(t, err, assert.AnError)
+				require.Equal(t, MigrationImportFailedPhase, getStoredAnnotationJSON[MigrationStatus](t, targetClient, namespaceName, spacename, MigrationStatusAnnotationKey).Phase)
+
+				// when
+				_, err = target.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				status := getStoredAnnotationJSON[MigrationStatus](t, targetClient, namespaceName, spacename, MigrationStatusAnnotationKey)
+				assert.Equal(t, MigrationImportedPhase, status.Phase)
+				assert.Equal(t, 0, status.Objects) // all the objects already exist
+			})
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("no storage", func(t *testing.T) {
+			// given
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, provisionedSpace(withAnnotation(MigrationExportAnnotationKey, "move-1"))...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "no storage is configured for the migration bundles (see the 'toolchain.dev.openshift.com/space-backup' annotation on the MemberOperatorConfig)")
+			status := getStoredAnnotationJSON[MigrationStatus](t, fakeClient, namespaceName, spacename, MigrationStatusAnnotationKey)
+			assert.Equal(t, MigrationExportFailedPhase, status.Phase)
+			assert.Equal(t, "move-1", status.Bundle)
+			assert.Equal(t, err.Error(), status.Message)
+		})
+
+		t.Run("missing bundle", func(t *testing.T) {
+			// given
+			config := newBackupConfig(t, map[string]string{
+				SpaceBackupAnnotationKey:          "pvc",
+				SpaceBackupDirectoryAnnotationKey: t.TempDir(),
+			})
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(provisionedSpace(withAnnotation(MigrationImportAnnotationKey, "move-1")), config)...)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.ErrorContains(t, err, "unable to read the backup archive")
+			assert.Equal(t, MigrationImportFailedPhase, getStoredAnnotationJSON[MigrationStatus](t, fakeClient, namespaceName, spacename, MigrationStatusAnnotationKey).Phase)
+		})
+
+		t.Run("unsupported kind in bundle", func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			config := newBackupConfig(t, map[string]string{
+				SpaceBackupAnnotationKey:          "pvc",
+				SpaceBackupDirectoryAnnotationKey: dir,
+			})
+			archive, _, err := newArchive(func(tarWriter *tar.Writer) (int, error) {
+				manifest := []byte("apiVersion: rbac.authorization.k8s.io/v1\nkind: RoleBinding\nmetadata:\n  name: admin\n")
+				if err := tarWriter.WriteHeader(&tar.Header{Name: "johnsmith-dev/rolebinding/admin.yaml", Mode: 0o600, Size: int64(len(manifest))}); err != nil {
+					return 0, err
+				}
+				_, err := tarWriter.Write(manifest)
+				return 1, err
+			})
+			require.NoError(t, err)
+			_, err = backup.NewDirectoryStore(dir).Store(context.TODO(), "migrations/johnsmith/move-1.tar.gz", archive)
+			require.NoError(t, err)
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(provisionedSpace(withAnnotation(MigrationImportAnnotationKey, "move-1")), config)...)
+			_, err = membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "the 'johnsmith-dev/rolebinding/admin.yaml' file of the migration bundle 'move-1' contains an object of the unsupported 'rbac.authorization.k8s.io/v1, Kind=RoleBinding' kind")
+			assert.Equal(t, MigrationImportFailedPhase, getStoredAnnotationJSON[MigrationStatus](t, fakeClient, namespaceName, spacename, MigrationStatusAnnotationKey).Phase)
+			AssertThatRoleBinding(t, spacename+"-dev", "admin", fakeClient).DoesNotExist()
+		})
+
+		t.Run("invalid bundle", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+
+			// when
+			_, err := bundleKey(nsTmplSet, "../other-space/move-1")
+
+			// then
+			require.EqualError(t, err, "invalid migration bundle '../other-space/move-1'")
+		})
+	})
+}
@@ -0,0 +1,218 @@
+package nstemplateset
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	errs "github.com/pkg/errors"
+	"github.com/redhat-cop/operator-utils/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// NamespaceDeletionTimeoutAnnotationKey is the annotation set on the MemberOperatorConfig to configure the duration (eg, `5m`)
+	// after which the deletion of a NSTemplateSet whose namespaces are not deleted yet is reported as failed.
+	NamespaceDeletionTimeoutAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "namespace-deletion-timeout"
+	// StaleFinalizersAnnotationKey is the annotation set on the MemberOperatorConfig with the comma-separated list of finalizers
+	// whose controllers are known to be dead (or uninstalled). When set, these finalizers are removed from the objects which
+	// keep a terminating namespace alive, once the grace period has elapsed.
+	// Note: the finalizers are only removed from the objects of the releasable resources (see defaultReleasableResources
+	// and ReleasableResourcesAnnotationKey); they are only reported for the objects of the other resources.
+	StaleFinalizersAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "namespace-deletion-stale-finalizers"
+	// ReleasableResourcesAnnotationKey is the annotation set on the MemberOperatorConfig with the comma-separated list of the resources
+	// (as `<resource>.<group>`, eg `pipelineruns.tekton.dev`) whose objects can be freed from their stale finalizers, in addition
+	// to defaultReleasableResources. Typically, the custom resources whose controller was uninstalled.
+	// The operator must be granted the permissions to list and update these resources.
+	ReleasableResourcesAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "namespace-deletion-releasable-resources"
+	// StaleFinalizersGracePeriodAnnotationKey is the annotation set on the MemberOperatorConfig to configure the duration
+	// after the deletion of a namespace was triggered, before the stale finalizers are removed.
+	StaleFinalizersGracePeriodAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "namespace-deletion-stale-finalizers-grace-period"
+
+	defaultNamespaceDeletionTimeout   = time.Minute
+	defaultStaleFinalizersGracePeriod = 10 * time.Minute
+
+	// maxRemainingObjects is the maximum number of remaining objects reported in the diagnostics of a terminating namespace
+	maxRemainingObjects = 5
+
+	// minNamespaceDeletionRequeueDelay and maxNamespaceDeletionRequeueDelay are the bounds of the delay after which a NSTemplateSet
+	// whose namespaces are being deleted is reconciled again (see namespaceDeletionRequeueDelay)
+	minNamespaceDeletionRequeueDelay = time.Second
+	maxNamespaceDeletionRequeueDelay = time.Minute
+)
+
+// defaultReleasableResources are the resources whose objects can be freed from their stale finalizers by default, ie, the ones that
+// the operator is allowed to update (see the RBAC markers of the Reconciler)
+var defaultReleasableResources = []schema.GroupResource{
+	{Resource: "limitranges"},
+	{Resource: "resourcequotas"},
+	{Group: "rbac.authorization.k8s.io", Resource: "roles"},
+	{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"},
+	{Group: "authorization.openshift.io", Resource: "roles"},
+	{Group: "authorization.openshift.io", Resource: "rolebindings"},
+	{Group: "networking.k8s.io", Resource: "networkpolicies"},
+	{Group: "appstudio.redhat.com", Resource: "environments"},
+	{Group: "tekton.dev", Resource: "pipelineruns"},
+	{Group: "tekton.dev", Resource: "taskruns"},
+}
+
+// namespaceDeletionConfig contains the settings of the deletion of the namespaces
+type namespaceDeletionConfig struct {
+	timeout                    time.Duration
+	staleFinalizers            []string
+	staleFinalizersGracePeriod time.Duration
+	releasableResources        []schema.GroupResource
+}
+
+func getNamespaceDeletionConfig(ctx context.Context) namespaceDeletionConfig {
+	releasableResources := slices.Clone(defaultReleasableResources)
+	for _, resource := range memberOperatorConfigList(ReleasableResourcesAnnotationKey) {
+		releasableResources = append(releasableResources, schema.ParseGroupResource(resource))
+	}
+	return namespaceDeletionConfig{
+		timeout:                    memberOperatorConfigDuration(ctx, NamespaceDeletionTimeoutAnnotationKey, defaultNamespaceDeletionTimeout),
+		staleFinalizers:            memberOperatorConfigList(StaleFinalizersAnnotationKey),
+		staleFinalizersGracePeriod: memberOperatorConfigDuration(ctx, StaleFinalizersGracePeriodAnnotationKey, defaultStaleFinalizersGracePeriod),
+		releasableResources:        releasableResources,
+	}
+}
+
+// inspectTerminatingNamespaces returns the diagnostics of the namespaces of the given space which are being deleted, ie, what
+// keeps them alive according to their status conditions, along with the remaining objects which have finalizers.
+// If stale finalizers are configured, then they are also removed from the remaining objects once the grace period has elapsed.
+func (r *namespacesManager) inspectTerminatingNamespaces(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, config namespaceDeletionConfig) ([]string, error) {
+	userNamespaces, err := fetchNamespacesByOwner(ctx, r.Client, nsTmplSet.Name)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list namespace with label owner '%s'", nsTmplSet.Name)
+	}
+	var diagnostics []string
+	for i := range userNamespaces {
+		ns := &userNamespaces[i]
+		if !util.IsBeingDeleted(ns) {
+			continue
+		}
+		nsDiagnostics, err := r.inspectTerminatingNamespace(ctx, ns, config)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, nsDiagnostics...)
+	}
+	return diagnostics, nil
+}
+
+func (r *namespacesManager) inspectTerminatingNamespace(ctx context.Context, ns *corev1.Namespace, config namespaceDeletionConfig) ([]string, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", ns.Name)
+	var diagnostics []string
+	var remainingResources []schema.GroupResource
+	for _, cond := range ns.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		diagnostics = append(diagnostics, fmt.Sprintf("namespace '%s': %s", ns.Name, cond.Message))
+		if cond.Type == corev1.NamespaceContentRemaining {
+			remainingResources = parseRemainingResources(cond.Message)
+		}
+	}
+
+	releaseStaleFinalizers := len(config.staleFinalizers) > 0 && time.Since(ns.DeletionTimestamp.Time) > config.staleFinalizersGracePeriod
+	var remaining []string
+	for _, gr := range remainingResources {
+		releasable := releaseStaleFinalizers && slices.Contains(config.releasableResources, gr)
+		objs, err := r.listRemainingObjects(ctx, ns.Name, gr)
+		if err != nil {
+			// diagnostics are best-effort: the resource may not be known or listable
+			logger.Info("unable to list the remaining objects", "resource", gr.String(), "error", err.Error())
+			continue
+		}
+		for i := range objs {
+			obj := &objs[i]
+			if len(obj.GetFinalizers()) == 0 {
+				continue
+			}
+			if releasable {
+				released, err := r.releaseStaleFinalizers(ctx, obj, config.staleFinalizers)
+				if err != nil {
+					return nil, err
+				}
+				if released {
+					continue
+				}
+			}
+			remaining = append(remaining, fmt.Sprintf("%s/%s (finalizers: %s)", gr.String(), obj.GetName(), strings.Join(obj.GetFinalizers(), ",")))
+		}
+	}
+	if len(remaining) > maxRemainingObjects {
+		remaining = append(remaining[:maxRemainingObjects], fmt.Sprintf("and %d more", len(remaining)-maxRemainingObjects))
+	}
+	if len(remaining) > 0 {
+		diagnostics = append(diagnostics, fmt.Sprintf("namespace '%s': remaining objects with finalizers: %s", ns.Name, strings.Join(remaining, ", ")))
+	}
+	return diagnostics, nil
+}
+
+func (r *namespacesManager) listRemainingObjects(ctx context.Context, namespace string, gr schema.GroupResource) ([]unstructured.Unstructured, error) {
+	gvk, err := r.Client.RESTMapper().KindFor(gr.WithVersion(""))
+	if err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := r.Client.List(ctx, list, runtimeclient.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// releaseStaleFinalizers removes the given stale finalizers from the object.
+// Returns `true` if the object has no finalizers left.
+func (r *namespacesManager) releaseStaleFinalizers(ctx context.Context, obj *unstructured.Unstructured, staleFinalizers []string) (bool, error) {
+	finalizers := slices.DeleteFunc(slices.Clone(obj.GetFinalizers()), func(finalizer string) bool {
+		return slices.Contains(staleFinalizers, finalizer)
+	})
+	if len(finalizers) == len(obj.GetFinalizers()) {
+		return false, nil
+	}
+	log.FromContext(ctx).Info("removing stale finalizers from object in terminating namespace", "object_namespace", obj.GetNamespace(),
+		"object_name", obj.GetKind()+"/"+obj.GetName(), "finalizers", obj.GetFinalizers(), "remaining_finalizers", finalizers)
+	obj.SetFinalizers(finalizers)
+	if err := r.Client.Update(ctx, obj); err != nil {
+		return false, errs.Wrapf(newObjectError(applyOperation, obj, err), "failed to remove the stale finalizers from %s '%s' in namespace '%s'", obj.GetKind(), obj.GetName(), obj.GetNamespace())
+	}
+	return len(finalizers) == 0, nil
+}
+
+// namespaceDeletionRequeueDelay returns the delay after which a NSTemplateSet whose namespaces are being deleted since the given duration
+// is reconciled again: the delay doubles with the duration of the deletion (so that the namespaces which take long to be deleted
+// are not inspected every second), within the bounds of min/maxNamespaceDeletionRequeueDelay, but without exceeding the timeout.
+func namespaceDeletionRequeueDelay(deletedSince, timeout time.Duration) time.Duration {
+	delay := min(max(deletedSince, minNamespaceDeletionRequeueDelay), maxNamespaceDeletionRequeueDelay)
+	if remaining := timeout - deletedSince; remaining > 0 && remaining < delay {
+		delay = max(remaining, minNamespaceDeletionRequeueDelay)
+	}
+	return delay
+}
+
+// parseRemainingResources parses the message of the `NamespaceContentRemaining` condition set by the namespace controller,
+// eg: `Some resources are remaining: pipelineruns.tekton.dev has 2 resource instances, configmaps has 1 resource instances`
+func parseRemainingResources(message string) []schema.GroupResource {
+	_, list, found := strings.Cut(message, ": ")
+	if !found {
+		return nil
+	}
+	var resources []schema.GroupResource
+	for _, item := range strings.Split(list, ", ") {
+		resource, _, found := strings.Cut(strings.TrimSpace(item), " has ")
+		if !found || resource == "" {
+			continue
+		}
+		resources = append(resources, schema.ParseGroupResource(resource))
+	}
+	return resources
+}
@@ -0,0 +1,324 @@
+package nstemplateset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDeleteNSTemplateSetWithTerminatingNamespace(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	newTerminatingNamespace := func(deletedSince time.Duration) *corev1.Namespace {
+		devNS := newNamespace("advanced", spacename, "dev", withTemplateRefUsingRevision("abcde11"), withFinalizer())
+		devNS.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-deletedSince)}
+		devNS.Status = corev1.NamespaceStatus{
+			Phase: corev1.NamespaceTerminating,
+			Conditions: []corev1.NamespaceCondition{
+				{
+					Type:    corev1.NamespaceDeletionDiscoveryFailure,
+					Status:  corev1.ConditionFalse,
+					Message: "All resources successfully discovered",
+				},
+				{
+					Type:    corev1.NamespaceContentRemaining,
+					Status:  corev1.ConditionTrue,
+					Message: "Some resources are remaining: configmaps has 1 resource instances",
+				},
+				{
+					Type:    corev1.NamespaceFinalizersRemaining,
+					Status:  corev1.ConditionTrue,
+					Message: "Some content in the namespace has finalizers remaining: example.com/cleanup in 1 resource instances",
+				},
+			},
+		}
+		return devNS
+	}
+	newConfigMapWithFinalizer := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "leftover",
+				Namespace:         spacename + "-dev",
+				DeletionTimestamp: &metav1.Time{Time: time.Now()},
+				Finalizers:        []string{"example.com/cleanup"},
+			},
+		}
+	}
+	newTerminatingNamespaceWithLimitRange := func(deletedSince time.Duration) *corev1.Namespace {
+		devNS := newTerminatingNamespace(deletedSince)
+		devNS.Status.Conditions[1].Message = "Some resources are remaining: limitranges has 1 resource instances"
+		return devNS
+	}
+	newLimitRangeWithFinalizer := func() *corev1.LimitRange {
+		return &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "leftover",
+				Namespace:         spacename + "-dev",
+				DeletionTimestamp: &metav1.Time{Time: time.Now()},
+				Finalizers:        []string{"example.com/cleanup"},
+			},
+		}
+	}
+	diagnostics := "namespace 'johnsmith-dev': Some resources are remaining: configmaps has 1 resource instances; " +
+		"namespace 'johnsmith-dev': Some content in the namespace has finalizers remaining: example.com/cleanup in 1 resource instances; " +
+		"namespace 'johnsmith-dev': remaining objects with finalizers: configmaps/leftover (finalizers: example.com/cleanup)"
+
+	t.Run("reports the diagnostics while waiting for the deletion", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs(), withConditions(Terminating()))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespace(10*time.Second), newConfigMapWithFinalizer())
+		withCoreRESTMapping(fakeClient)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Second, result.RequeueAfter)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasFinalizer().
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  toolchainv1alpha1.NSTemplateSetTerminatingReason,
+				Message: diagnostics,
+			})
+	})
+
+	t.Run("fails with the diagnostics after the default timeout", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs())
+		nsTmplSet.SetDeletionTimestamp(&metav1.Time{Time: time.Now().Add(-61 * time.Second)})
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespace(61*time.Second), newConfigMapWithFinalizer())
+		withCoreRESTMapping(fakeClient)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.EqualError(t, err, "NSTemplateSet deletion has not completed in over 1m0s: "+diagnostics)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasFinalizer().
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  toolchainv1alpha1.NSTemplateSetTerminatingFailedReason,
+				Message: "NSTemplateSet deletion has not completed in over 1m0s: " + diagnostics,
+			})
+	})
+
+	t.Run("with configuration in MemberOperatorConfig", func(t *testing.T) {
+
+		t.Run("waits until the configured timeout", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				NamespaceDeletionTimeoutAnnotationKey: "5m",
+			}
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs())
+			nsTmplSet.SetDeletionTimestamp(&metav1.Time{Time: time.Now().Add(-2 * time.Minute)})
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespace(2*time.Minute), newConfigMapWithFinalizer(), config)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			result, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, time.Minute, result.RequeueAfter)
+		})
+
+		t.Run("removes the stale finalizers after the grace period", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				StaleFinalizersAnnotationKey:            "example.com/cleanup, example.com/other",
+				StaleFinalizersGracePeriodAnnotationKey: "30s",
+			}
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs())
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespaceWithLimitRange(40*time.Second), newLimitRangeWithFinalizer(), config)
+			withCoreRESTMapping(fakeClient)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-dev", Name: "leftover"}, &corev1.LimitRange{})
+			require.True(t, errors.IsNotFound(err), "the limitrange should have been deleted once its stale finalizer was removed")
+		})
+
+		t.Run("keeps the stale finalizers of the objects which the operator is not allowed to update", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				StaleFinalizersAnnotationKey:            "example.com/cleanup",
+				StaleFinalizersGracePeriodAnnotationKey: "30s",
+			}
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs())
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespace(40*time.Second), newConfigMapWithFinalizer(), config)
+			withCoreRESTMapping(fakeClient)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-dev", Name: "leftover"}, cm))
+			assert.Equal(t, []string{"example.com/cleanup"}, cm.Finalizers)
+			AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    toolchainv1alpha1.ConditionReady,
+					Status:  corev1.ConditionFalse,
+					Reason:  toolchainv1alpha1.NSTemplateSetTerminatingReason,
+					Message: diagnostics,
+				})
+		})
+
+		t.Run("removes the stale finalizers of the objects of the configured releasable resources", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				StaleFinalizersAnnotationKey:            "example.com/cleanup",
+				StaleFinalizersGracePeriodAnnotationKey: "30s",
+				ReleasableResourcesAnnotationKey:        "pipelineruns.tekton.dev, configmaps",
+			}
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs())
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespace(40*time.Second), newConfigMapWithFinalizer(), config)
+			withCoreRESTMapping(fakeClient)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-dev", Name: "leftover"}, &corev1.ConfigMap{})
+			require.True(t, errors.IsNotFound(err), "the configmap should have been deleted once its stale finalizer was removed")
+		})
+
+		t.Run("keeps the stale finalizers during the grace period", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				StaleFinalizersAnnotationKey: "example.com/cleanup",
+			}
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withNamespaces("abcde11", "dev"), withDeletionTs())
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, newTerminatingNamespace(40*time.Second), newConfigMapWithFinalizer(), config)
+			withCoreRESTMapping(fakeClient)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-dev", Name: "leftover"}, cm))
+			assert.Equal(t, []string{"example.com/cleanup"}, cm.Finalizers)
+		})
+
+		t.Run("ignores an invalid timeout", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				NamespaceDeletionTimeoutAnnotationKey: "soon",
+			}
+			fakeClient := test.NewFakeClient(t, config)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			cfg := getNamespaceDeletionConfig(context.TODO())
+
+			// then
+			assert.Equal(t, defaultNamespaceDeletionTimeout, cfg.timeout)
+			assert.Equal(t, defaultStaleFinalizersGracePeriod, cfg.staleFinalizersGracePeriod)
+			assert.Empty(t, cfg.staleFinalizers)
+		})
+	})
+}
+
+// withCoreRESTMapping makes the ConfigMaps and LimitRanges known to the RESTMapper of the fake client (which is empty by default)
+func withCoreRESTMapping(fakeClient *test.FakeClient) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{corev1.SchemeGroupVersion})
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("LimitRange"), meta.RESTScopeNamespace)
+	fakeClient.Client = &restMapperClient{Client: fakeClient.Client, mapper: mapper}
+}
+
+type restMapperClient struct {
+	runtimeclient.Client
+	mapper meta.RESTMapper
+}
+
+func (c *restMapperClient) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func TestNamespaceDeletionRequeueDelay(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		deletedSince time.Duration
+		timeout      time.Duration
+		expected     time.Duration
+	}{
+		{name: "just deleted", deletedSince: 0, timeout: time.Minute, expected: time.Second},
+		{name: "doubles with the duration of the deletion", deletedSince: 10 * time.Second, timeout: 5 * time.Minute, expected: 10 * time.Second},
+		{name: "capped", deletedSince: 2 * time.Minute, timeout: 5 * time.Minute, expected: time.Minute},
+		{name: "does not exceed the timeout", deletedSince: 50 * time.Second, timeout: time.Minute, expected: 10 * time.Second},
+		{name: "timeout exceeded", deletedSince: 2 * time.Minute, timeout: time.Minute, expected: time.Minute},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, namespaceDeletionRequeueDelay(tc.deletedSince, tc.timeout))
+		})
+	}
+}
+
+func TestParseRemainingResources(t *testing.T) {
+	t.Run("core and custom resources", func(t *testing.T) {
+		// when
+		resources := parseRemainingResources("Some resources are remaining: pipelineruns.tekton.dev has 2 resource instances, configmaps has 1 resource instances")
+
+		// then
+		assert.Equal(t, []schema.GroupResource{
+			{Group: "tekton.dev", Resource: "pipelineruns"},
+			{Group: "", Resource: "configmaps"},
+		}, resources)
+	})
+
+	t.Run("unexpected message", func(t *testing.T) {
+		// when
+		resources := parseRemainingResources("All content successfully removed")
+
+		// then
+		assert.Empty(t, resources)
+	})
+}
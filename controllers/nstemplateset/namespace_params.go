@@ -0,0 +1,85 @@
+package nstemplateset
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// NamespaceParametersAnnotationKey is the annotation on the NSTemplateSet with the (JSON-encoded) extra parameters passed to the
+	// templates of its namespaces, by namespace type, eg: `{"dev":{"STORAGE_QUOTA":"20Gi"}}`.
+	// These parameters override the static parameters of the TierTemplateRevisions (and the default values of the parameters of the
+	// OpenShift templates), but they never override the parameters set by the operator, such as `SPACE_NAME`.
+	// The parameters under the `*` key are passed to the templates of all the namespace types.
+	NamespaceParametersAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "namespace-parameters"
+	// AppliedNamespaceParametersAnnotationKey is the annotation set on the namespaces with the (JSON-encoded) extra parameters
+	// which were passed to their template, so that the resources of the namespace are applied again when these parameters change,
+	// and so that the objects of the previous template (rendered with these parameters) which became obsolete can be deleted
+	AppliedNamespaceParametersAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "applied-namespace-parameters"
+)
+
+// allNamespaceTypes is the key of the extra parameters passed to the templates of all the namespace types
+const allNamespaceTypes = "*"
+
+// namespaceParameters are the extra parameters of the namespace templates, by namespace type
+type namespaceParameters map[string]map[string]string
+
+// getNamespaceParameters returns the extra parameters of the namespace templates: the parameters of the feature toggles
+// of the given NSTemplateSet (see featureParams) for all the namespace types, overridden by the parameters stored in its annotation
+func getNamespaceParameters(nsTmplSet *toolchainv1alpha1.NSTemplateSet) (namespaceParameters, error) {
+	params := namespaceParameters{}
+	if value := nsTmplSet.GetAnnotations()[NamespaceParametersAnnotationKey]; value != "" {
+		if err := json.Unmarshal([]byte(value), &params); err != nil {
+			return nil, fmt.Errorf("invalid value of the '%s' annotation: %w", NamespaceParametersAnnotationKey, err)
+		}
+	}
+	_, features := featureAnnotationNeedsUpdate(nsTmplSet)
+	if fParams := featureParams(features); len(fParams) > 0 {
+		maps.Copy(fParams, params[allNamespaceTypes])
+		params[allNamespaceTypes] = fParams
+	}
+	return params, nil
+}
+
+// forType returns the parameters to process the template of the given namespace type with: the extra parameters of all
+// the namespace types, overridden by the ones of this namespace type, overridden by the given runtime parameters (eg, `SPACE_NAME`)
+func (p namespaceParameters) forType(typeName string, runtimeParams map[string]string) map[string]string {
+	params := make(map[string]string, len(p[allNamespaceTypes])+len(p[typeName])+len(runtimeParams))
+	maps.Copy(params, p[allNamespaceTypes])
+	maps.Copy(params, p[typeName])
+	maps.Copy(params, runtimeParams)
+	return params
+}
+
+// applied returns the (JSON-encoded) extra parameters of the given namespace type, to be stored in the annotation of the namespace
+// once they were applied, or an empty string if there is none
+func (p namespaceParameters) applied(typeName string) string {
+	params := p.forType(typeName, nil)
+	if len(params) == 0 {
+		return ""
+	}
+	// the keys of the map are sorted when marshalled, so the value does not depend on the order of the parameters in the annotation
+	value, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
+
+// getAppliedNamespaceParameters returns the extra parameters which were passed to the template of the given namespace, as stored
+// in its annotation, and `false` if they are unknown (ie, if the annotation has an invalid value)
+func getAppliedNamespaceParameters(namespace *corev1.Namespace) (map[string]string, bool) {
+	value, found := namespace.GetAnnotations()[AppliedNamespaceParametersAnnotationKey]
+	if !found || value == "" {
+		return map[string]string{}, true
+	}
+	params := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &params); err != nil {
+		return nil, false
+	}
+	return params, true
+}
@@ -0,0 +1,308 @@
+package nstemplateset
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGetNamespaceParameters(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+
+	t.Run("no annotation", func(t *testing.T) {
+		// when
+		params, err := getNamespaceParameters(newNSTmplSet(namespaceName, spacename, "basic"))
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, params)
+	})
+
+	t.Run("parameters by namespace type", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic",
+			withAnnotation(NamespaceParametersAnnotationKey, `{"dev":{"STORAGE_QUOTA":"20Gi"},"stage":{"STORAGE_QUOTA":"10Gi","REPLICAS":"2"}}`))
+
+		// when
+		params, err := getNamespaceParameters(nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, namespaceParameters{
+			"dev":   {"STORAGE_QUOTA": "20Gi"},
+			"stage": {"STORAGE_QUOTA": "10Gi", "REPLICAS": "2"},
+		}, params)
+	})
+
+	t.Run("parameters of the feature toggles for all namespace types", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic",
+			withNSTemplateSetFeatureAnnotation("quota-boost;BOOST_CPU=4;STORAGE_QUOTA=5Gi,other;REPLICAS=3"),
+			withAnnotation(NamespaceParametersAnnotationKey, `{"*":{"REPLICAS":"2"},"dev":{"STORAGE_QUOTA":"20Gi"}}`))
+
+		// when
+		params, err := getNamespaceParameters(nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, namespaceParameters{
+			allNamespaceTypes: {"BOOST_CPU": "4", "STORAGE_QUOTA": "5Gi", "REPLICAS": "2"}, // the annotation overrides the feature toggles
+			"dev":             {"STORAGE_QUOTA": "20Gi"},
+		}, params)
+		assert.Equal(t, map[string]string{"BOOST_CPU": "4", "STORAGE_QUOTA": "20Gi", "REPLICAS": "2", SpaceName: spacename},
+			params.forType("dev", map[string]string{SpaceName: spacename}))
+		assert.Equal(t, map[string]string{"BOOST_CPU": "4", "STORAGE_QUOTA": "5Gi", "REPLICAS": "2", SpaceName: spacename},
+			params.forType("stage", map[string]string{SpaceName: spacename}))
+	})
+
+	t.Run("invalid annotation", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withAnnotation(NamespaceParametersAnnotationKey, `{"dev":"20Gi"}`))
+
+		// when
+		_, err := getNamespaceParameters(nsTmplSet)
+
+		// then
+		require.ErrorContains(t, err, "invalid value of the '"+NamespaceParametersAnnotationKey+"' annotation")
+	})
+}
+
+func TestNamespaceParametersForType(t *testing.T) {
+	// given
+	params := namespaceParameters{
+		"dev": {"STORAGE_QUOTA": "20Gi", SpaceName: "janedoe"},
+	}
+
+	t.Run("runtime parameters override the parameters of the namespace type", func(t *testing.T) {
+		// when
+		result := params.forType("dev", map[string]string{SpaceName: "johnsmith"})
+
+		// then
+		assert.Equal(t, map[string]string{"STORAGE_QUOTA": "20Gi", SpaceName: "johnsmith"}, result)
+		assert.Equal(t, "janedoe", params["dev"][SpaceName]) // not modified
+	})
+
+	t.Run("no parameters for the namespace type", func(t *testing.T) {
+		// when
+		result := params.forType("stage", map[string]string{SpaceName: "johnsmith"})
+
+		// then
+		assert.Equal(t, map[string]string{SpaceName: "johnsmith"}, result)
+	})
+
+	t.Run("no parameters at all", func(t *testing.T) {
+		// when
+		result := namespaceParameters(nil).forType("dev", map[string]string{SpaceName: "johnsmith"})
+
+		// then
+		assert.Equal(t, map[string]string{SpaceName: "johnsmith"}, result)
+	})
+}
+
+func TestNamespaceParametersApplied(t *testing.T) {
+	// given
+	params := namespaceParameters{
+		"dev":   {"STORAGE_QUOTA": "20Gi", "REPLICAS": "2"},
+		"stage": {"REPLICAS": "2", "STORAGE_QUOTA": "20Gi"},
+		"empty": {},
+	}
+
+	// then
+	assert.Empty(t, params.applied("empty"))
+	assert.Empty(t, params.applied("unknown"))
+	assert.JSONEq(t, `{"REPLICAS":"2","STORAGE_QUOTA":"20Gi"}`, params.applied("dev"))
+	assert.Equal(t, params.applied("dev"), params.applied("stage"))
+
+	t.Run("with parameters for all namespace types", func(t *testing.T) {
+		// given
+		params[allNamespaceTypes] = map[string]string{"BOOST_CPU": "4"}
+
+		// then
+		assert.JSONEq(t, `{"BOOST_CPU":"4"}`, params.applied("empty"))
+		assert.JSONEq(t, `{"BOOST_CPU":"4","REPLICAS":"2","STORAGE_QUOTA":"20Gi"}`, params.applied("dev"))
+	})
+}
+
+func TestGetAppliedNamespaceParameters(t *testing.T) {
+	withAnnotation := func(value string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AppliedNamespaceParametersAnnotationKey: value},
+			},
+		}
+	}
+
+	t.Run("no annotation", func(t *testing.T) {
+		// when
+		params, known := getAppliedNamespaceParameters(&corev1.Namespace{})
+
+		// then
+		assert.True(t, known)
+		assert.Empty(t, params)
+	})
+
+	t.Run("applied parameters", func(t *testing.T) {
+		// when
+		params, known := getAppliedNamespaceParameters(withAnnotation(`{"STORAGE_QUOTA":"20Gi"}`))
+
+		// then
+		assert.True(t, known)
+		assert.Equal(t, map[string]string{"STORAGE_QUOTA": "20Gi"}, params)
+	})
+
+	t.Run("checksum stored by a previous version", func(t *testing.T) {
+		// when
+		_, known := getAppliedNamespaceParameters(withAnnotation(sha256sum(`{"STORAGE_QUOTA":"20Gi"}`)))
+
+		// then
+		assert.False(t, known)
+	})
+}
+
+func TestEnsureNamespacesWithParameters(t *testing.T) {
+	// given
+	ctx := context.TODO()
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+
+	t.Run("default value of the template parameter", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "params", withNamespaces("abcde11", "dev"))
+		devNS := newNamespace("", spacename, "dev")
+		manager, fakeClient := prepareNamespacesManager(t, nsTmplSet, devNS)
+
+		// when
+		_, err := manager.ensure(ctx, nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assertStorageQuota(t, fakeClient, spacename+"-dev", "5Gi")
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).
+			HasLabel(toolchainv1alpha1.TemplateRefLabelKey, "params-dev-abcde11").
+			HasNoAnnotation(AppliedNamespaceParametersAnnotationKey)
+	})
+
+	t.Run("parameters of the namespace type", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "params", withNamespaces("abcde11", "dev"),
+			withAnnotation(NamespaceParametersAnnotationKey, `{"dev":{"STORAGE_QUOTA":"20Gi","SPACE_NAME":"janedoe"}}`))
+		devNS := newNamespace("params", spacename, "dev")
+		manager, fakeClient := prepareNamespacesManager(t, nsTmplSet, devNS)
+
+		// when
+		createdOrUpdated, err := manager.ensure(ctx, nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, createdOrUpdated)
+		assertStorageQuota(t, fakeClient, spacename+"-dev", "20Gi") // and not in the `janedoe-dev` namespace
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).
+			HasAnnotation(AppliedNamespaceParametersAnnotationKey, `{"SPACE_NAME":"janedoe","STORAGE_QUOTA":"20Gi"}`)
+
+		t.Run("nothing to do when the parameters did not change", func(t *testing.T) {
+			// when
+			createdOrUpdated, err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, createdOrUpdated)
+		})
+
+		t.Run("re-applied when the parameters changed", func(t *testing.T) {
+			// given
+			require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: spacename}, nsTmplSet))
+			nsTmplSet.Annotations[NamespaceParametersAnnotationKey] = `{"dev":{"STORAGE_QUOTA":"30Gi"}}`
+			require.NoError(t, fakeClient.Update(ctx, nsTmplSet))
+
+			// when
+			createdOrUpdated, err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, createdOrUpdated)
+			assertStorageQuota(t, fakeClient, spacename+"-dev", "30Gi")
+			AssertThatNamespace(t, spacename+"-dev", fakeClient).
+				HasAnnotation(AppliedNamespaceParametersAnnotationKey, `{"STORAGE_QUOTA":"30Gi"}`)
+
+			t.Run("re-applied when the parameters were removed", func(t *testing.T) {
+				// given
+				require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: spacename}, nsTmplSet))
+				delete(nsTmplSet.Annotations, NamespaceParametersAnnotationKey)
+				require.NoError(t, fakeClient.Update(ctx, nsTmplSet))
+
+				// when
+				createdOrUpdated, err := manager.ensure(ctx, nsTmplSet)
+
+				// then
+				require.NoError(t, err)
+				assert.True(t, createdOrUpdated)
+				assertStorageQuota(t, fakeClient, spacename+"-dev", "5Gi")
+				AssertThatNamespace(t, spacename+"-dev", fakeClient).HasNoAnnotation(AppliedNamespaceParametersAnnotationKey)
+			})
+		})
+	})
+
+	t.Run("obsolete objects rendered with the applied parameters are deleted", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "params", withNamespaces("abcde12", "dev"),
+			withAnnotation(NamespaceParametersAnnotationKey, `{"dev":{"STORAGE_CLASS":"fast"}}`))
+		devNS := newNamespace("params", spacename, "dev")
+		manager, fakeClient := prepareNamespacesManager(t, nsTmplSet, devNS)
+		_, err := manager.ensure(ctx, nsTmplSet)
+		require.NoError(t, err)
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: spacename + "-dev", Name: "storage-fast"}, &corev1.ResourceQuota{}))
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: spacename}, nsTmplSet))
+		nsTmplSet.Annotations[NamespaceParametersAnnotationKey] = `{"dev":{"STORAGE_CLASS":"slow"}}`
+		require.NoError(t, fakeClient.Update(ctx, nsTmplSet))
+
+		// when
+		createdOrUpdated, err := manager.ensure(ctx, nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, createdOrUpdated)
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: spacename + "-dev", Name: "storage-slow"}, &corev1.ResourceQuota{}))
+		err = fakeClient.Get(ctx, types.NamespacedName{Namespace: spacename + "-dev", Name: "storage-fast"}, &corev1.ResourceQuota{})
+		require.True(t, apierrors.IsNotFound(err), "expected the obsolete quota to be deleted, got %v", err)
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).
+			HasAnnotation(AppliedNamespaceParametersAnnotationKey, `{"STORAGE_CLASS":"slow"}`)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "params", withNamespaces("abcde11", "dev"),
+			withAnnotation(NamespaceParametersAnnotationKey, `invalid`))
+		manager, fakeClient := prepareNamespacesManager(t, nsTmplSet)
+
+		// when
+		_, err := manager.ensure(ctx, nsTmplSet)
+
+		// then
+		require.ErrorContains(t, err, "failed to get the parameters of the namespaces")
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(UnableToProvisionNamespace("invalid value of the '" + NamespaceParametersAnnotationKey + "' annotation: invalid character 'i' looking for beginning of value"))
+	})
+}
+
+func assertStorageQuota(t *testing.T, cl runtimeclient.Client, namespace, expected string) {
+	quota := &corev1.ResourceQuota{}
+	require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "storage"}, quota))
+	assert.True(t, resource.MustParse(expected).Equal(quota.Spec.Hard[corev1.ResourceRequestsStorage]), "expected %s, got %v", expected, quota.Spec.Hard)
+}
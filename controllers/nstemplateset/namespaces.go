@@ -3,6 +3,7 @@ package nstemplateset
 import (
 	"context"
 	"fmt"
+	"maps"
 	"sort"
 
 	rbac "k8s.io/api/rbac/v1"
@@ -38,20 +39,27 @@ func (r *namespacesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1al
 		return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err,
 			"failed to get TierTemplates for tier '%s'", nsTmplSet.Spec.TierName)
 	}
+	namespaceParams, err := getNamespaceParameters(nsTmplSet)
+	if err != nil {
+		return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to get the parameters of the namespaces")
+	}
 	toDeprovision, found := nextNamespaceToDeprovision(tierTemplatesByType, userNamespaces)
 	if found {
 		if err := r.setStatusUpdatingIfNotProvisioning(ctx, nsTmplSet); err != nil {
 			return false, err
 		}
+		if err := r.backupNamespace(ctx, nsTmplSet, toDeprovision); err != nil {
+			return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusUpdateFailed, err, "failed to back up namespace %s", toDeprovision.Name)
+		}
 		if err := r.Client.Delete(ctx, toDeprovision); err != nil {
-			return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusUpdateFailed, err, "failed to delete namespace %s", toDeprovision.Name)
+			return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusUpdateFailed, newObjectError(deleteOperation, toDeprovision, err), "failed to delete namespace %s", toDeprovision.Name)
 		}
 		logger.Info("deleted namespace as part of NSTemplateSet update", "namespace", toDeprovision.Name)
 		return true, nil // we deleted the namespace - wait for another reconcile
 	}
 
 	// find next namespace for provisioning namespace resource
-	tierTemplate, userNamespace, found, err := r.nextNamespaceToProvisionOrUpdate(ctx, tierTemplatesByType, userNamespaces)
+	tierTemplate, userNamespace, found, err := r.nextNamespaceToProvisionOrUpdate(ctx, tierTemplatesByType, namespaceParams, userNamespaces)
 	if err != nil {
 		return false, err
 	}
@@ -70,11 +78,11 @@ func (r *namespacesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1al
 		}
 	}
 	// create namespace resource
-	return true, r.ensureNamespace(ctx, nsTmplSet, tierTemplate, userNamespace)
+	return true, r.ensureNamespace(ctx, nsTmplSet, tierTemplate, namespaceParams, userNamespace)
 }
 
 // ensureNamespace ensures that the namespace exists and that it contains all the expected resources
-func (r *namespacesManager) ensureNamespace(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate, userNamespace *corev1.Namespace) error {
+func (r *namespacesManager) ensureNamespace(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate, namespaceParams namespaceParameters, userNamespace *corev1.Namespace) error {
 	logger := log.FromContext(ctx)
 	logger.Info("ensuring namespace", "namespace", tierTemplate.typeName, "tier", nsTmplSet.Spec.TierName)
 
@@ -85,7 +93,7 @@ func (r *namespacesManager) ensureNamespace(ctx context.Context, nsTmplSet *tool
 		logger.Info("namespace needs to be created")
 	} else {
 		// userNamespace exists, check if the namespace needs to be updated
-		upToDate, err := r.namespaceHasExpectedLabelsFromTemplate(tierTemplate, userNamespace)
+		upToDate, err := r.namespaceHasExpectedLabelsFromTemplate(tierTemplate, namespaceParams, userNamespace)
 		if err != nil {
 			return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to get namespace object from template for namespace type '%s'", tierTemplate.typeName)
 		}
@@ -95,17 +103,17 @@ func (r *namespacesManager) ensureNamespace(ctx context.Context, nsTmplSet *tool
 
 	// create namespace before creating inner resources because creating the namespace may take some time
 	if createOrUpdateNamespace {
-		return r.ensureNamespaceResource(ctx, nsTmplSet, tierTemplate)
+		return r.ensureNamespaceResource(ctx, nsTmplSet, tierTemplate, namespaceParams)
 	}
-	return r.ensureInnerNamespaceResources(ctx, nsTmplSet, tierTemplate, userNamespace)
+	return r.ensureInnerNamespaceResources(ctx, nsTmplSet, tierTemplate, namespaceParams, userNamespace)
 }
 
 // namespaceHasExpectedLabelsFromTemplate checks if the namespace has the expected labels from the template object
 // note: checks only if the namespace has labels that match the provided template, it does not check whether any labels could have been removed
-func (r *namespacesManager) namespaceHasExpectedLabelsFromTemplate(tierTemplate *tierTemplate, userNamespace *corev1.Namespace) (bool, error) {
-	objs, err := tierTemplate.process(r.Scheme, map[string]string{
+func (r *namespacesManager) namespaceHasExpectedLabelsFromTemplate(tierTemplate *tierTemplate, namespaceParams namespaceParameters, userNamespace *corev1.Namespace) (bool, error) {
+	objs, err := tierTemplate.process(r.Scheme, namespaceParams.forType(tierTemplate.typeName, map[string]string{
 		SpaceName: userNamespace.GetLabels()[toolchainv1alpha1.SpaceLabelKey],
-	}, template.RetainNamespaces)
+	}), template.RetainNamespaces)
 	if err != nil {
 		return false, err
 	}
@@ -148,12 +156,12 @@ func mapContains(actual, contains map[string]string) bool {
 }
 
 // ensureNamespaceResource ensures that the namespace exists.
-func (r *namespacesManager) ensureNamespaceResource(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate) error {
+func (r *namespacesManager) ensureNamespaceResource(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate, namespaceParams namespaceParameters) error {
 	logger := log.FromContext(ctx)
 	logger.Info("creating namespace", "spacename", nsTmplSet.GetName(), "tier", nsTmplSet.Spec.TierName, "type", tierTemplate.typeName)
-	objs, err := tierTemplate.process(r.Scheme, map[string]string{
+	objs, err := tierTemplate.process(r.Scheme, namespaceParams.forType(tierTemplate.typeName, map[string]string{
 		SpaceName: nsTmplSet.GetName(),
-	}, template.RetainNamespaces)
+	}), template.RetainNamespaces)
 	if err != nil {
 		return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to process template for namespace type '%s'", tierTemplate.typeName)
 	}
@@ -169,27 +177,35 @@ func (r *namespacesManager) ensureNamespaceResource(ctx context.Context, nsTmplS
 	// As a consequence, when the NSTemplateSet is deleted, we explicitly delete the associated namespaces that belong to the same user.
 	// see https://issues.redhat.com/browse/CRT-429
 
-	_, err = r.ApplyToolchainObjects(ctx, objs, labels)
+	_, err = r.applyTemplateObjects(ctx, nsTmplSet, objs, labels)
 	if err != nil {
-		return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to create namespace with type '%s'", tierTemplate.typeName)
+		return r.wrapErrorWithPendingStatusUpdate(ctx, nsTmplSet, err, r.setStatusNamespaceProvisionFailed, "failed to create namespace with type '%s'", tierTemplate.typeName)
 	}
 	logger.Info("namespace provisioned", "namespace", tierTemplate)
 	return nil
 }
 
 // ensureInnerNamespaceResources ensure that the namespace has the expected resources.
-func (r *namespacesManager) ensureInnerNamespaceResources(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate, namespace *corev1.Namespace) error {
+func (r *namespacesManager) ensureInnerNamespaceResources(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate, namespaceParams namespaceParameters, namespace *corev1.Namespace) error {
 	logger := log.FromContext(ctx)
 	logger.Info("ensuring namespace resources", "spacename", nsTmplSet.GetName(), "tier", nsTmplSet.Spec.TierName, "type", tierTemplate.typeName)
 	nsName := namespace.GetName()
-	newObjs, err := tierTemplate.process(r.Scheme, map[string]string{
+	newObjs, err := tierTemplate.process(r.Scheme, namespaceParams.forType(tierTemplate.typeName, map[string]string{
 		SpaceName: nsTmplSet.GetName(),
-	}, template.RetainAllButNamespaces)
+	}), template.RetainAllButNamespaces)
 	if err != nil {
 		return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to process template for namespace '%s'", nsName)
 	}
 
-	if currentRef, exists := namespace.Labels[toolchainv1alpha1.TemplateRefLabelKey]; exists && currentRef != "" && currentRef != tierTemplate.templateRef {
+	// the objects of the current template (rendered with the parameters which were applied) which are not in the new template anymore
+	// are deleted, when the template or the parameters changed
+	currentRef := namespace.Labels[toolchainv1alpha1.TemplateRefLabelKey]
+	currentParams, knownParams := getAppliedNamespaceParameters(namespace)
+	if !knownParams {
+		// the parameters which were applied are unknown, assume that they did not change
+		currentParams = namespaceParams.forType(tierTemplate.typeName, nil)
+	}
+	if currentRef != "" && (currentRef != tierTemplate.templateRef || !maps.Equal(currentParams, namespaceParams.forType(tierTemplate.typeName, nil))) {
 		logger.Info("checking obsolete namespace resources", "spacename", nsTmplSet.GetName(), "tier", nsTmplSet.Spec.TierName, "type", tierTemplate.typeName)
 		if err := r.setStatusUpdatingIfNotProvisioning(ctx, nsTmplSet); err != nil {
 			return err
@@ -198,9 +214,8 @@ func (r *namespacesManager) ensureInnerNamespaceResources(ctx context.Context, n
 		if err != nil {
 			return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusUpdateFailed, err, "failed to retrieve current TierTemplate with name '%s'", currentRef)
 		}
-		currentObjs, err := currentTierTemplate.process(r.Scheme, map[string]string{
-			SpaceName: nsTmplSet.GetName(),
-		}, template.RetainAllButNamespaces)
+		currentParams[SpaceName] = nsTmplSet.GetName()
+		currentObjs, err := currentTierTemplate.process(r.Scheme, currentParams, template.RetainAllButNamespaces)
 		if err != nil {
 			return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusUpdateFailed, err, "failed to process template for TierTemplate with name '%s'", currentRef)
 		}
@@ -213,9 +228,23 @@ func (r *namespacesManager) ensureInnerNamespaceResources(ctx context.Context, n
 		toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
 		toolchainv1alpha1.SpaceLabelKey:    nsTmplSet.GetName(),
 	}
-	if _, err = r.ApplyToolchainObjects(ctx, newObjs, labels); err != nil {
+	waves, err := groupByWave(newObjs)
+	if err != nil {
 		return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to provision namespace '%s' with required resources", nsName)
 	}
+	// apply the objects wave by wave, and wait for the objects which require it to be ready before moving to the next wave
+	for _, wave := range waves {
+		if _, err = r.applyTemplateObjects(ctx, nsTmplSet, wave.objects, labels); err != nil {
+			return r.wrapErrorWithPendingStatusUpdate(ctx, nsTmplSet, err, r.setStatusNamespaceProvisionFailed, "failed to provision namespace '%s' with required resources", nsName)
+		}
+		if err := checkReadiness(ctx, r.Client, wave); err != nil {
+			return r.wrapErrorWithPendingStatusUpdate(ctx, nsTmplSet, err, r.setStatusNamespaceProvisionFailed, "failed to provision namespace '%s' with required resources", nsName)
+		}
+	}
+	// all waves were applied, clear the message about the pending wave (if any)
+	if err := r.clearWavePendingStatus(ctx, nsTmplSet); err != nil {
+		return err
+	}
 
 	if namespace.Labels == nil {
 		namespace.Labels = make(map[string]string)
@@ -224,6 +253,15 @@ func (r *namespacesManager) ensureInnerNamespaceResources(ctx context.Context, n
 	// Adding label indicating that the namespace is up-to-date with TierTemplate
 	namespace.Labels[toolchainv1alpha1.TemplateRefLabelKey] = tierTemplate.templateRef
 	namespace.Labels[toolchainv1alpha1.TierLabelKey] = tierTemplate.tierName
+	// and with the extra parameters of its type
+	if applied := namespaceParams.applied(tierTemplate.typeName); applied != "" {
+		if namespace.Annotations == nil {
+			namespace.Annotations = make(map[string]string)
+		}
+		namespace.Annotations[AppliedNamespaceParametersAnnotationKey] = applied
+	} else {
+		delete(namespace.Annotations, AppliedNamespaceParametersAnnotationKey)
+	}
 	if err := r.Client.Update(ctx, namespace); err != nil {
 		return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to update namespace '%s'", nsName)
 	}
@@ -258,9 +296,12 @@ func (r *namespacesManager) ensureDeleted(ctx context.Context, nsTmplSet *toolch
 	}
 	ns := userNamespaces[0]
 	if !util.IsBeingDeleted(&ns) {
+		if err := r.backupNamespace(ctx, nsTmplSet, &ns); err != nil {
+			return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusTerminatingFailed, err, "failed to back up user namespace '%s'", ns.Name)
+		}
 		log.FromContext(ctx).Info("deleting a user namespace associated with the deleted NSTemplateSet", "namespace", ns.Name)
 		if err := r.Client.Delete(ctx, &ns); err != nil {
-			return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusTerminatingFailed, err, "failed to delete user namespace '%s'", ns.Name)
+			return false, r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.setStatusTerminatingFailed, newObjectError(deleteOperation, &ns, err), "failed to delete user namespace '%s'", ns.Name)
 		}
 		return false, nil // The namespace deletion is triggered so we should stop here. When the namespace is actually deleted the reconcile will be triggered again
 	}
@@ -296,14 +337,14 @@ func fetchNamespacesByOwner(ctx context.Context, cl runtimeclient.Client, spacen
 }
 
 // nextNamespaceToProvisionOrUpdate returns first namespace (from given namespaces) whose status is active and
-// either revision is not set or revision or tier doesn't equal to the current one.
+// either revision is not set or revision, tier or extra parameters don't equal to the current ones.
 // It also returns namespace present in tcNamespaces but not found in given namespaces
-func (r *namespacesManager) nextNamespaceToProvisionOrUpdate(ctx context.Context, tierTemplatesByType []*tierTemplate, namespaces []corev1.Namespace) (*tierTemplate, *corev1.Namespace, bool, error) {
+func (r *namespacesManager) nextNamespaceToProvisionOrUpdate(ctx context.Context, tierTemplatesByType []*tierTemplate, namespaceParams namespaceParameters, namespaces []corev1.Namespace) (*tierTemplate, *corev1.Namespace, bool, error) {
 	for _, nsTemplate := range tierTemplatesByType {
 		namespace, found := findNamespace(namespaces, nsTemplate.typeName)
 		if found {
 			if namespace.Status.Phase == corev1.NamespaceActive {
-				isProvisioned, err := r.isUpToDateAndProvisioned(ctx, &namespace, nsTemplate)
+				isProvisioned, err := r.isUpToDateAndProvisioned(ctx, &namespace, nsTemplate, namespaceParams)
 				if err != nil {
 					return nsTemplate, nil, true, err
 				}
@@ -350,19 +391,20 @@ func getNamespaceName(request reconcile.Request) (string, error) {
 	return namespace, nil
 }
 
-// isUpToDateAndProvisioned checks if the obj has the correct Template Reference Label.
+// isUpToDateAndProvisioned checks if the obj has the correct Template Reference Label and the current extra parameters of its type.
 // If so, it processes the tier template to get the expected roles and rolebindings and then checks if they are actually present in the namespace.
-func (r *namespacesManager) isUpToDateAndProvisioned(ctx context.Context, ns *corev1.Namespace, tierTemplate *tierTemplate) (bool, error) {
+func (r *namespacesManager) isUpToDateAndProvisioned(ctx context.Context, ns *corev1.Namespace, tierTemplate *tierTemplate, namespaceParams namespaceParameters) (bool, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("checking if namespace is up-to-date and provisioned", "namespace_name", ns.Name, "namespace_labels", ns.Labels, "tier_name", tierTemplate.tierName)
 	if ns.GetLabels() != nil &&
 		ns.GetLabels()[toolchainv1alpha1.TierLabelKey] == tierTemplate.tierName &&
-		ns.GetLabels()[toolchainv1alpha1.TemplateRefLabelKey] == tierTemplate.templateRef {
+		ns.GetLabels()[toolchainv1alpha1.TemplateRefLabelKey] == tierTemplate.templateRef &&
+		ns.GetAnnotations()[AppliedNamespaceParametersAnnotationKey] == namespaceParams.applied(tierTemplate.typeName) {
 
-		newObjs, err := tierTemplate.process(r.Scheme, map[string]string{
+		newObjs, err := tierTemplate.process(r.Scheme, namespaceParams.forType(tierTemplate.typeName, map[string]string{
 			Username:  ns.GetLabels()[toolchainv1alpha1.SpaceLabelKey],
 			SpaceName: ns.GetLabels()[toolchainv1alpha1.SpaceLabelKey], // both username and space name are required here, since rolebindings are still created with the USERNAME param.
-		}, template.RetainAllButNamespaces)
+		}), template.RetainAllButNamespaces)
 		if err != nil {
 			return false, err
 		}
@@ -81,7 +81,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 		delete(userNamespaces[1].Labels, toolchainv1alpha1.TemplateRefLabelKey)
 
 		// when
-		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.NoError(t, err)
@@ -96,7 +96,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 		userNamespaces[1].Labels[toolchainv1alpha1.TemplateRefLabelKey] = "basic-stage-123"
 
 		// when
-		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.NoError(t, err)
@@ -111,7 +111,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 		userNamespaces[0].Labels[toolchainv1alpha1.TierLabelKey] = "advanced"
 
 		// when
-		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.NoError(t, err)
@@ -126,7 +126,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 		userNamespaces[1].Labels[toolchainv1alpha1.TemplateRefLabelKey] = "outdated"
 
 		// when
-		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.NoError(t, err)
@@ -143,7 +143,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 		userNamespaces[1].Labels[toolchainv1alpha1.TemplateRefLabelKey] = "basic-stage-abcde21"
 
 		// when
-		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		tierTemplate, userNS, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.NoError(t, err)
@@ -168,7 +168,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 		})
 
 		// when
-		_, _, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		_, _, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.NoError(t, err)
@@ -185,7 +185,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 			return fakeClient.Client.List(ctx, list, opts...)
 		}
 		// when
-		_, _, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		_, _, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.Error(t, err, "mock List error")
@@ -202,7 +202,7 @@ func TestNextNamespaceToProvisionOrUpdate(t *testing.T) {
 			return fakeClient.Client.List(ctx, list, opts...)
 		}
 		// when
-		_, _, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, userNamespaces)
+		_, _, found, err := manager.nextNamespaceToProvisionOrUpdate(ctx, tierTemplates, nil, userNamespaces)
 
 		// then
 		require.Error(t, err, "mock List error")
@@ -1267,7 +1267,7 @@ func TestIsUpToDateAndProvisioned(t *testing.T) {
 		tierTmpl, err := getTierTemplate(ctx, manager.GetHostClusterClient, "basic-dev-abcde11")
 		require.NoError(t, err)
 		// when
-		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, &devNS, tierTmpl)
+		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, &devNS, tierTmpl, nil)
 		//then
 		require.NoError(t, err)
 		require.False(t, isProvisioned)
@@ -1293,7 +1293,7 @@ func TestIsUpToDateAndProvisioned(t *testing.T) {
 		tierTmpl, err := getTierTemplate(ctx, manager.GetHostClusterClient, "advanced-dev-abcde11")
 		require.NoError(t, err)
 		//when
-		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, &devNS, tierTmpl)
+		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, &devNS, tierTmpl, nil)
 		//then
 		require.NoError(t, err)
 		require.False(t, isProvisioned)
@@ -1308,7 +1308,7 @@ func TestIsUpToDateAndProvisioned(t *testing.T) {
 		tierTmpl, err := getTierTemplate(ctx, manager.GetHostClusterClient, "advanced-dev-abcde11")
 		require.NoError(t, err)
 		//when
-		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl)
+		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl, nil)
 		//then
 		require.NoError(t, err)
 		require.False(t, isProvisioned)
@@ -1332,7 +1332,7 @@ func TestIsUpToDateAndProvisioned(t *testing.T) {
 		tierTmpl, err := getTierTemplate(ctx, manager.GetHostClusterClient, "advanced-dev-abcde11")
 		require.NoError(t, err)
 		//when
-		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl)
+		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl, nil)
 		//then
 		require.NoError(t, err)
 		require.False(t, isProvisioned)
@@ -1354,7 +1354,7 @@ func TestIsUpToDateAndProvisioned(t *testing.T) {
 		tierTmpl, err := getTierTemplate(ctx, manager.GetHostClusterClient, "basic-dev-abcde11")
 		require.NoError(t, err)
 		//when
-		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl)
+		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl, nil)
 		//then
 		require.NoError(t, err)
 		require.False(t, isProvisioned)
@@ -1368,7 +1368,7 @@ func TestIsUpToDateAndProvisioned(t *testing.T) {
 		tierTmpl, err := getTierTemplate(ctx, manager.GetHostClusterClient, "basic-dev-abcde11")
 		require.NoError(t, err)
 		//when
-		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl)
+		isProvisioned, err := manager.isUpToDateAndProvisioned(ctx, devNS, tierTmpl, nil)
 		//then
 		require.Error(t, err, "namespace doesn't have space label")
 		require.False(t, isProvisioned)
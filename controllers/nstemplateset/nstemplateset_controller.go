@@ -2,7 +2,9 @@ package nstemplateset
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"strings"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -45,6 +47,7 @@ func NewReconciler(apiClient *APIClient) *Reconciler {
 		spaceRoles: &spaceRolesManager{
 			statusManager: status,
 		},
+		tierRollout: &tierRolloutCounter{},
 	}
 }
 
@@ -56,8 +59,9 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager, allNamespaceCluster r
 	}
 
 	mapToOwnerByLabel := handler.EnqueueRequestsFromMapFunc(commoncontroller.MapToOwnerByLabel("", toolchainv1alpha1.SpaceLabelKey))
+	mapToSharingSpaces := handler.EnqueueRequestsFromMapFunc(mapSharedToSpaces)
 	build := ctrl.NewControllerManagedBy(mgr).
-		For(&toolchainv1alpha1.NSTemplateSet{}, builder.WithPredicates(predicate.Or[runtimeclient.Object](predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		For(&toolchainv1alpha1.NSTemplateSet{}, builder.WithPredicates(predicate.Or[runtimeclient.Object](predicate.GenerationChangedPredicate{}, annotationChangedPredicate{}))).
 		Watches(&corev1.Namespace{}, mapToOwnerByLabel).
 		// we're watching the roles and role bindings explicitly so that the users that accidentally lose access to their namespaces
 		// can get it restored as quickly as possible.
@@ -70,7 +74,10 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager, allNamespaceCluster r
 		// when the users modify the (namespaced) resources from the template. As mentioned above, the notable exception are roles and bindings that can
 		// cause the user to lose the access to the namespace and therefore we DO watch those to reapply the template and restore the access as soon as possible.
 		WatchesRawSource(source.Kind[runtimeclient.Object](allNamespaceCluster.GetCache(), &rbac.Role{}, mapToOwnerByLabel, commonpredicates.LabelsAndGenerationPredicate{})).
-		WatchesRawSource(source.Kind[runtimeclient.Object](allNamespaceCluster.GetCache(), &rbac.RoleBinding{}, mapToOwnerByLabel, commonpredicates.LabelsAndGenerationPredicate{}))
+		WatchesRawSource(source.Kind[runtimeclient.Object](allNamespaceCluster.GetCache(), &rbac.RoleBinding{}, mapToOwnerByLabel, commonpredicates.LabelsAndGenerationPredicate{})).
+		// the shared cluster roles and bindings have no space label, so they are mapped to all the spaces which reference them
+		Watches(&rbac.ClusterRole{}, mapToSharingSpaces, builder.WithPredicates(predicate.NewPredicateFuncs(isShared))).
+		Watches(&rbac.ClusterRoleBinding{}, mapToSharingSpaces, builder.WithPredicates(predicate.NewPredicateFuncs(isShared)))
 
 	r.AllNamespacesClient = allNamespaceCluster.GetClient()
 	r.AvailableAPIGroups = apiGroupList.Groups
@@ -85,6 +92,7 @@ type Reconciler struct {
 	clusterResources *clusterResourcesManager
 	spaceRoles       *spaceRolesManager
 	status           *statusManager
+	tierRollout      *tierRolloutCounter
 }
 
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=nstemplatesets,verbs=get;list;watch;create;update;patch;delete
@@ -93,14 +101,30 @@ type Reconciler struct {
 
 //+kubebuilder:rbac:groups="",resources=namespaces;limitranges,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=namespaces;resourcequotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io;authorization.openshift.io,resources=rolebindings;roles;clusterroles;clusterrolebindings,verbs=*
 //+kubebuilder:rbac:groups=quota.openshift.io,resources=clusterresourcequotas,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=appstudio.redhat.com,resources=environments,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns;taskruns,verbs=get;list;update
 
 // Reconcile reads that state of the cluster for a NSTemplateSet object and makes changes based on the state read
 // and what is in the NSTemplateSet.Spec
 func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	// the annotations maintained by the controller are saved all at once, at the end of the reconcile
+	ctx = withAnnotationChanges(ctx)
+	result, err := r.reconcile(ctx, request)
+	if saveErr := r.status.saveAnnotations(ctx); saveErr != nil {
+		if err != nil {
+			log.FromContext(ctx).Error(saveErr, "failed to save the annotations of the NSTemplateSet")
+			return result, err
+		}
+		return reconcile.Result{}, saveErr
+	}
+	return result, err
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("reconciling NSTemplateSet")
 
@@ -117,18 +141,39 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	if err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("NSTemplateSet not found")
+			setPausedMetric(request.Name, false)
+			nsTemplateSetProvisioningTracker.untrack(request.Name)
+			setQuotaUsageRefreshed(request.Name, time.Time{})
 			return reconcile.Result{}, nil
 		}
 		logger.Error(err, "failed to get NSTemplateSet")
 		return reconcile.Result{}, err
 	}
 	if util.IsBeingDeleted(nsTmplSet) {
+		setPausedMetric(nsTmplSet.Name, false)
 		return r.deleteNSTemplateSet(ctx, nsTmplSet)
 	}
+	// skip everything (but the deletion) while the reconciliation is paused
+	if message, requeueAfter := pausedBy(nsTmplSet); message != "" {
+		logger.Info("NSTemplateSet reconciliation is paused", "reason", message)
+		setPausedMetric(nsTmplSet.Name, true)
+		return reconcile.Result{RequeueAfter: requeueAfter}, r.status.setStatusPaused(ctx, nsTmplSet, message)
+	}
+	setPausedMetric(nsTmplSet.Name, false)
+	// the duration of an update is measured from the time its spec change is first seen, including the time during which it is deferred
+	nsTemplateSetProvisioningTracker.trackGeneration(nsTmplSet)
 	// make sure there's a finalizer
 	if err := r.addFinalizer(ctx, nsTmplSet); err != nil {
 		return reconcile.Result{}, err
 	}
+	// keep the space on its current tier revision while the rollout is halted or too many spaces are being updated
+	if message, err := r.deferTierUpdate(ctx, nsTmplSet); err != nil {
+		logger.Error(err, "failed to check the tier rollout")
+		return reconcile.Result{}, err
+	} else if message != "" {
+		logger.Info("NSTemplateSet tier update is deferred", "reason", message)
+		return reconcile.Result{RequeueAfter: tierUpdateDeferredRequeueDelay}, nil
+	}
 
 	// we proceed with the cluster-scoped resources template, then all namespaces and finally space roles
 	// as we want to be sure that cluster-scoped resources such as quotas are set
@@ -137,6 +182,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	// This is a work-in-progress change to unify how we apply cluster resources and namespaces.
 	// In the end, everything will be applied in one go.
 	if err := r.clusterResources.ensure(ctx, nsTmplSet); err != nil {
+		if requeue, ok := requeueIfPending(ctx, err); ok {
+			return requeue, nil
+		}
 		logger.Error(err, "failed to either provision or update cluster resources")
 		return reconcile.Result{}, err
 	}
@@ -145,6 +193,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	}
 
 	if createdOrUpdated, err := r.namespaces.ensure(ctx, nsTmplSet); err != nil {
+		if requeue, ok := requeueIfPending(ctx, err); ok {
+			return requeue, nil
+		}
 		logger.Error(err, "failed to either provision or update user namespaces")
 		return reconcile.Result{}, err
 	} else if createdOrUpdated {
@@ -161,6 +212,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	}
 
 	if createdOrUpdated, err := r.spaceRoles.ensure(ctx, nsTmplSet); err != nil {
+		if requeue, ok := requeueIfPending(ctx, err); ok {
+			return requeue, nil
+		}
 		logger.Error(err, "failed to either provision or update roles in space")
 		return reconcile.Result{}, err
 	} else if createdOrUpdated {
@@ -170,7 +224,42 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, r.status.setStatusReady(ctx, nsTmplSet)
+	if err := r.status.setStatusReady(ctx, nsTmplSet); err != nil {
+		return reconcile.Result{}, err
+	}
+	// the quota usage and the orphan sweep are refreshed at a bounded rate, hence the requeue
+	requeueAfter, err := r.refreshQuotaUsage(ctx, nsTmplSet)
+	if err != nil {
+		logger.Error(err, "failed to refresh the quota usage")
+		return reconcile.Result{}, err
+	}
+	nextSweep, err := r.sweepOrphans(ctx, nsTmplSet)
+	if err != nil {
+		logger.Error(err, "failed to sweep the orphaned objects")
+		return reconcile.Result{}, err
+	}
+	if requeueAfter == 0 || (nextSweep > 0 && nextSweep < requeueAfter) {
+		requeueAfter = nextSweep
+	}
+	// the objects of a migrated space are imported once the namespaces are provisioned
+	return reconcile.Result{RequeueAfter: requeueAfter}, r.namespaces.migrate(ctx, nsTmplSet)
+}
+
+// requeueIfPending returns a result to requeue the NSTemplateSet (and `true`) if the given error is a *wavePendingError
+// or a *recreatePendingError, ie, if the reconcile was interrupted because some objects of an apply wave are not ready yet,
+// or because an object to recreate is not deleted yet.
+func requeueIfPending(ctx context.Context, err error) (reconcile.Result, bool) {
+	var wavePending *wavePendingError
+	if stderrors.As(err, &wavePending) {
+		log.FromContext(ctx).Info("waiting for the objects of the apply wave to become ready", "wave", wavePending.wave, "not_ready", wavePending.notReady)
+		return reconcile.Result{RequeueAfter: wavePendingRequeueDelay}, true
+	}
+	var recreatePending *recreatePendingError
+	if stderrors.As(err, &recreatePending) {
+		log.FromContext(ctx).Info("waiting for the object to recreate to be deleted", "object_namespace", recreatePending.namespace, "object_name", recreatePending.kind+"/"+recreatePending.name)
+		return reconcile.Result{RequeueAfter: recreatePendingRequeueDelay}, true
+	}
+	return reconcile.Result{}, false
 }
 
 // addFinalizer sets the finalizers for NSTemplateSet
@@ -218,12 +307,27 @@ func (r *Reconciler) deleteNSTemplateSet(ctx context.Context, nsTmplSet *toolcha
 		return reconcile.Result{}, r.status.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.status.setStatusTerminatingFailed, err, "failed to ensure namespace deletion")
 	}
 	if !allDeleted {
-		if time.Since(nsTmplSet.DeletionTimestamp.Time) > 60*time.Second {
-			return reconcile.Result{}, fmt.Errorf("NSTemplateSet deletion has not completed in over 1 minute")
+		config := getNamespaceDeletionConfig(ctx)
+		diagnostics, err := r.namespaces.inspectTerminatingNamespaces(ctx, nsTmplSet, config)
+		if err != nil {
+			return reconcile.Result{}, r.status.wrapErrorWithStatusUpdate(ctx, nsTmplSet, r.status.setStatusTerminatingFailed, err, "failed to inspect the terminating namespaces")
+		}
+		if time.Since(nsTmplSet.DeletionTimestamp.Time) > config.timeout {
+			err := fmt.Errorf("NSTemplateSet deletion has not completed in over %s", config.timeout)
+			if len(diagnostics) > 0 {
+				err = fmt.Errorf("%w: %s", err, strings.Join(diagnostics, "; "))
+			}
+			if err := r.status.setStatusTerminatingFailed(ctx, nsTmplSet, err.Error()); err != nil {
+				logger.Error(err, "status update failed")
+			}
+			return reconcile.Result{}, err
+		}
+		if err := r.status.setStatusTerminatingWithMessage(ctx, nsTmplSet, strings.Join(diagnostics, "; ")); err != nil {
+			return reconcile.Result{}, err
 		}
 		// One or more namespaces may not yet be deleted. We can stop here.
 		return reconcile.Result{
-			RequeueAfter: time.Second,
+			RequeueAfter: namespaceDeletionRequeueDelay(time.Since(nsTmplSet.DeletionTimestamp.Time), config.timeout),
 		}, nil
 	}
 
@@ -253,7 +357,7 @@ Current:
 			}
 		}
 		if err := client.Delete(ctx, currentObj); err != nil && !errors.IsNotFound(err) { // ignore if the object was already deleted
-			return errs.Wrapf(err, "failed to delete obsolete object '%s' of kind '%s' in namespace '%s'", currentObj.GetName(), currentObj.GetObjectKind().GroupVersionKind().Kind, currentObj.GetNamespace())
+			return errs.Wrapf(newObjectError(deleteOperation, currentObj, err), "failed to delete obsolete object '%s' of kind '%s' in namespace '%s'", currentObj.GetName(), currentObj.GetObjectKind().GroupVersionKind().Kind, currentObj.GetNamespace())
 		} else if errors.IsNotFound(err) {
 			continue // continue to the next object since this one was already deleted
 		}
@@ -1251,7 +1251,7 @@ func TestDeleteNSTemplateSet(t *testing.T) {
 		_, err := r.Reconcile(context.TODO(), req)
 
 		// then
-		require.EqualError(t, err, "NSTemplateSet deletion has not completed in over 1 minute")
+		require.EqualError(t, err, "NSTemplateSet deletion has not completed in over 1m0s")
 	})
 
 	t.Run("NSTemplateSet not deleted until namespace is deleted", func(t *testing.T) {
@@ -1327,6 +1327,13 @@ func TestDeleteNSTemplateSet(t *testing.T) {
 	})
 }
 
+// getStoredAnnotationJSON returns the value of the given JSON annotation of the NSTemplateSet stored in the cluster
+func getStoredAnnotationJSON[T any](t *testing.T, cl client.Client, namespace, name, key string) T {
+	nsTmplSet := &toolchainv1alpha1.NSTemplateSet{}
+	require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, nsTmplSet))
+	return getAnnotationJSON[T](context.TODO(), nsTmplSet, key)
+}
+
 func prepareReconcile(t *testing.T, namespaceName, name string, initObjs ...client.Object) (*Reconciler, reconcile.Request, *test.FakeClient) {
 	r, fakeClient := prepareController(t, initObjs...)
 	return r, newReconcileRequest(namespaceName, name), fakeClient
@@ -1369,6 +1376,10 @@ func toStructured(obj client.Object, decoder runtime.Decoder) (client.Object, er
 			crq := &quotav1.ClusterResourceQuota{}
 			_, _, err = decoder.Decode(data, nil, crq)
 			return crq, err
+		case "ClusterRole":
+			cr := &rbacv1.ClusterRole{}
+			_, _, err = decoder.Decode(data, nil, cr)
+			return cr, err
 		case "ClusterRoleBinding":
 			crb := &rbacv1.ClusterRoleBinding{}
 			_, _, err = decoder.Decode(data, nil, crb)
@@ -1382,6 +1393,10 @@ func toStructured(obj client.Object, decoder runtime.Decoder) (client.Object, er
 			idler := &toolchainv1alpha1.Idler{}
 			_, _, err = decoder.Decode(data, nil, idler)
 			return idler, err
+		case "ResourceQuota":
+			rq := &corev1.ResourceQuota{}
+			_, _, err = decoder.Decode(data, nil, rq)
+			return rq, err
 		case "Role":
 			rl := &rbacv1.Role{}
 			_, _, err = decoder.Decode(data, nil, rl)
@@ -1762,6 +1777,9 @@ func prepareTemplateTiers(decoder runtime.Decoder) ([]client.Object, error) {
 			"admin": { // space roles
 				"abcde11": test.CreateTemplate(test.WithObjects(spaceAdmin, spaceAdminRb), test.WithParams(namespace, username)),
 			},
+			"subjectadmin": { // space roles for users and groups
+				"abcde11": test.CreateTemplate(test.WithObjects(spaceAdmin, spaceAdminSubjectRb), test.WithParams(namespace, subjectKind, subjectName)),
+			},
 		},
 		"team": {
 			"clusterresources": {
@@ -1797,6 +1815,32 @@ func prepareTemplateTiers(decoder runtime.Decoder) ([]client.Object, error) {
 				"abcde11": test.CreateTemplate(test.WithObjects(spaceAdmin, spaceAdminRb), test.WithParams(namespace, username)),
 			},
 		},
+		"waves": {
+			"clusterresources": {
+				"abcde11": test.CreateTemplate(test.WithObjects(waitingCrq, secondWaveClusterRb), test.WithParams(spacename, username)),
+			},
+			"dev": {
+				"abcde11": test.CreateTemplate(test.WithObjects(ns, waitingQuota, secondWaveRb), test.WithParams(spacename)),
+			},
+		},
+		"params": {
+			"dev": {
+				"abcde11": test.CreateTemplate(test.WithObjects(ns, storageQuota), test.WithParams(spacename, storageQuotaParam)),
+				"abcde12": test.CreateTemplate(test.WithObjects(ns, storageClassQuota), test.WithParams(spacename, storageQuotaParam, storageClassParam)),
+			},
+		},
+		"boost": {
+			"clusterresources": {
+				"abcde11": test.CreateTemplate(test.WithObjects(advancedCrq, crqQuotaBoost), test.WithParams(spacename, boostCPU)),
+			},
+		},
+		"shared": {
+			"clusterresources": {
+				"abcde11": test.CreateTemplate(test.WithObjects(advancedCrq, sharedClusterRole), test.WithParams(spacename)),
+				"abcde12": test.CreateTemplate(test.WithObjects(advancedCrq), test.WithParams(spacename)),
+				"abcde13": test.CreateTemplate(test.WithObjects(advancedCrq, sharedClusterRole), test.WithParams(spacename)),
+			},
+		},
 		"appstudio": {
 			"clusterresources": {
 				"abcde11": test.CreateTemplate(test.WithObjects(advancedCrq, clusterTektonRb, idlerDev, idlerStage), test.WithParams(spacename, username)),
@@ -1923,6 +1967,21 @@ var (
 	username test.TemplateParam = `
 - name: USERNAME
   value: johnsmith`
+	subjectKind test.TemplateParam = `
+- name: SUBJECT_KIND
+  value: User`
+	subjectName test.TemplateParam = `
+- name: SUBJECT_NAME
+  required: true`
+	boostCPU test.TemplateParam = `
+- name: BOOST_CPU
+  value: "1"`
+	storageQuotaParam test.TemplateParam = `
+- name: STORAGE_QUOTA
+  value: 5Gi`
+	storageClassParam test.TemplateParam = `
+- name: STORAGE_CLASS
+  value: standard`
 
 	advancedCrq test.TemplateObject = `
 - apiVersion: quota.openshift.io/v1
@@ -1955,6 +2014,22 @@ var (
       annotations:
         openshift.io/requester: ${SPACE_NAME}
     labels: null
+  `
+	crqQuotaBoost test.TemplateObject = `
+- apiVersion: quota.openshift.io/v1
+  kind: ClusterResourceQuota
+  metadata:
+    name: quota-boost-for-${SPACE_NAME}
+    annotations:
+      toolchain.dev.openshift.com/feature: quota-boost
+  spec:
+    quota:
+      hard:
+        limits.cpu: ${BOOST_CPU}
+    selector:
+      annotations:
+        openshift.io/requester: ${SPACE_NAME}
+    labels: null
   `
 	crqFeature2 test.TemplateObject = `
 - apiVersion: quota.openshift.io/v1
@@ -2027,6 +2102,107 @@ var (
   subjects:
     - kind: User
       name: ${USERNAME}
+`
+	waitingQuota test.TemplateObject = `
+- apiVersion: v1
+  kind: ResourceQuota
+  metadata:
+    name: compute
+    namespace: ${SPACE_NAME}-NSTYPE
+    annotations:
+      toolchain.dev.openshift.com/wait-for-ready: "true"
+  spec:
+    hard:
+      limits.cpu: 2000m
+`
+
+	waitingCrq test.TemplateObject = `
+- apiVersion: quota.openshift.io/v1
+  kind: ClusterResourceQuota
+  metadata:
+    name: for-${SPACE_NAME}
+    annotations:
+      toolchain.dev.openshift.com/wait-for-ready: "true"
+  spec:
+    quota:
+      hard:
+        limits.cpu: 2000m
+    selector:
+      annotations:
+        openshift.io/requester: ${SPACE_NAME}
+`
+
+	secondWaveClusterRb test.TemplateObject = `
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRoleBinding
+  metadata:
+    name: ${SPACE_NAME}-tekton-view
+    annotations:
+      toolchain.dev.openshift.com/apply-wave: "1"
+  roleRef:
+    apiGroup: rbac.authorization.k8s.io
+    kind: ClusterRole
+    name: tekton-view-for-${SPACE_NAME}
+  subjects:
+    - kind: User
+      name: ${USERNAME}
+`
+
+	storageQuota test.TemplateObject = `
+- apiVersion: v1
+  kind: ResourceQuota
+  metadata:
+    name: storage
+    namespace: ${SPACE_NAME}-NSTYPE
+  spec:
+    hard:
+      requests.storage: ${STORAGE_QUOTA}
+`
+
+	storageClassQuota test.TemplateObject = `
+- apiVersion: v1
+  kind: ResourceQuota
+  metadata:
+    name: storage-${STORAGE_CLASS}
+    namespace: ${SPACE_NAME}-NSTYPE
+  spec:
+    hard:
+      requests.storage: ${STORAGE_QUOTA}
+`
+
+	secondWaveRb test.TemplateObject = `
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: RoleBinding
+  metadata:
+    name: crtadmin-pods
+    namespace: ${SPACE_NAME}-NSTYPE
+    annotations:
+      toolchain.dev.openshift.com/apply-wave: "1"
+  roleRef:
+    apiGroup: rbac.authorization.k8s.io
+    kind: ClusterRole
+    name: edit
+  subjects:
+  - apiGroup: rbac.authorization.k8s.io
+    kind: Group
+    name: crtadmin-users-view
+`
+
+	sharedClusterRole test.TemplateObject = `
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: shared-tekton-view
+    annotations:
+      toolchain.dev.openshift.com/shared: "true"
+  rules:
+  - apiGroups:
+    - tekton.dev
+    resources:
+    - pipelineruns
+    verbs:
+    - get
+    - list
 `
 	idlerDev test.TemplateObject = `
 - apiVersion: toolchain.dev.openshift.com/v1alpha1
@@ -2076,6 +2252,21 @@ var (
   subjects:
     - kind: User
       name: ${USERNAME}
+`
+	spaceAdminSubjectRb test.TemplateObject = `
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: RoleBinding
+  metadata:
+    name: ${SUBJECT_NAME}-space-admin
+    namespace: ${NAMESPACE}
+  roleRef:
+    apiGroup: rbac.authorization.k8s.io
+    kind: Role
+    name: space-admin
+  subjects:
+    - apiGroup: rbac.authorization.k8s.io
+      kind: ${SUBJECT_KIND}
+      name: ${SUBJECT_NAME}
 `
 	spaceViewer test.TemplateObject = `
 - apiVersion: rbac.authorization.k8s.io/v1
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"maps"
+	"slices"
 
 	"fmt"
 
@@ -42,10 +43,11 @@ func getTierTemplate(ctx context.Context, getHostClient host.ClientGetter, templ
 				return nil, err
 			}
 			tierTmpl = &tierTemplate{
-				templateRef: templateRef,
-				tierName:    tmpl.Spec.TierName,
-				typeName:    tmpl.Spec.Type,
-				template:    tmpl.Spec.Template,
+				templateRef:     templateRef,
+				resourceVersion: tmpl.ResourceVersion,
+				tierName:        tmpl.Spec.TierName,
+				typeName:        tmpl.Spec.Type,
+				template:        tmpl.Spec.Template,
 			}
 		} else {
 			return nil, err
@@ -56,10 +58,11 @@ func getTierTemplate(ctx context.Context, getHostClient host.ClientGetter, templ
 			return nil, err
 		}
 		tierTmpl = &tierTemplate{
-			templateRef: templateRef,
-			tierName:    ttrTmpl.Spec.TierName,
-			typeName:    ttrTmpl.Spec.Type,
-			ttr:         ttr,
+			templateRef:     templateRef,
+			resourceVersion: ttr.ResourceVersion,
+			tierName:        ttrTmpl.Spec.TierName,
+			typeName:        ttrTmpl.Spec.Type,
+			ttr:             ttr,
 		}
 	}
 
@@ -88,10 +91,12 @@ func getToolchainTierTemplate(ctx context.Context, getHostClient host.ClientGett
 // tierTemplate contains all data from TierTemplate including its name
 type tierTemplate struct {
 	templateRef string
-	tierName    string
-	typeName    string
-	template    templatev1.Template
-	ttr         *toolchainv1alpha1.TierTemplateRevision
+	// resourceVersion is the version of the TierTemplateRevision (or TierTemplate) from which the objects are rendered
+	resourceVersion string
+	tierName        string
+	typeName        string
+	template        templatev1.Template
+	ttr             *toolchainv1alpha1.TierTemplateRevision
 }
 
 const (
@@ -99,13 +104,35 @@ const (
 	Username         = "USERNAME"
 	SpaceName        = "SPACE_NAME"
 	Namespace        = "NAMESPACE"
+	GroupName        = "GROUPNAME"
+	SubjectKind      = "SUBJECT_KIND"
+	SubjectName      = "SUBJECT_NAME"
 )
 
 // process processes the template inside of the tierTemplate object with the given parameters.
 // it first checks if tiertemplaterevision resource is present, and process its object and
 // if not present then it process the openshift template(current) logic
 // Optionally, it also filters the result to return a subset of the template objects.
+// The rendered objects are cached, keyed by the template (and its resource version), the parameters and the filters.
 func (t *tierTemplate) process(scheme *runtime.Scheme, params map[string]string, filters ...template.FilterFunc) ([]runtimeclient.Object, error) {
+	key, cacheable := newRenderCacheKey(t, params, filters)
+	if cacheable {
+		if objs, found := tierTemplateRenderCache.get(key); found {
+			return objs, nil
+		}
+	}
+	objs, err := t.render(scheme, params, filters...)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable {
+		tierTemplateRenderCache.add(key, objs)
+	}
+	return objs, nil
+}
+
+// render renders the objects of the template, without using the cache
+func (t *tierTemplate) render(scheme *runtime.Scheme, params map[string]string, filters ...template.FilterFunc) ([]runtimeclient.Object, error) {
 	//check if tiertemplaterevision is present then return the runtimeclient object of ttr
 	if t.ttr != nil {
 		return t.processGoTemplate(params, filters...)
@@ -121,7 +148,22 @@ func (t *tierTemplate) process(scheme *runtime.Scheme, params map[string]string,
 
 }
 
-// convert ttr parameters to a map
+// acceptsGroups returns true if the template can be processed for a Group subject (ie, without the `USERNAME` parameter).
+// Templates must declare the `SUBJECT_KIND` parameter, so that a template which binds the role to a User
+// (possibly with a default value of the `USERNAME` parameter) is never processed for a Group.
+func (t *tierTemplate) acceptsGroups() bool {
+	if t.ttr != nil {
+		return slices.ContainsFunc(t.ttr.Spec.Parameters, func(param toolchainv1alpha1.Parameter) bool {
+			return param.Name == SubjectKind
+		})
+	}
+	return slices.ContainsFunc(t.template.Parameters, func(param templatev1.Parameter) bool {
+		return param.Name == SubjectKind
+	})
+}
+
+// convert ttr parameters to a map. The given runtime parameters (which include the extra parameters of the namespace type
+// from the NSTemplateSet, if any - see namespaceParameters.forType) take precedence over the static parameters of the ttr
 func (t *tierTemplate) convertParametersToMap(runtimeParam map[string]string) map[string]string {
 	staticParamMap := map[string]string{}
 	for _, params := range t.ttr.Spec.Parameters {
@@ -161,7 +203,7 @@ func (t *tierTemplate) processGoTemplate(runtimeParams map[string]string, filter
 		unStructObj := &unstructured.Unstructured{}
 		strTemp := string(rawExt.Raw)
 
-		ttrTemp, err := gotemp.New(t.ttr.Name).Option("missingkey=error").Parse(strTemp)
+		ttrTemp, err := gotemp.New(t.ttr.Name).Option("missingkey=error").Funcs(templateFuncs()).Parse(strTemp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse go template for object %d in tierTemplateRevision %q: %w; raw: %q", i, t.ttr.Name, err, strTemp)
 		}
@@ -374,6 +374,49 @@ func TestProcessWithTTRTable(t *testing.T) {
 
 }
 
+func TestAcceptsGroupsWithTTR(t *testing.T) {
+	rbTemplate := func(subject string) string {
+		return `{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "RoleBinding", "metadata": {"name": "admin", "namespace": "{{.NAMESPACE}}"}, "subjects": [` +
+			subject + `], "roleRef": {"kind": "ClusterRole", "name": "admin", "apiGroup": "rbac.authorization.k8s.io"}}`
+	}
+	tests := []struct {
+		name     string
+		template string
+		params   []toolchainv1alpha1.Parameter
+		expected bool
+	}{
+		{
+			name:     "declares the SUBJECT_KIND parameter",
+			template: rbTemplate(`{"kind": "{{.SUBJECT_KIND}}", "name": "{{.SUBJECT_NAME}}"}`),
+			params:   []toolchainv1alpha1.Parameter{{Name: "SUBJECT_KIND", Value: "User"}},
+			expected: true,
+		},
+		{
+			name:     "refers to the subject parameters without declaring the SUBJECT_KIND parameter",
+			template: rbTemplate(`{"kind": "{{.SUBJECT_KIND}}", "name": "{{.SUBJECT_NAME}}"}`),
+			expected: false,
+		},
+		{
+			name:     "refers to the USERNAME parameter with a default value",
+			template: rbTemplate(`{"kind": "User", "name": "{{.USERNAME}}"}`),
+			params:   []toolchainv1alpha1.Parameter{{Name: "USERNAME", Value: "admin"}},
+			expected: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			tierTemplate := createTestTierTemplate(createTestTTR("test-ttr", []string{tc.template}, tc.params))
+
+			// when
+			accepted := tierTemplate.acceptsGroups()
+
+			// then
+			assert.Equal(t, tc.expected, accepted)
+		})
+	}
+}
+
 func TestConvertParametersToMap(t *testing.T) {
 	// given
 	ttr := &toolchainv1alpha1.TierTemplateRevision{
@@ -406,6 +449,32 @@ func TestConvertParametersToMap(t *testing.T) {
 
 	assert.Equal(t, expected, result)
 }
+func TestConvertParametersToMapWithNamespaceParameters(t *testing.T) {
+	// given
+	ttr := &toolchainv1alpha1.TierTemplateRevision{
+		Spec: toolchainv1alpha1.TierTemplateRevisionSpec{
+			Parameters: []toolchainv1alpha1.Parameter{
+				{Name: "STORAGE_QUOTA", Value: "5Gi"},
+				{Name: "REPLICAS", Value: "1"},
+			},
+		},
+	}
+	tierTemplate := createTestTierTemplate(ttr)
+	namespaceParams := namespaceParameters{
+		"dev": {"STORAGE_QUOTA": "20Gi", SpaceName: "janedoe"},
+	}
+
+	// when
+	result := tierTemplate.convertParametersToMap(namespaceParams.forType("dev", map[string]string{SpaceName: "johnsmith"}))
+
+	// then
+	assert.Equal(t, map[string]string{
+		"STORAGE_QUOTA": "20Gi",      // the parameter of the namespace type overrides the static param
+		"REPLICAS":      "1",         // static param
+		SpaceName:       "johnsmith", // the runtime param overrides the parameter of the namespace type
+	}, result)
+}
+
 func TestGetTierTemplate(t *testing.T) {
 
 	// given
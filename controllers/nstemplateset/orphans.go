@@ -0,0 +1,211 @@
+package nstemplateset
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/utils/memberconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// OrphanCleanupAnnotationKey is the annotation on the MemberOperatorConfig which enables the periodic sweep of the orphaned objects of the spaces,
+	// ie, the objects with the space and provider labels which are not produced by the current templates of the space (anymore).
+	// The value is either `dry-run` (the orphans are only reported) or `delete` (the orphans are deleted). The sweep is disabled when the annotation is not set.
+	OrphanCleanupAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "orphan-cleanup"
+	// OrphanCleanupPeriodAnnotationKey is the annotation on the MemberOperatorConfig with the minimum duration between two sweeps of a space (default: 1h)
+	OrphanCleanupPeriodAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "orphan-cleanup-period"
+	// OrphanCleanupStatusAnnotationKey is the annotation set on the NSTemplateSet with the (JSON-encoded) result of the last sweep
+	OrphanCleanupStatusAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "orphan-cleanup-status"
+
+	OrphanCleanupDryRun = "dry-run"
+	OrphanCleanupDelete = "delete"
+
+	defaultOrphanCleanupPeriod = time.Hour
+	// maxReportedOrphans is the maximum number of orphans listed in the status of the sweep
+	maxReportedOrphans = 20
+)
+
+// OrphanCleanupStatus is the result of the last sweep of the orphaned objects of a space
+type OrphanCleanupStatus struct {
+	DryRun bool `json:"dryRun,omitempty"`
+	// Orphans is the list of the orphaned objects which were found (and deleted, unless in dry-run mode)
+	Orphans []OrphanObject `json:"orphans,omitempty"`
+	// Total is the number of orphaned objects which were found, including the ones which are not listed
+	Total         int         `json:"total"`
+	LastSweepTime metav1.Time `json:"lastSweepTime"`
+}
+
+// OrphanObject is an object with the labels of the space which is not produced by the current templates of the space
+type OrphanObject struct {
+	GVK       string `json:"gvk"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+type templateObjectKey struct {
+	groupKind schema.GroupKind
+	namespace string
+	name      string
+}
+
+// sweepOrphans looks for the objects of the space which are not produced by the current templates (cluster resources, namespaces
+// and space roles), and deletes them unless in dry-run mode. Only the kinds of objects which appear in the current templates are considered.
+// The sweep is done at most once per configured period, and the returned duration is the time after which the next sweep is due
+// (or zero if the sweep is disabled).
+func (r *Reconciler) sweepOrphans(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) (time.Duration, error) {
+	mode := memberconfig.Annotation(OrphanCleanupAnnotationKey)
+	switch mode {
+	case "":
+		return 0, nil
+	case OrphanCleanupDryRun, OrphanCleanupDelete:
+	default:
+		log.FromContext(ctx).Info("ignoring invalid orphan cleanup mode in MemberOperatorConfig annotation", "annotation", OrphanCleanupAnnotationKey, "value", mode)
+		return 0, nil
+	}
+	period := memberOperatorConfigDuration(ctx, OrphanCleanupPeriodAnnotationKey, defaultOrphanCleanupPeriod)
+	if elapsed := time.Since(getAnnotationJSON[OrphanCleanupStatus](ctx, nsTmplSet, OrphanCleanupStatusAnnotationKey).LastSweepTime.Time); elapsed < period {
+		return period - elapsed, nil
+	}
+
+	orphans, err := r.findOrphans(ctx, nsTmplSet)
+	if err != nil {
+		return 0, errs.Wrap(err, "failed to look for the orphaned objects of the space")
+	}
+	status := OrphanCleanupStatus{
+		DryRun:        mode == OrphanCleanupDryRun,
+		Total:         len(orphans),
+		LastSweepTime: metav1.Now(),
+	}
+	for _, orphan := range orphans {
+		gvk := orphan.GroupVersionKind()
+		logger := log.FromContext(ctx).WithValues("gvk", gvk.String(), "namespace", orphan.GetNamespace(), "name", orphan.GetName())
+		if status.DryRun {
+			logger.Info("found orphaned object (dry-run)")
+		} else {
+			logger.Info("deleting orphaned object")
+			if err := r.Client.Delete(ctx, orphan); err != nil && !errors.IsNotFound(err) {
+				return 0, errs.Wrapf(newObjectError(deleteOperation, orphan, err), "failed to delete the orphaned %s '%s'", gvk.Kind, orphan.GetName())
+			}
+		}
+		if len(status.Orphans) < maxReportedOrphans {
+			status.Orphans = append(status.Orphans, OrphanObject{
+				GVK:       gvk.String(),
+				Namespace: orphan.GetNamespace(),
+				Name:      orphan.GetName(),
+			})
+		}
+	}
+	if err := r.status.setAnnotationJSON(ctx, nsTmplSet, OrphanCleanupStatusAnnotationKey, status); err != nil {
+		return 0, errs.Wrap(err, "unable to set the orphan cleanup status")
+	}
+	return period, nil
+}
+
+// findOrphans returns the objects with the labels of the space whose kind appears in the current templates of the space,
+// but which are not produced by these templates
+func (r *Reconciler) findOrphans(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) ([]*unstructured.Unstructured, error) {
+	var templateObjs []runtimeclient.Object
+	_, features := featureAnnotationNeedsUpdate(nsTmplSet)
+	if nsTmplSet.Spec.ClusterResources != nil {
+		_, objs, err := r.clusterResources.processTierTemplate(ctx, nsTmplSet.Spec.ClusterResources, nsTmplSet.Name, features)
+		if err != nil {
+			return nil, err
+		}
+		templateObjs = append(templateObjs, objs...)
+	}
+	tierTemplates, err := r.namespaces.getTierTemplatesForAllNamespaces(ctx, nsTmplSet)
+	if err != nil {
+		return nil, err
+	}
+	namespaceParams, err := getNamespaceParameters(nsTmplSet)
+	if err != nil {
+		return nil, err
+	}
+	for _, tierTemplate := range tierTemplates {
+		objs, err := tierTemplate.process(r.Scheme, namespaceParams.forType(tierTemplate.typeName, map[string]string{SpaceName: nsTmplSet.Name}), template.RetainAllButNamespaces)
+		if err != nil {
+			return nil, err
+		}
+		templateObjs = append(templateObjs, objs...)
+	}
+	namespaces, err := fetchNamespacesByOwner(ctx, r.Client, nsTmplSet.Name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range namespaces {
+		objs, err := r.spaceRoles.getSpaceRolesObjects(ctx, &namespaces[i], nsTmplSet.Spec.SpaceRoles, featureParams(features))
+		if err != nil {
+			return nil, err
+		}
+		templateObjs = append(templateObjs, objs...)
+	}
+
+	expected := map[templateObjectKey]bool{}
+	// the kinds of the objects in the templates, and whether they are namespaced
+	kinds := map[schema.GroupVersionKind]bool{}
+	for _, obj := range templateObjs {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		expected[templateObjectKey{groupKind: gvk.GroupKind(), namespace: obj.GetNamespace(), name: obj.GetName()}] = true
+		kinds[gvk] = kinds[gvk] || obj.GetNamespace() != ""
+	}
+
+	var orphans []*unstructured.Unstructured
+	for gvk, namespaced := range kinds {
+		listNamespaces := []string{""}
+		if namespaced {
+			listNamespaces = listNamespaces[:0]
+			for _, ns := range namespaces {
+				listNamespaces = append(listNamespaces, ns.Name)
+			}
+		}
+		for _, namespace := range listNamespaces {
+			// listed as unstructured objects, so that the (namespace-scoped) cache of the client is bypassed
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+			if err := r.Client.List(ctx, list, runtimeclient.InNamespace(namespace), runtimeclient.MatchingLabels{
+				toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
+				toolchainv1alpha1.SpaceLabelKey:    nsTmplSet.Name,
+			}); err != nil {
+				if meta.IsNoMatchError(err) {
+					continue // eg, an optional resource whose API group is not available
+				}
+				return nil, errs.Wrapf(err, "unable to list the %s objects of the space", gvk.Kind)
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				if obj.GetDeletionTimestamp() != nil || len(obj.GetOwnerReferences()) > 0 || isShared(obj) {
+					continue // being deleted, or managed by another controller
+				}
+				if !expected[templateObjectKey{groupKind: gvk.GroupKind(), namespace: obj.GetNamespace(), name: obj.GetName()}] {
+					obj.SetGroupVersionKind(gvk)
+					orphans = append(orphans, obj)
+				}
+			}
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].GetKind() != orphans[j].GetKind() {
+			return orphans[i].GetKind() < orphans[j].GetKind()
+		}
+		if orphans[i].GetNamespace() != orphans[j].GetNamespace() {
+			return orphans[i].GetNamespace() < orphans[j].GetNamespace()
+		}
+		return orphans[i].GetName() < orphans[j].GetName()
+	})
+	return orphans, nil
+}
@@ -0,0 +1,139 @@
+package nstemplateset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSweepOrphans(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	// provisioned space with the `dev` namespace and the `admin` space role, along with:
+	// - a RoleBinding and a Role of the space which are not in the templates anymore (the orphans)
+	// - a RoleBinding without the labels of the space, and another one owned by another object (not to be deleted)
+	provisionedSpace := func() []runtimeclient.Object {
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"),
+			withSpaceRoles(map[string][]string{"basic-admin-abcde11": {spacename}}))
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		devNS.Annotations = map[string]string{
+			toolchainv1alpha1.LastAppliedSpaceRolesAnnotationKey: `[{"templateRef":"basic-admin-abcde11","usernames":["johnsmith"]}]`,
+		}
+		ownedRb := newRoleBinding(devNS.Name, "owned", spacename)
+		ownedRb.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "abc"}}
+		return []runtimeclient.Object{
+			nsTmplSet, devNS,
+			newRoleBinding(devNS.Name, "crtadmin-pods", spacename),
+			newRoleBinding(devNS.Name, spacename+"-space-admin", spacename),
+			newRole(devNS.Name, "space-admin", spacename),
+			newRoleBinding(devNS.Name, "obsolete-rb", spacename),
+			newRole(devNS.Name, "obsolete-role", spacename),
+			&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: devNS.Name, Name: "user-created"}},
+			ownedRb,
+		}
+	}
+	config := func(t *testing.T, annotations map[string]string) *toolchainv1alpha1.MemberOperatorConfig {
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t)
+		cfg.Annotations = annotations
+		return cfg
+	}
+	orphans := []OrphanObject{
+		{GVK: "rbac.authorization.k8s.io/v1, Kind=Role", Namespace: spacename + "-dev", Name: "obsolete-role"},
+		{GVK: "rbac.authorization.k8s.io/v1, Kind=RoleBinding", Namespace: spacename + "-dev", Name: "obsolete-rb"},
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename,
+			append(provisionedSpace(), config(t, map[string]string{OrphanCleanupAnnotationKey: OrphanCleanupDryRun}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, result.RequeueAfter)
+		status := getStoredAnnotationJSON[OrphanCleanupStatus](t, fakeClient, namespaceName, spacename, OrphanCleanupStatusAnnotationKey)
+		assert.True(t, status.DryRun)
+		assert.Equal(t, 2, status.Total)
+		assert.Equal(t, orphans, status.Orphans)
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).
+			HasResource("obsolete-rb", &rbacv1.RoleBinding{}).
+			HasResource("obsolete-role", &rbacv1.Role{})
+
+		t.Run("not repeated before the end of the period", func(t *testing.T) {
+			// when
+			result, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.LessOrEqual(t, result.RequeueAfter, time.Hour)
+			assert.Positive(t, result.RequeueAfter)
+			assert.Equal(t, status, getStoredAnnotationJSON[OrphanCleanupStatus](t, fakeClient, namespaceName, spacename, OrphanCleanupStatusAnnotationKey))
+		})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename,
+			append(provisionedSpace(), config(t, map[string]string{
+				OrphanCleanupAnnotationKey:       OrphanCleanupDelete,
+				OrphanCleanupPeriodAnnotationKey: "30m",
+			}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Minute, result.RequeueAfter)
+		status := getStoredAnnotationJSON[OrphanCleanupStatus](t, fakeClient, namespaceName, spacename, OrphanCleanupStatusAnnotationKey)
+		assert.False(t, status.DryRun)
+		assert.Equal(t, orphans, status.Orphans)
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).
+			HasNoResource("obsolete-rb", &rbacv1.RoleBinding{}).
+			HasNoResource("obsolete-role", &rbacv1.Role{}).
+			HasResource("crtadmin-pods", &rbacv1.RoleBinding{}).
+			HasResource(spacename+"-space-admin", &rbacv1.RoleBinding{}).
+			HasResource("space-admin", &rbacv1.Role{}).
+			HasResource("owned", &rbacv1.RoleBinding{})
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: spacename + "-dev", Name: "user-created"}, &rbacv1.RoleBinding{}))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename,
+			append(provisionedSpace(), config(t, map[string]string{OrphanCleanupAnnotationKey: "unknown"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, result.RequeueAfter)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasNoAnnotation(OrphanCleanupStatusAnnotationKey)
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).HasResource("obsolete-rb", &rbacv1.RoleBinding{})
+	})
+}
@@ -0,0 +1,76 @@
+package nstemplateset
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/metrics"
+	"github.com/codeready-toolchain/member-operator/pkg/utils/memberconfig"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PausedAnnotationKey is the annotation set on a NSTemplateSet (with the `true` value) to pause its reconciliation,
+	// eg, to prevent the operator from reverting manual fixes in the space during an incident.
+	// Only the deletion of the NSTemplateSet is still handled while it is paused.
+	PausedAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "paused"
+	// NSTemplateSetsPausedAnnotationKey is the annotation set on the MemberOperatorConfig (with the `true` value)
+	// to pause the reconciliation of all the NSTemplateSets of the cluster, eg, to freeze a tier rollout.
+	NSTemplateSetsPausedAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "nstemplatesets-paused"
+
+	// NSTemplateSetPausedReason is the reason of the Ready condition of a NSTemplateSet whose reconciliation is paused
+	NSTemplateSetPausedReason = "Paused"
+
+	// pausedRequeueDelay is the delay after which a NSTemplateSet paused via the MemberOperatorConfig is reconciled again.
+	// The MemberOperatorConfig is not watched by the controller, so this is how the NSTemplateSets resume once the pause is lifted.
+	pausedRequeueDelay = time.Minute
+)
+
+// pausedBy returns a non-empty message describing why the reconciliation of the given NSTemplateSet is paused, if it is,
+// along with the delay after which the NSTemplateSet should be reconciled again to check if it is still paused
+// (zero when the change of the pause triggers a reconcile by itself).
+func pausedBy(nsTmplSet *toolchainv1alpha1.NSTemplateSet) (string, time.Duration) {
+	if nsTmplSet.GetAnnotations()[PausedAnnotationKey] == "true" {
+		return fmt.Sprintf("reconciliation is paused via the '%s' annotation on the NSTemplateSet", PausedAnnotationKey), 0
+	}
+	if memberconfig.Annotation(NSTemplateSetsPausedAnnotationKey) == "true" {
+		return fmt.Sprintf("reconciliation of all NSTemplateSets is paused via the '%s' annotation on the MemberOperatorConfig", NSTemplateSetsPausedAnnotationKey), pausedRequeueDelay
+	}
+	return "", 0
+}
+
+// pausedSpaces are the names of the spaces whose reconciliation is paused (to maintain the gauge of the paused NSTemplateSets).
+// The paused spaces themselves are listed via the reason of their Ready condition (and in the logs).
+var pausedSpaces = struct {
+	mu    sync.Mutex
+	names map[string]struct{}
+}{
+	names: map[string]struct{}{},
+}
+
+// setPausedMetric records whether the given space is paused, and updates the gauge of the paused NSTemplateSets accordingly
+func setPausedMetric(spacename string, paused bool) {
+	pausedSpaces.mu.Lock()
+	defer pausedSpaces.mu.Unlock()
+	if paused {
+		pausedSpaces.names[spacename] = struct{}{}
+	} else {
+		delete(pausedSpaces.names, spacename)
+	}
+	metrics.NSTemplateSetsPausedGauge.Set(float64(len(pausedSpaces.names)))
+}
+
+func (r *statusManager) setStatusPaused(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, message string) error {
+	return r.updateStatusConditions(
+		ctx,
+		nsTmplSet,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  NSTemplateSetPausedReason,
+			Message: message,
+		})
+}
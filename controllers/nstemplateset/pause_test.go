@@ -0,0 +1,163 @@
+package nstemplateset
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/metrics"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPausedNSTemplateSet(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	t.Run("paused via annotation", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"), withAnnotation(PausedAnnotationKey, "true"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, res.RequeueAfter) // the change of the annotation triggers a reconcile
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  NSTemplateSetPausedReason,
+				Message: "reconciliation is paused via the 'toolchain.dev.openshift.com/paused' annotation on the NSTemplateSet",
+			})
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).DoesNotExist()
+		assert.InDelta(t, float64(1), promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge), 0.01)
+
+		t.Run("resumed when annotation is removed", func(t *testing.T) {
+			// given
+			actual := &toolchainv1alpha1.NSTemplateSet{}
+			require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, actual))
+			delete(actual.Annotations, PausedAnnotationKey)
+			require.NoError(t, fakeClient.Update(context.TODO(), actual))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+				HasConditions(Provisioning())
+			AssertThatNamespace(t, spacename+"-dev", fakeClient).HasLabel(toolchainv1alpha1.SpaceLabelKey, spacename)
+			assert.Zero(t, promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge))
+		})
+	})
+
+	t.Run("paused via MemberOperatorConfig", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		config := commonconfig.NewMemberOperatorConfigWithReset(t)
+		config.Annotations = map[string]string{
+			NSTemplateSetsPausedAnnotationKey: "true",
+		}
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, config)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, pausedRequeueDelay, res.RequeueAfter) // the MemberOperatorConfig is not watched
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  NSTemplateSetPausedReason,
+				Message: "reconciliation of all NSTemplateSets is paused via the 'toolchain.dev.openshift.com/nstemplatesets-paused' annotation on the MemberOperatorConfig",
+			})
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).DoesNotExist()
+		assert.InDelta(t, float64(1), promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge), 0.01)
+
+		t.Run("resumed when annotation is removed from the MemberOperatorConfig", func(t *testing.T) {
+			// given
+			delete(config.Annotations, NSTemplateSetsPausedAnnotationKey)
+			require.NoError(t, fakeClient.Update(context.TODO(), config))
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+				HasConditions(Provisioning())
+			AssertThatNamespace(t, spacename+"-dev", fakeClient).HasLabel(toolchainv1alpha1.SpaceLabelKey, spacename)
+			assert.Zero(t, promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge))
+		})
+	})
+
+	t.Run("deletion is not paused", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"), withDeletionTs(), withAnnotation(PausedAnnotationKey, "true"))
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, devNS)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(Terminating())
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).DoesNotExist()
+		assert.Zero(t, promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge))
+	})
+}
+
+func TestSetPausedMetric(t *testing.T) {
+	// given
+	metrics.Reset()
+
+	// when
+	setPausedMetric("johnsmith", true)
+	setPausedMetric("janedoe", true)
+	setPausedMetric("johnsmith", true)
+
+	// then
+	assert.InDelta(t, float64(2), promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge), 0.01)
+
+	t.Run("resumed spaces are not counted anymore", func(t *testing.T) {
+		// when
+		setPausedMetric("johnsmith", false)
+		setPausedMetric("janedoe", false)
+		setPausedMetric("unknown", false)
+
+		// then
+		assert.Zero(t, promtestutil.ToFloat64(metrics.NSTemplateSetsPausedGauge))
+	})
+}
+
+func withAnnotation(key, value string) nsTmplSetOption {
+	return func(nsTmplSet *toolchainv1alpha1.NSTemplateSet) {
+		if nsTmplSet.Annotations == nil {
+			nsTmplSet.Annotations = map[string]string{}
+		}
+		nsTmplSet.Annotations[key] = value
+	}
+}
@@ -0,0 +1,199 @@
+package nstemplateset
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/metrics"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// the phases of the provisioning (or update) of a NSTemplateSet, used as the `phase` label of the duration metric
+const (
+	clusterResourcesPhase = "cluster_resources"
+	namespacesPhase       = "namespaces"
+	spaceRolesPhase       = "space_roles"
+	readyPhase            = "ready"
+
+	provisionOperation = "provision"
+	updateOperation    = "update"
+)
+
+// failureReasons are the reasons of the Ready condition which are counted as failures
+var failureReasons = []string{
+	toolchainv1alpha1.NSTemplateSetUnableToProvisionReason,
+	toolchainv1alpha1.NSTemplateSetUnableToProvisionNamespaceReason,
+	toolchainv1alpha1.NSTemplateSetUnableToProvisionClusterResourcesReason,
+	toolchainv1alpha1.NSTemplateSetUnableToProvisionSpaceRolesReason,
+	toolchainv1alpha1.NSTemplateSetUpdateFailedReason,
+	toolchainv1alpha1.NSTemplateSetTerminatingFailedReason,
+}
+
+// provisioningTracker keeps track of the Ready reason of each NSTemplateSet (to maintain the gauge of the NSTemplateSets per reason),
+// of the time at which the controller first saw the current generation of each NSTemplateSet (ie, when its spec changed),
+// and of the phases whose duration was already observed for the ongoing provisioning or update of each NSTemplateSet
+// (since a phase completes again on every reconcile until the NSTemplateSet is ready)
+type provisioningTracker struct {
+	mu           sync.Mutex
+	readyReasons map[string]string
+	reasonCounts map[string]int
+	generations  map[string]observedGeneration
+	phases       map[string]observedPhases
+}
+
+// observedGeneration is a generation of a NSTemplateSet, along with the time at which the controller first saw it
+// and whether it was fully applied since then
+type observedGeneration struct {
+	generation int64
+	seen       time.Time
+	applied    bool
+}
+
+type observedPhases struct {
+	start  time.Time
+	phases []string
+}
+
+var nsTemplateSetProvisioningTracker = newProvisioningTracker()
+
+func newProvisioningTracker() *provisioningTracker {
+	return &provisioningTracker{
+		readyReasons: map[string]string{},
+		reasonCounts: map[string]int{},
+		generations:  map[string]observedGeneration{},
+		phases:       map[string]observedPhases{},
+	}
+}
+
+// trackGeneration records the time at which the current generation of the given NSTemplateSet is seen for the first time,
+// so that the duration of its update also covers the time during which the update was deferred (see deferTierUpdate).
+// Once the generation was applied, the time is reset on every reconcile until an update is in progress again, since
+// an update without a spec change (eg, a change of the feature toggles) starts when it is reconciled.
+func (t *provisioningTracker) trackGeneration(nsTmplSet *toolchainv1alpha1.NSTemplateSet) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	observed, found := t.generations[nsTmplSet.Name]
+	if found && observed.generation == nsTmplSet.Generation && (!observed.applied || isUpdating(nsTmplSet)) {
+		return
+	}
+	t.generations[nsTmplSet.Name] = observedGeneration{
+		generation: nsTmplSet.Generation,
+		seen:       time.Now(),
+		applied:    found && observed.generation == nsTmplSet.Generation,
+	}
+}
+
+// generationApplied records that the current generation of the given NSTemplateSet was fully applied
+func (t *provisioningTracker) generationApplied(nsTmplSet *toolchainv1alpha1.NSTemplateSet) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if observed, found := t.generations[nsTmplSet.Name]; found && observed.generation == nsTmplSet.Generation {
+		observed.applied = true
+		t.generations[nsTmplSet.Name] = observed
+	}
+}
+
+// trackReadyReason records the reason of the Ready condition of the given NSTemplateSet, and updates the gauge accordingly
+func (t *provisioningTracker) trackReadyReason(spacename, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous, found := t.readyReasons[spacename]
+	if found && previous == reason {
+		return
+	}
+	if found {
+		t.reasonCounts[previous]--
+		metrics.NSTemplateSetsReadyReasonGaugeVec.WithLabelValues(previous).Set(float64(t.reasonCounts[previous]))
+	}
+	t.readyReasons[spacename] = reason
+	t.reasonCounts[reason]++
+	metrics.NSTemplateSetsReadyReasonGaugeVec.WithLabelValues(reason).Set(float64(t.reasonCounts[reason]))
+}
+
+// untrack forgets about the given NSTemplateSet (once it was deleted)
+func (t *provisioningTracker) untrack(spacename string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.phases, spacename)
+	delete(t.generations, spacename)
+	if previous, found := t.readyReasons[spacename]; found {
+		delete(t.readyReasons, spacename)
+		t.reasonCounts[previous]--
+		metrics.NSTemplateSetsReadyReasonGaugeVec.WithLabelValues(previous).Set(float64(t.reasonCounts[previous]))
+	}
+}
+
+// observePhase observes the duration of the given phase of the ongoing provisioning or update of the given NSTemplateSet,
+// unless it was already observed. Nothing is observed if the NSTemplateSet is not being provisioned or updated.
+func (t *provisioningTracker) observePhase(nsTmplSet *toolchainv1alpha1.NSTemplateSet, phase string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	operation, start, inProgress := t.provisioningStart(nsTmplSet)
+	if !inProgress {
+		return
+	}
+	observed := t.phases[nsTmplSet.Name]
+	if !observed.start.Equal(start) {
+		observed = observedPhases{start: start}
+	}
+	if slices.Contains(observed.phases, phase) {
+		return
+	}
+	metrics.NSTemplateSetProvisioningDurationHistogramVec.WithLabelValues(nsTmplSet.Spec.TierName, operation, phase).Observe(time.Since(start).Seconds())
+	if phase == readyPhase {
+		delete(t.phases, nsTmplSet.Name)
+		return
+	}
+	observed.phases = append(observed.phases, phase)
+	t.phases[nsTmplSet.Name] = observed
+}
+
+// provisioningStart returns the ongoing operation on the given NSTemplateSet (provision or update) and the time at which it started,
+// ie, the creation of the NSTemplateSet or the time at which its current generation was first seen (see trackGeneration).
+// It returns `false` if the NSTemplateSet is neither being provisioned nor updated.
+// The caller must hold the lock of the tracker.
+func (t *provisioningTracker) provisioningStart(nsTmplSet *toolchainv1alpha1.NSTemplateSet) (string, time.Time, bool) {
+	readyCondition, found := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	if !found {
+		return provisionOperation, nsTmplSet.CreationTimestamp.Time, true
+	}
+	if readyCondition.Status == corev1.ConditionTrue {
+		return "", time.Time{}, false
+	}
+	switch readyCondition.Reason {
+	case toolchainv1alpha1.NSTemplateSetUpdatingReason, toolchainv1alpha1.NSTemplateSetUpdateFailedReason:
+		observed, found := t.generations[nsTmplSet.Name]
+		if !found || observed.generation != nsTmplSet.Generation {
+			// the generation was not seen yet (eg, the operator restarted during the update)
+			observed = observedGeneration{generation: nsTmplSet.Generation, seen: time.Now()}
+			t.generations[nsTmplSet.Name] = observed
+		}
+		return updateOperation, observed.seen, true
+	case toolchainv1alpha1.NSTemplateSetProvisioningReason,
+		toolchainv1alpha1.NSTemplateSetUnableToProvisionReason,
+		toolchainv1alpha1.NSTemplateSetUnableToProvisionNamespaceReason,
+		toolchainv1alpha1.NSTemplateSetUnableToProvisionClusterResourcesReason,
+		toolchainv1alpha1.NSTemplateSetUnableToProvisionSpaceRolesReason:
+		return provisionOperation, nsTmplSet.CreationTimestamp.Time, true
+	default:
+		return "", time.Time{}, false
+	}
+}
+
+// isUpdating returns true if the Ready condition of the given NSTemplateSet is about an ongoing (or failed) update
+func isUpdating(nsTmplSet *toolchainv1alpha1.NSTemplateSet) bool {
+	readyCondition, found := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	return found && (readyCondition.Reason == toolchainv1alpha1.NSTemplateSetUpdatingReason || readyCondition.Reason == toolchainv1alpha1.NSTemplateSetUpdateFailedReason)
+}
+
+// recordStatusTransition updates the metrics after the given Ready condition was set on the NSTemplateSet:
+// the gauge of the NSTemplateSets per reason and, if the condition changed, the counter of failures
+func recordStatusTransition(nsTmplSet *toolchainv1alpha1.NSTemplateSet, readyCondition toolchainv1alpha1.Condition, changed bool) {
+	nsTemplateSetProvisioningTracker.trackReadyReason(nsTmplSet.Name, readyCondition.Reason)
+	if changed && slices.Contains(failureReasons, readyCondition.Reason) {
+		metrics.NSTemplateSetFailuresCounterVec.WithLabelValues(readyCondition.Reason).Inc()
+	}
+}
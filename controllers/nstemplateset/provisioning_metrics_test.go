@@ -0,0 +1,313 @@
+package nstemplateset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/metrics"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvisioningStart(t *testing.T) {
+	// given
+	created := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	transitioned := metav1.NewTime(time.Now().Add(-10 * time.Second).Truncate(time.Second))
+	seen := time.Now().Add(-30 * time.Second)
+	withCondition := func(reason string, status corev1.ConditionStatus) *toolchainv1alpha1.NSTemplateSet {
+		nsTmplSet := newNSTmplSet("toolchain-member", "johnsmith", "basic")
+		nsTmplSet.CreationTimestamp = created
+		nsTmplSet.Generation = 2
+		if reason != "" {
+			nsTmplSet.Status.Conditions = []toolchainv1alpha1.Condition{{
+				Type:               toolchainv1alpha1.ConditionReady,
+				Status:             status,
+				Reason:             reason,
+				LastTransitionTime: transitioned,
+			}}
+		}
+		return nsTmplSet
+	}
+
+	for name, tc := range map[string]struct {
+		nsTmplSet          *toolchainv1alpha1.NSTemplateSet
+		expectedOperation  string
+		expectedStart      time.Time
+		expectedInProgress bool
+	}{
+		"new": {
+			nsTmplSet:          withCondition("", ""),
+			expectedOperation:  provisionOperation,
+			expectedStart:      created.Time,
+			expectedInProgress: true,
+		},
+		"provisioning": {
+			nsTmplSet:          withCondition(toolchainv1alpha1.NSTemplateSetProvisioningReason, corev1.ConditionFalse),
+			expectedOperation:  provisionOperation,
+			expectedStart:      created.Time,
+			expectedInProgress: true,
+		},
+		"provision failed": {
+			nsTmplSet:          withCondition(toolchainv1alpha1.NSTemplateSetUnableToProvisionNamespaceReason, corev1.ConditionFalse),
+			expectedOperation:  provisionOperation,
+			expectedStart:      created.Time,
+			expectedInProgress: true,
+		},
+		"updating": {
+			nsTmplSet:          withCondition(toolchainv1alpha1.NSTemplateSetUpdatingReason, corev1.ConditionFalse),
+			expectedOperation:  updateOperation,
+			expectedStart:      seen,
+			expectedInProgress: true,
+		},
+		"update failed": {
+			nsTmplSet:          withCondition(toolchainv1alpha1.NSTemplateSetUpdateFailedReason, corev1.ConditionFalse),
+			expectedOperation:  updateOperation,
+			expectedStart:      seen,
+			expectedInProgress: true,
+		},
+		"provisioned": {
+			nsTmplSet: withCondition(toolchainv1alpha1.NSTemplateSetProvisionedReason, corev1.ConditionTrue),
+		},
+		"terminating": {
+			nsTmplSet: withCondition(toolchainv1alpha1.NSTemplateSetTerminatingReason, corev1.ConditionFalse),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			tracker := newProvisioningTracker()
+			tracker.generations["johnsmith"] = observedGeneration{generation: 2, seen: seen}
+
+			// when
+			operation, start, inProgress := tracker.provisioningStart(tc.nsTmplSet)
+
+			// then
+			assert.Equal(t, tc.expectedOperation, operation)
+			assert.True(t, tc.expectedStart.Equal(start), "expected %s, got %s", tc.expectedStart, start)
+			assert.Equal(t, tc.expectedInProgress, inProgress)
+		})
+	}
+
+	t.Run("updating with a generation not seen yet", func(t *testing.T) {
+		// given
+		tracker := newProvisioningTracker()
+		tracker.generations["johnsmith"] = observedGeneration{generation: 1, seen: seen}
+		before := time.Now()
+
+		// when
+		operation, start, inProgress := tracker.provisioningStart(withCondition(toolchainv1alpha1.NSTemplateSetUpdatingReason, corev1.ConditionFalse))
+
+		// then
+		assert.Equal(t, updateOperation, operation)
+		assert.False(t, start.Before(before))
+		assert.True(t, inProgress)
+		assert.Equal(t, int64(2), tracker.generations["johnsmith"].generation)
+	})
+}
+
+func TestTrackGeneration(t *testing.T) {
+	// given
+	tracker := newProvisioningTracker()
+	nsTmplSet := newNSTmplSet("toolchain-member", "johnsmith", "basic", withConditions(Provisioned()))
+	nsTmplSet.Generation = 1
+
+	// when
+	tracker.trackGeneration(nsTmplSet)
+
+	// then
+	first := tracker.generations["johnsmith"]
+	assert.Equal(t, int64(1), first.generation)
+	assert.False(t, first.applied)
+
+	t.Run("first seen time kept while the generation is not applied", func(t *testing.T) {
+		// when
+		tracker.trackGeneration(nsTmplSet)
+
+		// then
+		assert.Equal(t, first, tracker.generations["johnsmith"])
+	})
+
+	t.Run("first seen time reset once the generation was applied", func(t *testing.T) {
+		// given
+		first.seen = first.seen.Add(-time.Minute)
+		tracker.generations["johnsmith"] = first
+		tracker.generationApplied(nsTmplSet)
+
+		// when
+		tracker.trackGeneration(nsTmplSet)
+
+		// then
+		observed := tracker.generations["johnsmith"]
+		assert.Equal(t, int64(1), observed.generation)
+		assert.True(t, observed.applied)
+		assert.True(t, observed.seen.After(first.seen))
+
+		t.Run("first seen time kept while an update without a spec change is in progress", func(t *testing.T) {
+			// given
+			nsTmplSet.Status.Conditions = []toolchainv1alpha1.Condition{Updating()}
+
+			// when
+			tracker.trackGeneration(nsTmplSet)
+
+			// then
+			assert.Equal(t, observed, tracker.generations["johnsmith"])
+		})
+	})
+
+	t.Run("new generation", func(t *testing.T) {
+		// given
+		nsTmplSet.Generation = 2
+
+		// when
+		tracker.trackGeneration(nsTmplSet)
+
+		// then
+		observed := tracker.generations["johnsmith"]
+		assert.Equal(t, int64(2), observed.generation)
+		assert.False(t, observed.applied)
+	})
+
+	t.Run("untracked", func(t *testing.T) {
+		// when
+		tracker.untrack("johnsmith")
+
+		// then
+		assert.Empty(t, tracker.generations)
+	})
+}
+
+func TestProvisioningTracker(t *testing.T) {
+
+	t.Run("ready reasons", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		tracker := newProvisioningTracker()
+
+		// when
+		tracker.trackReadyReason("johnsmith", toolchainv1alpha1.NSTemplateSetProvisioningReason)
+		tracker.trackReadyReason("janedoe", toolchainv1alpha1.NSTemplateSetProvisioningReason)
+		tracker.trackReadyReason("johnsmith", toolchainv1alpha1.NSTemplateSetProvisionedReason)
+		tracker.trackReadyReason("johnsmith", toolchainv1alpha1.NSTemplateSetProvisionedReason)
+
+		// then
+		assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetProvisioningReason, 1)
+		assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetProvisionedReason, 1)
+
+		t.Run("untracked", func(t *testing.T) {
+			// when
+			tracker.untrack("johnsmith")
+			tracker.untrack("unknown")
+
+			// then
+			assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetProvisioningReason, 1)
+			assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetProvisionedReason, 0)
+		})
+	})
+
+	t.Run("phases observed once per provisioning", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		tracker := newProvisioningTracker()
+		nsTmplSet := newNSTmplSet("toolchain-member", "johnsmith", "basic", withConditions(Provisioning()))
+		nsTmplSet.CreationTimestamp = metav1.NewTime(time.Now().Add(-3 * time.Second))
+
+		// when
+		tracker.observePhase(nsTmplSet, namespacesPhase)
+		tracker.observePhase(nsTmplSet, namespacesPhase)
+		tracker.observePhase(nsTmplSet, readyPhase)
+
+		// then
+		assertProvisioningDurationCount(t, "basic", provisionOperation, namespacesPhase, 1)
+		assertProvisioningDurationCount(t, "basic", provisionOperation, readyPhase, 1)
+		assert.Empty(t, tracker.phases)
+
+		t.Run("observed again for the next update", func(t *testing.T) {
+			// given
+			nsTmplSet.Status.Conditions = []toolchainv1alpha1.Condition{Updating()}
+			nsTmplSet.Generation++
+			tracker.trackGeneration(nsTmplSet)
+
+			// when
+			tracker.observePhase(nsTmplSet, namespacesPhase)
+
+			// then
+			assertProvisioningDurationCount(t, "basic", updateOperation, namespacesPhase, 1)
+			assertProvisioningDurationCount(t, "basic", provisionOperation, namespacesPhase, 1)
+		})
+
+		t.Run("not observed once ready", func(t *testing.T) {
+			// given
+			nsTmplSet.Status.Conditions = []toolchainv1alpha1.Condition{Provisioned()}
+
+			// when
+			tracker.observePhase(nsTmplSet, readyPhase)
+
+			// then
+			assertProvisioningDurationCount(t, "basic", updateOperation, readyPhase, 0)
+		})
+	})
+}
+
+func TestProvisioningMetricsOnReconcile(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	metrics.Reset()
+	nsTemplateSetProvisioningTracker = newProvisioningTracker()
+
+	t.Run("provisioned", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet)
+
+		// when
+		for i := 0; i < 5; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+		}
+
+		// then
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Provisioned())
+		assertProvisioningDurationCount(t, "basic", provisionOperation, clusterResourcesPhase, 1)
+		assertProvisioningDurationCount(t, "basic", provisionOperation, namespacesPhase, 1)
+		assertProvisioningDurationCount(t, "basic", provisionOperation, spaceRolesPhase, 1)
+		assertProvisioningDurationCount(t, "basic", provisionOperation, readyPhase, 1)
+		assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetProvisionedReason, 1)
+		assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetProvisioningReason, 0)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, "janedoe", "unknown", withNamespaces("abcde11", "dev"))
+		r, req, _ := prepareReconcile(t, namespaceName, "janedoe", nsTmplSet)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.Error(t, err)
+		assert.InDelta(t, float64(1), promtestutil.ToFloat64(metrics.NSTemplateSetFailuresCounterVec.WithLabelValues(toolchainv1alpha1.NSTemplateSetUnableToProvisionNamespaceReason)), 0.01)
+		assertReadyReasonGauge(t, toolchainv1alpha1.NSTemplateSetUnableToProvisionNamespaceReason, 1)
+	})
+}
+
+func assertReadyReasonGauge(t *testing.T, reason string, expected int) {
+	assert.InDelta(t, float64(expected), promtestutil.ToFloat64(metrics.NSTemplateSetsReadyReasonGaugeVec.WithLabelValues(reason)), 0.01, "reason: %s", reason)
+}
+
+func assertProvisioningDurationCount(t *testing.T, tier, operation, phase string, expected int) {
+	m := &dto.Metric{}
+	require.NoError(t, metrics.NSTemplateSetProvisioningDurationHistogramVec.WithLabelValues(tier, operation, phase).(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(expected), m.GetHistogram().GetSampleCount(), "%s/%s/%s", tier, operation, phase)
+}
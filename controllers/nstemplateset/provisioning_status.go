@@ -0,0 +1,300 @@
+package nstemplateset
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"maps"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/redhat-cop/operator-utils/pkg/util"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// ProvisioningStatusAnnotationKey is the annotation set on the NSTemplateSet with the (JSON-encoded) ProvisioningStatus, ie,
+	// the details about the objects that could not be applied or deleted. The annotation is removed once the NSTemplateSet is provisioned.
+	ProvisioningStatusAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "provisioning-status"
+
+	// maxFailedObjects is the maximum number of failed objects kept in the provisioning status (the most recent failures are kept)
+	maxFailedObjects = 10
+
+	applyOperation  = "apply"
+	deleteOperation = "delete"
+)
+
+// ProvisioningStatus contains the details about the template objects that failed to be applied or deleted
+type ProvisioningStatus struct {
+	// FailedObjects is the list of the objects that failed to be applied or deleted, the most recent failure first
+	FailedObjects []FailedObject `json:"failedObjects,omitempty"`
+	// Namespaces contains the number of failed objects per namespace
+	Namespaces []NamespaceProvisioningSummary `json:"namespaces,omitempty"`
+}
+
+// FailedObject is a template object that failed to be applied or deleted
+type FailedObject struct {
+	GVK             string      `json:"gvk"`
+	Namespace       string      `json:"namespace,omitempty"`
+	Name            string      `json:"name"`
+	Operation       string      `json:"operation"`
+	Error           string      `json:"error"`
+	LastAttemptTime metav1.Time `json:"lastAttemptTime"`
+}
+
+// NamespaceProvisioningSummary is the summary of the failures in a namespace. Failures of the Namespace objects themselves are
+// counted in the summary of the namespace with the same name, while the failures of cluster-scoped objects are counted with an empty name.
+type NamespaceProvisioningSummary struct {
+	Name          string `json:"name"`
+	FailedObjects int    `json:"failedObjects"`
+}
+
+// objectError is an error that occurred while applying or deleting a given template object
+type objectError struct {
+	operation string
+	object    runtimeclient.Object
+	err       error
+}
+
+func newObjectError(operation string, object runtimeclient.Object, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &objectError{
+		operation: operation,
+		object:    object,
+		err:       err,
+	}
+}
+
+func (e *objectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *objectError) Unwrap() error {
+	return e.err
+}
+
+// recordFailedObject adds the object of the given error (if it is an *objectError) to the provisioning status of the NSTemplateSet.
+func (r *statusManager) recordFailedObject(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, err error, message string) error {
+	var objErr *objectError
+	if !stderrors.As(err, &objErr) {
+		return nil
+	}
+	gvk, gvkErr := apiutil.GVKForObject(objErr.object, r.Scheme)
+	if gvkErr != nil {
+		gvk = objErr.object.GetObjectKind().GroupVersionKind()
+	}
+	failed := FailedObject{
+		GVK:             gvk.String(),
+		Namespace:       objErr.object.GetNamespace(),
+		Name:            objErr.object.GetName(),
+		Operation:       objErr.operation,
+		Error:           message,
+		LastAttemptTime: metav1.Now(),
+	}
+
+	status := getAnnotationJSON[ProvisioningStatus](ctx, nsTmplSet, ProvisioningStatusAnnotationKey)
+	failedObjects := slices.DeleteFunc(status.FailedObjects, func(f FailedObject) bool {
+		return f.GVK == failed.GVK && f.Namespace == failed.Namespace && f.Name == failed.Name
+	})
+	failedObjects = append([]FailedObject{failed}, failedObjects...)
+	if len(failedObjects) > maxFailedObjects {
+		failedObjects = failedObjects[:maxFailedObjects]
+	}
+	return r.setProvisioningStatus(ctx, nsTmplSet, failedObjects)
+}
+
+// clearProvisioningStatus removes the provisioning status from the NSTemplateSet (if any)
+func (r *statusManager) clearProvisioningStatus(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	if _, found := getAnnotation(ctx, nsTmplSet, ProvisioningStatusAnnotationKey); !found {
+		return nil
+	}
+	return r.setProvisioningStatus(ctx, nsTmplSet, nil)
+}
+
+func (r *statusManager) setProvisioningStatus(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, failedObjects []FailedObject) error {
+	if len(failedObjects) == 0 {
+		return r.setAnnotation(ctx, nsTmplSet, ProvisioningStatusAnnotationKey, "")
+	}
+	return r.setAnnotationJSON(ctx, nsTmplSet, ProvisioningStatusAnnotationKey, ProvisioningStatus{
+		FailedObjects: failedObjects,
+		Namespaces:    summarizeByNamespace(failedObjects),
+	})
+}
+
+// annotationChanges are the changes of the annotations maintained by the controller on an NSTemplateSet during a reconcile,
+// which are saved all at once at the end of the reconcile (see saveAnnotations). A nil value is the removal of the annotation.
+type annotationChanges struct {
+	nsTmplSet *toolchainv1alpha1.NSTemplateSet
+	values    map[string]*string
+}
+
+type annotationChangesKey struct{}
+
+// withAnnotationChanges returns a context in which the changes of the annotations maintained by the controller are
+// collected instead of being saved immediately
+func withAnnotationChanges(ctx context.Context) context.Context {
+	return context.WithValue(ctx, annotationChangesKey{}, &annotationChanges{values: map[string]*string{}})
+}
+
+// setAnnotation sets (or removes, if the value is empty) the given annotation which is maintained by the controller on the NSTemplateSet.
+// The change is saved at the end of the reconcile if the context collects the changes (see withAnnotationChanges), otherwise immediately.
+func (r *statusManager) setAnnotation(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, key, value string) error {
+	var v *string
+	if value != "" {
+		v = &value
+	}
+	if changes, ok := ctx.Value(annotationChangesKey{}).(*annotationChanges); ok {
+		changes.nsTmplSet = nsTmplSet
+		changes.values[key] = v
+		return nil
+	}
+	return r.patchAnnotations(ctx, nsTmplSet, map[string]*string{key: v})
+}
+
+// saveAnnotations saves the changes of the annotations collected during the reconcile (if any) with a single patch
+func (r *statusManager) saveAnnotations(ctx context.Context) error {
+	changes, ok := ctx.Value(annotationChangesKey{}).(*annotationChanges)
+	if !ok || len(changes.values) == 0 {
+		return nil
+	}
+	if err := r.patchAnnotations(ctx, changes.nsTmplSet, changes.values); err != nil {
+		return err
+	}
+	clear(changes.values)
+	return nil
+}
+
+// patchAnnotations patches the given annotations of the NSTemplateSet, and applies them to the in-memory NSTemplateSet (along with
+// its new resource version) once they are saved.
+// The patch only contains the given annotations, so it does not conflict with the changes of the other annotations (eg, by the host).
+func (r *statusManager) patchAnnotations(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, values map[string]*string) error {
+	data, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": values,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// patch a copy, as the in-memory status of the NSTemplateSet would otherwise be reset to the one that is stored in the cluster
+	patched := nsTmplSet.DeepCopy()
+	if err := r.Client.Patch(ctx, patched, runtimeclient.RawPatch(types.MergePatchType, data)); err != nil {
+		if errors.IsNotFound(err) && util.IsBeingDeleted(nsTmplSet) {
+			return nil // the NSTemplateSet was deleted once its finalizer was removed
+		}
+		return err
+	}
+	nsTmplSet.SetResourceVersion(patched.GetResourceVersion())
+	annotations := nsTmplSet.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for key, value := range values {
+		if value == nil {
+			delete(annotations, key)
+		} else {
+			annotations[key] = *value
+		}
+	}
+	nsTmplSet.SetAnnotations(annotations)
+	return nil
+}
+
+// setAnnotationJSON sets the given annotation which is maintained by the controller on the NSTemplateSet to the JSON encoding of the given value
+func (r *statusManager) setAnnotationJSON(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.setAnnotation(ctx, nsTmplSet, key, string(data))
+}
+
+// getAnnotation returns the value of the given annotation which is maintained by the controller on the NSTemplateSet,
+// including the change which is not saved yet (if any)
+func getAnnotation(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, key string) (string, bool) {
+	if changes, ok := ctx.Value(annotationChangesKey{}).(*annotationChanges); ok {
+		if value, changed := changes.values[key]; changed {
+			if value == nil {
+				return "", false
+			}
+			return *value, true
+		}
+	}
+	value, found := nsTmplSet.GetAnnotations()[key]
+	return value, found
+}
+
+// getAnnotationJSON returns the value of the given annotation which is maintained by the controller on the NSTemplateSet,
+// decoded from JSON (or the zero value if the annotation is not set).
+// An invalid value is ignored (and will be overridden).
+func getAnnotationJSON[T any](ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, key string) T {
+	var value T
+	data, found := getAnnotation(ctx, nsTmplSet, key)
+	if !found {
+		return value
+	}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		log.FromContext(ctx).Error(err, "ignoring invalid annotation", "annotation", key, "value", data)
+		var zero T
+		return zero
+	}
+	return value
+}
+
+func summarizeByNamespace(failedObjects []FailedObject) []NamespaceProvisioningSummary {
+	counts := map[string]int{}
+	for _, f := range failedObjects {
+		namespace := f.Namespace
+		if f.GVK == "/v1, Kind=Namespace" {
+			namespace = f.Name
+		}
+		counts[namespace]++
+	}
+	summaries := make([]NamespaceProvisioningSummary, 0, len(counts))
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		summaries = append(summaries, NamespaceProvisioningSummary{
+			Name:          name,
+			FailedObjects: counts[name],
+		})
+	}
+	return summaries
+}
+
+// controllerAnnotations are the annotations of the NSTemplateSet which are maintained by the controller itself
+var controllerAnnotations = []string{
+	ProvisioningStatusAnnotationKey,
+	SpaceBackupsAnnotationKey,
+	MigrationStatusAnnotationKey,
+	QuotaUsageAnnotationKey,
+	RecreatedObjectsAnnotationKey,
+	OrphanCleanupStatusAnnotationKey,
+}
+
+// annotationChangedPredicate triggers a reconcile when the annotations of the NSTemplateSet changed, except when
+// the change is only about the annotations maintained by the controller itself (otherwise recording a failure
+// would trigger a new reconcile immediately, bypassing the rate-limited requeue of the failed reconcile).
+type annotationChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (annotationChangedPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+	oldAnnotations := maps.Clone(e.ObjectOld.GetAnnotations())
+	newAnnotations := maps.Clone(e.ObjectNew.GetAnnotations())
+	for _, key := range controllerAnnotations {
+		delete(oldAnnotations, key)
+		delete(newAnnotations, key)
+	}
+	return !maps.Equal(oldAnnotations, newAnnotations)
+}
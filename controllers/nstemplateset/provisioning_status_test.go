@@ -0,0 +1,302 @@
+package nstemplateset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestProvisioningStatus(t *testing.T) {
+	// given
+	logger := zap.New(zap.UseDevMode(true))
+	log.SetLogger(logger)
+	ctx := log.IntoContext(context.TODO(), logger)
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+
+	t.Run("failed object is recorded when inner resources cannot be created", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"))
+		devNS := newNamespace("", spacename, "dev")
+		manager, fakeClient := prepareNamespacesManager(t, nsTmplSet, devNS)
+		fakeClient.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			return errors.New("admission webhook denied the request")
+		}
+
+		// when
+		_, err := manager.ensure(ctx, nsTmplSet)
+
+		// then
+		require.Error(t, err)
+		status := getStoredAnnotationJSON[ProvisioningStatus](t, fakeClient, namespaceName, spacename, ProvisioningStatusAnnotationKey)
+		require.Len(t, status.FailedObjects, 1)
+		failed := status.FailedObjects[0]
+		assert.Equal(t, "rbac.authorization.k8s.io/v1, Kind=RoleBinding", failed.GVK)
+		assert.Equal(t, "johnsmith-dev", failed.Namespace)
+		assert.Equal(t, "crtadmin-pods", failed.Name)
+		assert.Equal(t, applyOperation, failed.Operation)
+		assert.Contains(t, failed.Error, "admission webhook denied the request")
+		assert.False(t, failed.LastAttemptTime.IsZero())
+		assert.Equal(t, []NamespaceProvisioningSummary{{Name: "johnsmith-dev", FailedObjects: 1}}, status.Namespaces)
+		// the condition is still set
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(UnableToProvisionNamespace(
+				"unable to create resource of kind: RoleBinding, version: v1: unable to create resource of kind: RoleBinding, version: v1: admission webhook denied the request"))
+	})
+
+	t.Run("failed deletion of namespace is recorded", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withDeletionTs(), withNamespaces("abcde11", "dev"))
+		devNS := newNamespace("basic", spacename, "dev")
+		manager, fakeClient := prepareNamespacesManager(t, nsTmplSet, devNS)
+		fakeClient.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		_, err := manager.ensureDeleted(ctx, nsTmplSet)
+
+		// then
+		require.Error(t, err)
+		status := getStoredAnnotationJSON[ProvisioningStatus](t, fakeClient, namespaceName, spacename, ProvisioningStatusAnnotationKey)
+		require.Len(t, status.FailedObjects, 1)
+		assert.Equal(t, "/v1, Kind=Namespace", status.FailedObjects[0].GVK)
+		assert.Equal(t, "johnsmith-dev", status.FailedObjects[0].Name)
+		assert.Equal(t, deleteOperation, status.FailedObjects[0].Operation)
+		assert.Equal(t, []NamespaceProvisioningSummary{{Name: "johnsmith-dev", FailedObjects: 1}}, status.Namespaces)
+	})
+
+	t.Run("failures of the same object are merged", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+		cm := newConfigMapObject("johnsmith-dev", "cm")
+
+		// when
+		err := manager.recordFailedObject(ctx, nsTmplSet, newObjectError(applyOperation, cm, errors.New("first error")), "first error")
+		require.NoError(t, err)
+		err = manager.recordFailedObject(ctx, nsTmplSet, newObjectError(applyOperation, cm, errors.New("second error")), "second error")
+
+		// then
+		require.NoError(t, err)
+		status := getStoredAnnotationJSON[ProvisioningStatus](t, fakeClient, namespaceName, spacename, ProvisioningStatusAnnotationKey)
+		require.Len(t, status.FailedObjects, 1)
+		assert.Equal(t, "second error", status.FailedObjects[0].Error)
+	})
+
+	t.Run("list of failed objects is bounded", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+
+		// when
+		for i := 0; i < maxFailedObjects+2; i++ {
+			namespace := "johnsmith-dev"
+			if i%2 == 1 {
+				namespace = "johnsmith-stage"
+			}
+			err := manager.recordFailedObject(ctx, nsTmplSet, newObjectError(applyOperation, newConfigMapObject(namespace, fmt.Sprintf("cm-%d", i)), errors.New("mock error")), "mock error")
+			require.NoError(t, err)
+		}
+
+		// then
+		status := getStoredAnnotationJSON[ProvisioningStatus](t, fakeClient, namespaceName, spacename, ProvisioningStatusAnnotationKey)
+		require.Len(t, status.FailedObjects, maxFailedObjects)
+		assert.Equal(t, fmt.Sprintf("cm-%d", maxFailedObjects+1), status.FailedObjects[0].Name) // most recent first
+		assert.Equal(t, "cm-2", status.FailedObjects[maxFailedObjects-1].Name)
+		assert.Equal(t, []NamespaceProvisioningSummary{
+			{Name: "johnsmith-dev", FailedObjects: 5},
+			{Name: "johnsmith-stage", FailedObjects: 5},
+		}, status.Namespaces)
+	})
+
+	t.Run("errors not related to an object are not recorded", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+
+		// when
+		err := manager.wrapErrorWithStatusUpdate(ctx, nsTmplSet, manager.setStatusProvisionFailed, errors.New("some error"), "failed")
+
+		// then
+		require.Error(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(UnableToProvision("some error"))
+		assert.Empty(t, getStoredAnnotationJSON[ProvisioningStatus](t, fakeClient, namespaceName, spacename, ProvisioningStatusAnnotationKey).FailedObjects)
+	})
+
+	t.Run("provisioning status is cleared when ready", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withConditions(Provisioning()))
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+		err := manager.recordFailedObject(ctx, nsTmplSet, newObjectError(applyOperation, newConfigMapObject("johnsmith-dev", "cm"), errors.New("mock error")), "mock error")
+		require.NoError(t, err)
+
+		// when
+		err = manager.setStatusReady(ctx, nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(Provisioned()).
+			HasNoAnnotation(ProvisioningStatusAnnotationKey)
+	})
+}
+
+func TestAnnotationJSON(t *testing.T) {
+	// given
+	withAnnotations := func(annotations map[string]string) *toolchainv1alpha1.NSTemplateSet {
+		return &toolchainv1alpha1.NSTemplateSet{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+
+	t.Run("valid value", func(t *testing.T) {
+		// when
+		status := getAnnotationJSON[ProvisioningStatus](context.TODO(), withAnnotations(map[string]string{
+			ProvisioningStatusAnnotationKey: `{"failedObjects":[{"name":"foo"}]}`,
+		}), ProvisioningStatusAnnotationKey)
+
+		// then
+		assert.Equal(t, ProvisioningStatus{FailedObjects: []FailedObject{{Name: "foo"}}}, status)
+	})
+
+	t.Run("missing value", func(t *testing.T) {
+		// when
+		status := getAnnotationJSON[ProvisioningStatus](context.TODO(), withAnnotations(nil), ProvisioningStatusAnnotationKey)
+
+		// then
+		assert.Equal(t, ProvisioningStatus{}, status)
+	})
+
+	t.Run("invalid value is ignored", func(t *testing.T) {
+		// when
+		backups := getAnnotationJSON[[]SpaceBackup](context.TODO(), withAnnotations(map[string]string{
+			SpaceBackupsAnnotationKey: `[{"namespace":"foo"}`,
+		}), SpaceBackupsAnnotationKey)
+
+		// then
+		assert.Nil(t, backups)
+	})
+}
+
+func TestAnnotationChanges(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+
+	t.Run("changes saved with a single patch", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withConditions(Provisioning()))
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+		patched := 0
+		fakeClient.MockPatch = func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patched++
+			return fakeClient.Client.Patch(ctx, obj, patch, opts...)
+		}
+		ctx := withAnnotationChanges(context.TODO())
+		require.NoError(t, manager.recordFailedObject(ctx, nsTmplSet, newObjectError(applyOperation, newConfigMapObject("johnsmith-dev", "cm"), errors.New("mock error")), "mock error"))
+		require.NoError(t, manager.setAnnotationJSON(ctx, nsTmplSet, QuotaUsageAnnotationKey, QuotaUsage{}))
+		require.Len(t, getAnnotationJSON[ProvisioningStatus](ctx, nsTmplSet, ProvisioningStatusAnnotationKey).FailedObjects, 1)
+		assert.Equal(t, 0, patched)
+
+		// when
+		err := manager.saveAnnotations(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, patched)
+		assert.Len(t, getStoredAnnotationJSON[ProvisioningStatus](t, fakeClient, namespaceName, spacename, ProvisioningStatusAnnotationKey).FailedObjects, 1)
+		stored := &toolchainv1alpha1.NSTemplateSet{}
+		require.NoError(t, fakeClient.Get(context.TODO(), test.NamespacedName(namespaceName, spacename), stored))
+		assert.Contains(t, stored.Annotations, QuotaUsageAnnotationKey)
+		// the status is not reset by the patch
+		test.AssertConditionsMatch(t, nsTmplSet.Status.Conditions, Provisioning())
+
+		t.Run("nothing to save", func(t *testing.T) {
+			// when
+			err := manager.saveAnnotations(ctx)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 1, patched)
+		})
+	})
+
+	t.Run("in-memory annotations unchanged when the patch fails", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+		fakeClient.MockPatch = func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return fmt.Errorf("mock error")
+		}
+
+		// when
+		err := manager.setAnnotation(context.TODO(), nsTmplSet, ProvisioningStatusAnnotationKey, "{}")
+
+		// then
+		require.EqualError(t, err, "mock error")
+		assert.NotContains(t, nsTmplSet.GetAnnotations(), ProvisioningStatusAnnotationKey)
+	})
+}
+
+func TestAnnotationChangedPredicate(t *testing.T) {
+	// given
+	withAnnotations := func(annotations map[string]string) *toolchainv1alpha1.NSTemplateSet {
+		return &toolchainv1alpha1.NSTemplateSet{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+	pred := annotationChangedPredicate{}
+
+	t.Run("provisioning status changed", func(t *testing.T) {
+		assert.False(t, pred.Update(event.UpdateEvent{
+			ObjectOld: withAnnotations(map[string]string{"foo": "bar"}),
+			ObjectNew: withAnnotations(map[string]string{"foo": "bar", ProvisioningStatusAnnotationKey: "{}"}),
+		}))
+	})
+
+	t.Run("space backups changed", func(t *testing.T) {
+		assert.False(t, pred.Update(event.UpdateEvent{
+			ObjectOld: withAnnotations(map[string]string{"foo": "bar"}),
+			ObjectNew: withAnnotations(map[string]string{"foo": "bar", SpaceBackupsAnnotationKey: "[]"}),
+		}))
+	})
+
+	t.Run("other annotation changed", func(t *testing.T) {
+		assert.True(t, pred.Update(event.UpdateEvent{
+			ObjectOld: withAnnotations(map[string]string{ProvisioningStatusAnnotationKey: "{}"}),
+			ObjectNew: withAnnotations(map[string]string{"foo": "bar", ProvisioningStatusAnnotationKey: "{}"}),
+		}))
+	})
+
+	t.Run("nothing changed", func(t *testing.T) {
+		assert.False(t, pred.Update(event.UpdateEvent{
+			ObjectOld: withAnnotations(map[string]string{"foo": "bar"}),
+			ObjectNew: withAnnotations(map[string]string{"foo": "bar"}),
+		}))
+	})
+}
+
+func newConfigMapObject(namespace, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+}
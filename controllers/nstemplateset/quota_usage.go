@@ -0,0 +1,240 @@
+package nstemplateset
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// QuotaUsageAnnotationKey is the annotation set on the NSTemplateSet with the usage of the ResourceQuotas and ClusterResourceQuotas
+	// of the space, so that the host can surface it to the users and notify them when they get close to their limits.
+	QuotaUsageAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "quota-usage"
+	// QuotaUsageRefreshPeriodAnnotationKey is the annotation on the MemberOperatorConfig with the minimum duration between two refreshes
+	// of the quota usage of a space (eg, `5m`). The quota usage is not reported when the annotation is not set.
+	QuotaUsageRefreshPeriodAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "quota-usage-refresh-period"
+	// QuotaUsageThresholdsAnnotationKey is the annotation on the MemberOperatorConfig with the comma-separated usage thresholds
+	// (in percent, eg, `80,95`) which are reported when reached.
+	QuotaUsageThresholdsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "quota-usage-thresholds"
+
+	// QuotaUsageThresholdReachedReason is the reason of the Events emitted when the usage of a resource reached a configured threshold
+	QuotaUsageThresholdReachedReason = "QuotaUsageThresholdReached"
+)
+
+// QuotaUsage is the usage of the quotas of a space
+type QuotaUsage struct {
+	Resources      []ResourceUsage `json:"resources,omitempty"`
+	LastUpdateTime metav1.Time     `json:"lastUpdateTime"`
+}
+
+// ResourceUsage is the usage of a resource limited by a quota
+type ResourceUsage struct {
+	// Quota is the quota which limits the resource, ie, `ResourceQuota/<namespace>/<name>` or `ClusterResourceQuota/<name>`
+	Quota    string `json:"quota"`
+	Resource string `json:"resource"`
+	Used     string `json:"used"`
+	Hard     string `json:"hard"`
+	Percent  int    `json:"percent"`
+	// ThresholdReached is the highest of the configured thresholds which is reached, if any
+	ThresholdReached int `json:"thresholdReached,omitempty"`
+}
+
+// quotaUsageRefreshes are the times of the last refreshes of the quota usage of the spaces. The annotation of the NSTemplateSet
+// is not updated when the usage did not change, so its `LastUpdateTime` may be older than the last refresh.
+var quotaUsageRefreshes = struct {
+	mu    sync.Mutex
+	times map[string]time.Time
+}{
+	times: map[string]time.Time{},
+}
+
+// lastQuotaUsageRefresh returns the time of the last refresh of the quota usage of the given space, if it is more recent than the given time
+func lastQuotaUsageRefresh(spacename string, lastUpdateTime time.Time) time.Time {
+	quotaUsageRefreshes.mu.Lock()
+	defer quotaUsageRefreshes.mu.Unlock()
+	if refreshed, found := quotaUsageRefreshes.times[spacename]; found && refreshed.After(lastUpdateTime) {
+		return refreshed
+	}
+	return lastUpdateTime
+}
+
+// setQuotaUsageRefreshed records the time at which the quota usage of the given space was refreshed
+// (or forgets about the space, if the given time is zero)
+func setQuotaUsageRefreshed(spacename string, refreshed time.Time) {
+	quotaUsageRefreshes.mu.Lock()
+	defer quotaUsageRefreshes.mu.Unlock()
+	if refreshed.IsZero() {
+		delete(quotaUsageRefreshes.times, spacename)
+		return
+	}
+	quotaUsageRefreshes.times[spacename] = refreshed
+}
+
+var (
+	resourceQuotaGVK        = schema.GroupVersionKind{Version: "v1", Kind: "ResourceQuota"}
+	clusterResourceQuotaGVK = schema.GroupVersionKind{Group: "quota.openshift.io", Version: "v1", Kind: "ClusterResourceQuota"}
+)
+
+// refreshQuotaUsage refreshes the usage of the quotas of the space in the annotation of the NSTemplateSet, unless it was refreshed
+// less than the configured period ago. It returns the duration after which the usage should be refreshed again (or zero if the
+// quota usage reporting is disabled).
+// The ResourceQuotas are listed in each of the provisioned namespaces of the space, and the ClusterResourceQuotas are fetched
+// by the names they have in the cluster resources template of the space.
+// The reached thresholds are reported with Events on the NSTemplateSet, and the annotation is not updated when the usage did not change.
+// Note: the quotas are fetched as unstructured objects, so that the (namespace-scoped) cache of the client is bypassed.
+func (r *Reconciler) refreshQuotaUsage(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) (time.Duration, error) {
+	period := memberOperatorConfigDuration(ctx, QuotaUsageRefreshPeriodAnnotationKey, 0)
+	if period == 0 {
+		return 0, nil
+	}
+	current := getAnnotationJSON[QuotaUsage](ctx, nsTmplSet, QuotaUsageAnnotationKey)
+	if elapsed := time.Since(lastQuotaUsageRefresh(nsTmplSet.Name, current.LastUpdateTime.Time)); elapsed < period {
+		return period - elapsed, nil
+	}
+
+	thresholds := quotaUsageThresholds(ctx)
+	usage := QuotaUsage{
+		LastUpdateTime: metav1.Now(),
+	}
+	for _, ns := range nsTmplSet.Status.ProvisionedNamespaces {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(resourceQuotaGVK.GroupVersion().WithKind(resourceQuotaGVK.Kind + "List"))
+		if err := r.Client.List(ctx, list, runtimeclient.InNamespace(ns.Name), runtimeclient.MatchingLabels{
+			toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
+			toolchainv1alpha1.SpaceLabelKey:    nsTmplSet.GetName(),
+		}); err != nil {
+			return 0, errs.Wrapf(err, "unable to list the ResourceQuotas of the space in namespace '%s'", ns.Name)
+		}
+		for _, quota := range list.Items {
+			usage.Resources = append(usage.Resources, resourceUsages(ctx, quota, []string{"status"}, thresholds)...)
+		}
+	}
+	clusterQuotaNames, err := r.clusterResourceQuotaNames(ctx, nsTmplSet)
+	if err != nil {
+		return 0, errs.Wrap(err, "unable to get the ClusterResourceQuotas of the space")
+	}
+	for _, name := range clusterQuotaNames {
+		quota := &unstructured.Unstructured{}
+		quota.SetGroupVersionKind(clusterResourceQuotaGVK)
+		if err := r.Client.Get(ctx, runtimeclient.ObjectKey{Name: name}, quota); err != nil {
+			if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue // not created yet, or a non-OpenShift cluster
+			}
+			return 0, errs.Wrapf(err, "unable to get the ClusterResourceQuota '%s' of the space", name)
+		}
+		usage.Resources = append(usage.Resources, resourceUsages(ctx, *quota, []string{"status", "total"}, thresholds)...)
+	}
+	sort.Slice(usage.Resources, func(i, j int) bool {
+		if usage.Resources[i].Quota != usage.Resources[j].Quota {
+			return usage.Resources[i].Quota < usage.Resources[j].Quota
+		}
+		return usage.Resources[i].Resource < usage.Resources[j].Resource
+	})
+	for _, resourceUsage := range usage.Resources {
+		if resourceUsage.ThresholdReached > 0 && !slices.ContainsFunc(current.Resources, func(previous ResourceUsage) bool {
+			return previous.Quota == resourceUsage.Quota && previous.Resource == resourceUsage.Resource && previous.ThresholdReached >= resourceUsage.ThresholdReached
+		}) {
+			log.FromContext(ctx).Info("quota usage threshold reached", "quota", resourceUsage.Quota, "resource", resourceUsage.Resource, "threshold", resourceUsage.ThresholdReached)
+			if r.Recorder != nil {
+				r.Recorder.Eventf(nsTmplSet, corev1.EventTypeWarning, QuotaUsageThresholdReachedReason, "the usage of '%s' in %s reached %d%% of the limit (%s of %s)",
+					resourceUsage.Resource, resourceUsage.Quota, resourceUsage.ThresholdReached, resourceUsage.Used, resourceUsage.Hard)
+			}
+		}
+	}
+
+	setQuotaUsageRefreshed(nsTmplSet.Name, usage.LastUpdateTime.Time)
+	if _, found := getAnnotation(ctx, nsTmplSet, QuotaUsageAnnotationKey); found && slices.Equal(usage.Resources, current.Resources) {
+		return period, nil // only the LastUpdateTime would change
+	}
+	if err := r.status.setAnnotationJSON(ctx, nsTmplSet, QuotaUsageAnnotationKey, usage); err != nil {
+		return 0, errs.Wrap(err, "unable to set the quota usage")
+	}
+	return period, nil
+}
+
+// clusterResourceQuotaNames returns the names of the ClusterResourceQuotas in the cluster resources template of the space
+func (r *Reconciler) clusterResourceQuotaNames(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) ([]string, error) {
+	_, features := featureAnnotationNeedsUpdate(nsTmplSet)
+	_, objs, err := r.clusterResources.processTierTemplate(ctx, nsTmplSet.Spec.ClusterResources, nsTmplSet.Name, features)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, obj := range objs {
+		if obj.GetObjectKind().GroupVersionKind().GroupKind() == clusterResourceQuotaGVK.GroupKind() {
+			names = append(names, obj.GetName())
+		}
+	}
+	return names, nil
+}
+
+// resourceUsages returns the usage of each resource which has a `hard` limit in the status of the given quota
+func resourceUsages(ctx context.Context, quota unstructured.Unstructured, statusPath []string, thresholds []int) []ResourceUsage {
+	name := quota.GetKind() + "/" + quota.GetName()
+	if quota.GetNamespace() != "" {
+		name = quota.GetKind() + "/" + quota.GetNamespace() + "/" + quota.GetName()
+	}
+	hard, _, _ := unstructured.NestedStringMap(quota.Object, append(slices.Clone(statusPath), "hard")...)
+	used, _, _ := unstructured.NestedStringMap(quota.Object, append(slices.Clone(statusPath), "used")...)
+	usages := make([]ResourceUsage, 0, len(hard))
+	for resourceName, hardValue := range hard {
+		hardQuantity, err := resource.ParseQuantity(hardValue)
+		if err != nil {
+			log.FromContext(ctx).Info("ignoring invalid quota limit", "quota", name, "resource", resourceName, "value", hardValue)
+			continue
+		}
+		usedQuantity := resource.Quantity{}
+		if usedValue, found := used[resourceName]; found {
+			if usedQuantity, err = resource.ParseQuantity(usedValue); err != nil {
+				log.FromContext(ctx).Info("ignoring invalid quota usage", "quota", name, "resource", resourceName, "value", usedValue)
+				continue
+			}
+		}
+		usage := ResourceUsage{
+			Quota:    name,
+			Resource: resourceName,
+			Used:     usedQuantity.String(),
+			Hard:     hardQuantity.String(),
+		}
+		if hardQuantity.Sign() > 0 {
+			usage.Percent = int(usedQuantity.AsApproximateFloat64() * 100 / hardQuantity.AsApproximateFloat64())
+		}
+		for _, threshold := range thresholds {
+			if usage.Percent >= threshold {
+				usage.ThresholdReached = threshold
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// quotaUsageThresholds returns the configured usage thresholds, in ascending order. Invalid values are ignored.
+func quotaUsageThresholds(ctx context.Context) []int {
+	var thresholds []int
+	for _, value := range memberOperatorConfigList(QuotaUsageThresholdsAnnotationKey) {
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold <= 0 {
+			log.FromContext(ctx).Info("ignoring invalid quota usage threshold in MemberOperatorConfig annotation", "annotation", QuotaUsageThresholdsAnnotationKey, "value", value)
+			continue
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	slices.Sort(thresholds)
+	return thresholds
+}
@@ -0,0 +1,224 @@
+package nstemplateset
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	quotav1 "github.com/openshift/api/quota/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRefreshQuotaUsage(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	rq := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "compute-deploy",
+			Namespace: spacename + "-dev",
+			Labels: map[string]string{
+				toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
+				toolchainv1alpha1.SpaceLabelKey:    spacename,
+			},
+		},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				"limits.cpu":    resource.MustParse("2"),
+				"limits.memory": resource.MustParse("4Gi"),
+			},
+			Used: corev1.ResourceList{
+				"limits.cpu":    resource.MustParse("1900m"),
+				"limits.memory": resource.MustParse("1Gi"),
+			},
+		},
+	}
+	crq := newClusterResourceQuota(spacename, "advanced")
+	crq.Status = quotav1.ClusterResourceQuotaStatus{
+		Total: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{"count/secrets": resource.MustParse("10")},
+			Used: corev1.ResourceList{"count/secrets": resource.MustParse("8")},
+		},
+	}
+	otherRQ := rq.DeepCopy()
+	otherRQ.Namespace = "janedoe-dev"
+	otherRQ.Labels[toolchainv1alpha1.SpaceLabelKey] = "janedoe"
+	config := func(t *testing.T, annotations map[string]string) *toolchainv1alpha1.MemberOperatorConfig {
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t)
+		cfg.Annotations = annotations
+		return cfg
+	}
+
+	provisionedNamespaces := []toolchainv1alpha1.SpaceNamespace{{Name: spacename + "-dev", Type: "default"}}
+	// the last refreshes are kept in memory across the tests
+	resetRefreshes := func(t *testing.T) {
+		setQuotaUsageRefreshed(spacename, time.Time{})
+		t.Cleanup(func() {
+			setQuotaUsageRefreshed(spacename, time.Time{})
+		})
+	}
+
+	t.Run("reports the usage of the quotas", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withClusterResources("abcde11"))
+		nsTmplSet.Status.ProvisionedNamespaces = provisionedNamespaces
+		manager, fakeClient := prepareController(t, nsTmplSet, rq, crq, otherRQ, config(t, map[string]string{
+			QuotaUsageRefreshPeriodAnnotationKey: "5m",
+			QuotaUsageThresholdsAnnotationKey:    "95,80,invalid",
+		}))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		resetRefreshes(t)
+
+		// when
+		requeueAfter, err := manager.refreshQuotaUsage(context.TODO(), nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, requeueAfter)
+		usage := getStoredAnnotationJSON[QuotaUsage](t, fakeClient, namespaceName, spacename, QuotaUsageAnnotationKey)
+		assert.Equal(t, []ResourceUsage{
+			{Quota: "ClusterResourceQuota/for-johnsmith", Resource: "count/secrets", Used: "8", Hard: "10", Percent: 80, ThresholdReached: 80},
+			{Quota: "ResourceQuota/johnsmith-dev/compute-deploy", Resource: "limits.cpu", Used: "1900m", Hard: "2", Percent: 95, ThresholdReached: 95},
+			{Quota: "ResourceQuota/johnsmith-dev/compute-deploy", Resource: "limits.memory", Used: "1Gi", Hard: "4Gi", Percent: 25},
+		}, usage.Resources)
+		events := manager.Recorder.(*record.FakeRecorder).Events
+		require.Len(t, events, 2)
+		assert.Equal(t, "Warning QuotaUsageThresholdReached the usage of 'count/secrets' in ClusterResourceQuota/for-johnsmith reached 80% of the limit (8 of 10)", <-events)
+		assert.Equal(t, "Warning QuotaUsageThresholdReached the usage of 'limits.cpu' in ResourceQuota/johnsmith-dev/compute-deploy reached 95% of the limit (1900m of 2)", <-events)
+
+		t.Run("not refreshed before the end of the period", func(t *testing.T) {
+			// given
+			crq := crq.DeepCopy()
+			require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: crq.Name}, crq))
+			crq.Status.Total.Used["count/secrets"] = resource.MustParse("10")
+			require.NoError(t, fakeClient.Update(context.TODO(), crq))
+
+			// when
+			requeueAfter, err := manager.refreshQuotaUsage(context.TODO(), nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			assert.LessOrEqual(t, requeueAfter, 5*time.Minute)
+			assert.Positive(t, requeueAfter)
+			assert.Equal(t, usage, getStoredAnnotationJSON[QuotaUsage](t, fakeClient, namespaceName, spacename, QuotaUsageAnnotationKey))
+		})
+	})
+
+	t.Run("annotation not updated when the usage did not change", func(t *testing.T) {
+		// given
+		lastUpdateTime := metav1.NewTime(time.Now().Add(-10 * time.Minute).Truncate(time.Second))
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withClusterResources("abcde11"))
+		nsTmplSet.Status.ProvisionedNamespaces = provisionedNamespaces
+		nsTmplSet.Annotations = map[string]string{}
+		previous := QuotaUsage{
+			Resources: []ResourceUsage{
+				{Quota: "ClusterResourceQuota/for-johnsmith", Resource: "count/secrets", Used: "8", Hard: "10", Percent: 80, ThresholdReached: 80},
+				{Quota: "ResourceQuota/johnsmith-dev/compute-deploy", Resource: "limits.cpu", Used: "1900m", Hard: "2", Percent: 95, ThresholdReached: 95},
+				{Quota: "ResourceQuota/johnsmith-dev/compute-deploy", Resource: "limits.memory", Used: "1Gi", Hard: "4Gi", Percent: 25},
+			},
+			LastUpdateTime: lastUpdateTime,
+		}
+		data, err := json.Marshal(previous)
+		require.NoError(t, err)
+		nsTmplSet.Annotations[QuotaUsageAnnotationKey] = string(data)
+		manager, fakeClient := prepareController(t, nsTmplSet, rq, crq, config(t, map[string]string{
+			QuotaUsageRefreshPeriodAnnotationKey: "5m",
+			QuotaUsageThresholdsAnnotationKey:    "80,95",
+		}))
+		_, err = membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		resetRefreshes(t)
+
+		// when
+		requeueAfter, err := manager.refreshQuotaUsage(context.TODO(), nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, requeueAfter)
+		assert.Equal(t, previous, getStoredAnnotationJSON[QuotaUsage](t, fakeClient, namespaceName, spacename, QuotaUsageAnnotationKey))
+		// the thresholds were already reached
+		assert.Empty(t, manager.Recorder.(*record.FakeRecorder).Events)
+
+		t.Run("not refreshed again before the end of the period", func(t *testing.T) {
+			// when
+			requeueAfter, err := manager.refreshQuotaUsage(context.TODO(), nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			assert.Less(t, requeueAfter, 5*time.Minute)
+			assert.Positive(t, requeueAfter)
+		})
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced", withClusterResources("abcde11"))
+		nsTmplSet.Status.ProvisionedNamespaces = provisionedNamespaces
+		manager, fakeClient := prepareController(t, nsTmplSet, rq, crq, config(t, nil))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		requeueAfter, err := manager.refreshQuotaUsage(context.TODO(), nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, requeueAfter)
+		assert.NotContains(t, nsTmplSet.GetAnnotations(), QuotaUsageAnnotationKey)
+	})
+
+	t.Run("requeued by the reconcile", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"))
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, devNS,
+			newRoleBinding(devNS.Name, "crtadmin-pods", spacename), rq,
+			config(t, map[string]string{QuotaUsageRefreshPeriodAnnotationKey: "10m"}))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		resetRefreshes(t)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Minute, result.RequeueAfter)
+		assert.Len(t, getStoredAnnotationJSON[QuotaUsage](t, fakeClient, namespaceName, spacename, QuotaUsageAnnotationKey).Resources, 2)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "advanced")
+		nsTmplSet.Status.ProvisionedNamespaces = provisionedNamespaces
+		manager, fakeClient := prepareController(t, nsTmplSet, rq, config(t, map[string]string{QuotaUsageRefreshPeriodAnnotationKey: "5m"}))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		fakeClient.MockList = func(ctx context.Context, list runtimeclient.ObjectList, opts ...runtimeclient.ListOption) error {
+			return assert.AnError
+		}
+
+		// when
+		_, err = manager.refreshQuotaUsage(context.TODO(), nsTmplSet)
+
+		// then
+		require.ErrorIs(t, err, assert.AnError)
+		assert.EqualError(t, err, "unable to list the ResourceQuotas of the space in namespace 'johnsmith-dev': "+assert.AnError.Error())
+	})
+}
@@ -0,0 +1,193 @@
+package nstemplateset
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	applycl "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// RecreateOnImmutableChangeAnnotationKey is the annotation set on the objects of the tier templates which can safely be deleted and
+	// re-created when their new version changes an immutable field (eg, the `roleRef` of a RoleBinding)
+	RecreateOnImmutableChangeAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "recreate-on-immutable-change"
+	// RecreatableKindsAnnotationKey is the annotation on the MemberOperatorConfig with the comma-separated kinds of objects
+	// (in the `Kind.group` format, eg, `RoleBinding.rbac.authorization.k8s.io,Deployment.apps`) which can safely be deleted and
+	// re-created when their new version changes an immutable field, regardless of the annotation on the objects themselves
+	RecreatableKindsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "recreatable-kinds"
+	// RecreatedObjectsAnnotationKey is the annotation set on the NSTemplateSet with the (JSON-encoded) list of the objects
+	// that were re-created because of a change of an immutable field, the most recent first
+	RecreatedObjectsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "recreated-objects"
+
+	// ObjectRecreatedReason is the reason of the Events emitted when an object was re-created
+	ObjectRecreatedReason = "ObjectRecreated"
+
+	// maxRecreatedObjects is the maximum number of recreated objects kept in the annotation of the NSTemplateSet
+	maxRecreatedObjects = 10
+
+	// recreatePendingRequeueDelay is the delay after which the NSTemplateSet is reconciled again when a deleted object
+	// is still present in the cluster and thus cannot be re-created yet
+	recreatePendingRequeueDelay = 2 * time.Second
+)
+
+// recreatePendingError is returned when an object was deleted to be re-created but is still present in the cluster
+// (eg, because of its finalizers), so it cannot be re-created yet.
+type recreatePendingError struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (e *recreatePendingError) Error() string {
+	if e.namespace == "" {
+		return fmt.Sprintf("waiting for %s '%s' to be deleted before recreating it", e.kind, e.name)
+	}
+	return fmt.Sprintf("waiting for %s '%s' in namespace '%s' to be deleted before recreating it", e.kind, e.name, e.namespace)
+}
+
+// RecreatedObject is an object that was deleted and re-created because its new version changed an immutable field
+type RecreatedObject struct {
+	GVK       string      `json:"gvk"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Reason    string      `json:"reason"`
+	Time      metav1.Time `json:"time"`
+}
+
+// recreatedFunc is called after an object was re-created, with the error which caused the re-creation
+type recreatedFunc func(object runtimeclient.Object, cause error) error
+
+// isImmutableFieldError returns `true` if the given error was returned by the API server because the update of an object
+// changed an immutable field, ie, if it is an `Invalid` error with a `FieldValueInvalid` cause about an immutable field
+// (eg, `field is immutable` or `cannot change roleRef`)
+func isImmutableFieldError(err error) bool {
+	if !errors.IsInvalid(err) {
+		return false
+	}
+	var statusErr errors.APIStatus
+	if !stderrors.As(err, &statusErr) || statusErr.Status().Details == nil {
+		return false
+	}
+	for _, cause := range statusErr.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldValueInvalid &&
+			(strings.Contains(cause.Message, validation.FieldImmutableErrorMsg) || strings.Contains(cause.Message, "cannot change")) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRecreatable returns `true` if the given object can be deleted and re-created, ie, if it is annotated as such
+// or if its kind is in the allowlist configured in the MemberOperatorConfig
+func isRecreatable(object runtimeclient.Object) bool {
+	if object.GetAnnotations()[RecreateOnImmutableChangeAnnotationKey] == "true" {
+		return true
+	}
+	gk := object.GetObjectKind().GroupVersionKind().GroupKind()
+	return slices.ContainsFunc(memberOperatorConfigList(RecreatableKindsAnnotationKey), func(kind string) bool {
+		return schema.ParseGroupKind(kind) == gk
+	})
+}
+
+// recreate deletes the given object and applies it again once the deleted object is gone, ie, when it is not found anymore
+// or when it was re-created with another UID in the meantime. It returns `true` if the object was deleted by this call,
+// along with a *recreatePendingError if the deleted object is still present in the cluster.
+func (c APIClient) recreate(ctx context.Context, applyClient *applycl.ApplyClient, object runtimeclient.Object, newLabels map[string]string, cause error) (bool, error) {
+	logger := log.FromContext(ctx).WithValues("object_namespace", object.GetNamespace(), "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName())
+	deleted := false
+	existing := object.DeepCopyObject().(runtimeclient.Object)
+	if err := c.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(object), existing); err != nil && !errors.IsNotFound(err) {
+		return false, errs.Wrapf(err, "unable to get the object to recreate it")
+	} else if err == nil {
+		// the object may already be being deleted since a previous reconcile
+		if existing.GetDeletionTimestamp() == nil {
+			logger.Info("the object changed an immutable field - deleting it to recreate it...", "cause", cause.Error())
+			if err := c.Client.Delete(ctx, existing, runtimeclient.PropagationPolicy(metav1.DeletePropagationBackground),
+				runtimeclient.Preconditions{UID: ptr.To(existing.GetUID())}); err != nil && !errors.IsNotFound(err) {
+				return false, errs.Wrapf(err, "unable to delete the object to recreate it")
+			}
+			deleted = true
+		}
+		if gone, err := c.isGone(ctx, existing); err != nil {
+			return deleted, errs.Wrapf(err, "unable to check the deletion of the object to recreate it")
+		} else if !gone {
+			logger.Info("the object to recreate is not deleted yet")
+			return deleted, &recreatePendingError{
+				kind:      object.GetObjectKind().GroupVersionKind().Kind,
+				namespace: object.GetNamespace(),
+				name:      object.GetName(),
+			}
+		}
+	}
+	logger.Info("recreating the object...")
+	if _, err := applyClient.Apply(ctx, []runtimeclient.Object{object}, newLabels); err != nil {
+		return deleted, errs.Wrapf(err, "unable to recreate the object")
+	}
+	return deleted, nil
+}
+
+// isGone returns `true` if the given deleted object is not found anymore, or if it was replaced by an object with another UID
+func (c APIClient) isGone(ctx context.Context, deleted runtimeclient.Object) (bool, error) {
+	current := deleted.DeepCopyObject().(runtimeclient.Object)
+	if err := c.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(deleted), current); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return current.GetUID() != deleted.GetUID(), nil
+}
+
+// applyTemplateObjects applies the given objects of the templates of the NSTemplateSet, recreating the objects which
+// changed an immutable field if they are recreatable. The recreations are recorded in Events and in the annotation of the NSTemplateSet.
+func (r *statusManager) applyTemplateObjects(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, objects []runtimeclient.Object, newLabels map[string]string) (bool, error) {
+	return r.applyToolchainObjects(ctx, objects, newLabels, func(object runtimeclient.Object, cause error) error {
+		return r.recordRecreatedObject(ctx, nsTmplSet, object, cause)
+	})
+}
+
+// recordRecreatedObject emits an Event and adds the given object to the list of recreated objects of the NSTemplateSet.
+// It is called once the object was deleted, even if it cannot be re-created before a later reconcile.
+func (r *statusManager) recordRecreatedObject(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, object runtimeclient.Object, cause error) error {
+	gvk, err := apiutil.GVKForObject(object, r.Scheme)
+	if err != nil {
+		gvk = object.GetObjectKind().GroupVersionKind()
+	}
+	if r.Recorder != nil {
+		description := fmt.Sprintf("%s '%s'", gvk.Kind, object.GetName())
+		if object.GetNamespace() != "" {
+			description += fmt.Sprintf(" in namespace '%s'", object.GetNamespace())
+		}
+		r.Recorder.Eventf(nsTmplSet, corev1.EventTypeNormal, ObjectRecreatedReason, "recreated %s since an immutable field changed: %s",
+			description, cause.Error())
+	}
+	recreated := append([]RecreatedObject{{
+		GVK:       gvk.String(),
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		Reason:    cause.Error(),
+		Time:      metav1.Now(),
+	}}, getAnnotationJSON[[]RecreatedObject](ctx, nsTmplSet, RecreatedObjectsAnnotationKey)...)
+	if len(recreated) > maxRecreatedObjects {
+		recreated = recreated[:maxRecreatedObjects]
+	}
+	if err := r.setAnnotationJSON(ctx, nsTmplSet, RecreatedObjectsAnnotationKey, recreated); err != nil {
+		return errs.Wrapf(err, "unable to record the recreation of %s '%s'", gvk.Kind, object.GetName())
+	}
+	return nil
+}
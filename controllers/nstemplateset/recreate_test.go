@@ -0,0 +1,258 @@
+package nstemplateset
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	errs "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyTemplateObjectsWithImmutableFieldChange(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	newBinding := func(roleName string, annotations map[string]string) *rbacv1.RoleBinding {
+		rb := newRoleBinding(spacename+"-dev", "crtadmin-pods", spacename)
+		rb.TypeMeta = metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"}
+		rb.Annotations = annotations
+		rb.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: roleName}
+		return rb
+	}
+	// the fake client does not validate the immutable fields, hence the mocked error when the roleRef changes
+	immutableRoleRef := func(fakeClient *test.FakeClient) {
+		fakeClient.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+			if rb, ok := obj.(*rbacv1.RoleBinding); ok {
+				existing := &rbacv1.RoleBinding{}
+				if err := fakeClient.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(rb), existing); err == nil && existing.RoleRef != rb.RoleRef {
+					return apierrors.NewInvalid(schema.GroupKind{Group: rbacv1.GroupName, Kind: "RoleBinding"}, rb.Name,
+						field.ErrorList{field.Invalid(field.NewPath("roleRef"), rb.RoleRef, "cannot change roleRef")})
+				}
+			}
+			return fakeClient.Client.Update(ctx, obj, opts...)
+		}
+	}
+	config := func(t *testing.T, annotations map[string]string) *toolchainv1alpha1.MemberOperatorConfig {
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t)
+		cfg.Annotations = annotations
+		return cfg
+	}
+
+	t.Run("recreated when annotated", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet, newBinding("viewer", nil), config(t, nil))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		immutableRoleRef(fakeClient)
+
+		// when
+		applied, err := manager.applyTemplateObjects(context.TODO(), nsTmplSet,
+			[]runtimeclient.Object{newBinding("admin", map[string]string{RecreateOnImmutableChangeAnnotationKey: "true"})}, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, applied)
+		assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "admin")
+		recreated := getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey)
+		require.Len(t, recreated, 1)
+		assert.Equal(t, "rbac.authorization.k8s.io/v1, Kind=RoleBinding", recreated[0].GVK)
+		assert.Equal(t, spacename+"-dev", recreated[0].Namespace)
+		assert.Equal(t, "crtadmin-pods", recreated[0].Name)
+		assert.Contains(t, recreated[0].Reason, "cannot change roleRef")
+		events := manager.Recorder.(*record.FakeRecorder).Events
+		require.Len(t, events, 1)
+		assert.Contains(t, <-events, "Normal ObjectRecreated recreated RoleBinding 'crtadmin-pods' in namespace 'johnsmith-dev' since an immutable field changed")
+	})
+
+	t.Run("recreated when the kind is configured", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet, newBinding("viewer", nil),
+			config(t, map[string]string{RecreatableKindsAnnotationKey: "Deployment.apps,RoleBinding.rbac.authorization.k8s.io"}))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		immutableRoleRef(fakeClient)
+
+		// when
+		_, err = manager.applyTemplateObjects(context.TODO(), nsTmplSet, []runtimeclient.Object{newBinding("admin", nil)}, nil)
+
+		// then
+		require.NoError(t, err)
+		assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "admin")
+		assert.Len(t, getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey), 1)
+	})
+
+	t.Run("recreated once the deleted object is gone", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		existing := newBinding("viewer", nil)
+		existing.Finalizers = []string{"test/finalizer"}
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet, existing, config(t, nil))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		immutableRoleRef(fakeClient)
+		objs := []runtimeclient.Object{newBinding("admin", map[string]string{RecreateOnImmutableChangeAnnotationKey: "true"})}
+
+		// when
+		_, err = manager.applyTemplateObjects(context.TODO(), nsTmplSet, objs, nil)
+
+		// then
+		var recreatePending *recreatePendingError
+		require.ErrorAs(t, err, &recreatePending)
+		assert.EqualError(t, err, "waiting for RoleBinding 'crtadmin-pods' in namespace 'johnsmith-dev' to be deleted before recreating it")
+		assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "viewer")
+		assert.Len(t, getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey), 1)
+
+		t.Run("still pending while the object is being deleted", func(t *testing.T) {
+			// when
+			_, err := manager.applyTemplateObjects(context.TODO(), nsTmplSet, objs, nil)
+
+			// then
+			require.ErrorAs(t, err, &recreatePending)
+			assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "viewer")
+			// the recreation was recorded only once
+			assert.Len(t, getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey), 1)
+		})
+
+		t.Run("recreated when the object is gone", func(t *testing.T) {
+			// given
+			rb := &rbacv1.RoleBinding{}
+			require.NoError(t, fakeClient.Client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(existing), rb))
+			rb.Finalizers = nil
+			require.NoError(t, fakeClient.Client.Update(context.TODO(), rb))
+
+			// when
+			applied, err := manager.applyTemplateObjects(context.TODO(), nsTmplSet, objs, nil)
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, applied)
+			assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "admin")
+			assert.Len(t, getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey), 1)
+		})
+	})
+
+	t.Run("not recreated", func(t *testing.T) {
+
+		t.Run("when not recreatable", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+			manager, fakeClient := prepareStatusManager(t, nsTmplSet, newBinding("viewer", nil),
+				config(t, map[string]string{RecreatableKindsAnnotationKey: "Deployment.apps"}))
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+			immutableRoleRef(fakeClient)
+
+			// when
+			_, err = manager.applyTemplateObjects(context.TODO(), nsTmplSet, []runtimeclient.Object{newBinding("admin", nil)}, nil)
+
+			// then
+			require.ErrorContains(t, err, "cannot change roleRef")
+			assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "viewer")
+			assert.Empty(t, getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey))
+		})
+
+		t.Run("when the error is not about an immutable field", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+			manager, fakeClient := prepareStatusManager(t, nsTmplSet, newBinding("viewer", nil), config(t, nil))
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+			fakeClient.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				return apierrors.NewInvalid(schema.GroupKind{Group: rbacv1.GroupName, Kind: "RoleBinding"}, obj.GetName(),
+					field.ErrorList{field.Required(field.NewPath("subjects").Index(0).Child("name"), "")})
+			}
+
+			// when
+			_, err = manager.applyTemplateObjects(context.TODO(), nsTmplSet,
+				[]runtimeclient.Object{newBinding("admin", map[string]string{RecreateOnImmutableChangeAnnotationKey: "true"})}, nil)
+
+			// then
+			require.ErrorContains(t, err, "Required value")
+			assertRoleRef(t, fakeClient, spacename+"-dev", "crtadmin-pods", "viewer")
+		})
+	})
+}
+
+func assertRoleRef(t *testing.T, cl runtimeclient.Client, namespace, name, roleName string) {
+	rb := &rbacv1.RoleBinding{}
+	require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, rb))
+	assert.Equal(t, roleName, rb.RoleRef.Name)
+}
+
+func TestRecordRecreatedObject(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	t.Run("cluster-scoped object", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic")
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+		crb := &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: spacename + "-tekton-view"},
+		}
+
+		// when
+		err := manager.recordRecreatedObject(context.TODO(), nsTmplSet, crb, errors.New("cannot change roleRef"))
+
+		// then
+		require.NoError(t, err)
+		recreated := getStoredAnnotationJSON[[]RecreatedObject](t, fakeClient, namespaceName, spacename, RecreatedObjectsAnnotationKey)
+		require.Len(t, recreated, 1)
+		assert.Empty(t, recreated[0].Namespace)
+		events := manager.Recorder.(*record.FakeRecorder).Events
+		require.Len(t, events, 1)
+		assert.Equal(t, "Normal ObjectRecreated recreated ClusterRoleBinding 'johnsmith-tekton-view' since an immutable field changed: cannot change roleRef", <-events)
+	})
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	gk := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+	t.Run("immutable field", func(t *testing.T) {
+		err := apierrors.NewInvalid(gk, "test", field.ErrorList{field.Invalid(field.NewPath("spec", "selector"), "app=test", "field is immutable")})
+		assert.True(t, isImmutableFieldError(errs.Wrap(err, "unable to update")))
+	})
+
+	t.Run("roleRef changed", func(t *testing.T) {
+		err := apierrors.NewInvalid(gk, "test", field.ErrorList{field.Invalid(field.NewPath("roleRef"), "edit", "cannot change roleRef")})
+		assert.True(t, isImmutableFieldError(err))
+	})
+
+	t.Run("other invalid value", func(t *testing.T) {
+		err := apierrors.NewInvalid(gk, "test", field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0")})
+		assert.False(t, isImmutableFieldError(err))
+	})
+
+	t.Run("immutable in the value of another cause", func(t *testing.T) {
+		err := apierrors.NewInvalid(gk, "test", field.ErrorList{field.Required(field.NewPath("metadata", "annotations", "immutable"), "field is immutable")})
+		assert.False(t, isImmutableFieldError(err))
+	})
+
+	t.Run("not an invalid error", func(t *testing.T) {
+		assert.False(t, isImmutableFieldError(apierrors.NewBadRequest("field is immutable")))
+		assert.False(t, isImmutableFieldError(assert.AnError))
+	})
+}
@@ -0,0 +1,165 @@
+package nstemplateset
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/metrics"
+	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RenderCacheSizeAnnotationKey is the annotation on the MemberOperatorConfig with the maximum number of rendered templates
+	// kept in the cache (defaultRenderCacheSize by default). The least recently used entries are evicted beyond that size.
+	RenderCacheSizeAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "tiertemplate-render-cache-size"
+
+	defaultRenderCacheSize = 1000
+)
+
+// tierTemplateRenderCache is the cache of the objects rendered from the TierTemplates and TierTemplateRevisions,
+// shared by all the reconciles since the same templates are processed for every namespace on every resync.
+var tierTemplateRenderCache = newRenderCache(0)
+
+// InvalidateTierTemplateRenderCache removes the objects rendered from the TierTemplate or TierTemplateRevision
+// with the given name from the cache. It is meant to be called when such a resource changed (or was deleted) on the host cluster.
+func InvalidateTierTemplateRenderCache(templateRef string) {
+	tierTemplateRenderCache.invalidate(templateRef)
+}
+
+// knownFilters are the filters which can be part of the key of the cache, since the filter funcs are not comparable
+var knownFilters = map[uintptr]string{
+	reflect.ValueOf(template.RetainNamespaces).Pointer():       "namespaces",
+	reflect.ValueOf(template.RetainAllButNamespaces).Pointer(): "all-but-namespaces",
+}
+
+type renderCacheKey struct {
+	templateRef     string
+	resourceVersion string
+	params          string
+	filters         string
+}
+
+// newRenderCacheKey returns the key of the objects rendered from the given template with the given params and filters,
+// or `false` if the result can't be cached (ie, unknown resource version or filter).
+func newRenderCacheKey(t *tierTemplate, params map[string]string, filters []template.FilterFunc) (renderCacheKey, bool) {
+	if t.resourceVersion == "" {
+		return renderCacheKey{}, false
+	}
+	filterNames := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		name, found := knownFilters[reflect.ValueOf(filter).Pointer()]
+		if !found {
+			return renderCacheKey{}, false
+		}
+		filterNames = append(filterNames, name)
+	}
+	paramValues := make([]string, 0, len(params))
+	for name, value := range params {
+		paramValues = append(paramValues, name+"="+value)
+	}
+	slices.Sort(paramValues)
+	return renderCacheKey{
+		templateRef:     t.templateRef,
+		resourceVersion: t.resourceVersion,
+		params:          strings.Join(paramValues, "\x00"),
+		filters:         strings.Join(filterNames, ","),
+	}, true
+}
+
+// renderCache is a LRU cache of the rendered objects. The objects are deep-copied when they are added and when they are returned,
+// so that the callers can safely modify them (eg, to set the labels before applying them).
+type renderCache struct {
+	lock    sync.Mutex
+	size    int // fixed size, or zero to use the size configured in the MemberOperatorConfig (see capacity)
+	entries map[renderCacheKey]*list.Element
+	lru     *list.List // most recently used first
+}
+
+type renderCacheEntry struct {
+	key  renderCacheKey
+	objs []runtimeclient.Object
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{
+		size:    size,
+		entries: map[renderCacheKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// capacity returns the maximum number of entries of the cache: its fixed size if set, otherwise the size configured
+// in the MemberOperatorConfig, or defaultRenderCacheSize
+func (c *renderCache) capacity() int {
+	if c.size > 0 {
+		return c.size
+	}
+	if size := memberOperatorConfigInt(context.TODO(), RenderCacheSizeAnnotationKey); size > 0 {
+		return size
+	}
+	return defaultRenderCacheSize
+}
+
+func (c *renderCache) get(key renderCacheKey) ([]runtimeclient.Object, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, found := c.entries[key]
+	if !found {
+		metrics.TierTemplateRenderCacheMissesCounter.Inc()
+		return nil, false
+	}
+	metrics.TierTemplateRenderCacheHitsCounter.Inc()
+	c.lru.MoveToFront(element)
+	return deepCopy(element.Value.(*renderCacheEntry).objs), true
+}
+
+func (c *renderCache) add(key renderCacheKey, objs []runtimeclient.Object) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, found := c.entries[key]; found {
+		element.Value.(*renderCacheEntry).objs = deepCopy(objs)
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&renderCacheEntry{key: key, objs: deepCopy(objs)})
+	capacity := c.capacity()
+	for c.lru.Len() > capacity {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *renderCache) invalidate(templateRef string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, element := range c.entries {
+		if key.templateRef == templateRef {
+			c.remove(element)
+		}
+	}
+}
+
+func (c *renderCache) purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = map[renderCacheKey]*list.Element{}
+	c.lru.Init()
+}
+
+func (c *renderCache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*renderCacheEntry).key)
+}
+
+func deepCopy(objs []runtimeclient.Object) []runtimeclient.Object {
+	copies := make([]runtimeclient.Object, 0, len(objs))
+	for _, obj := range objs {
+		copies = append(copies, obj.DeepCopyObject().(runtimeclient.Object))
+	}
+	return copies
+}
@@ -0,0 +1,222 @@
+package nstemplateset
+
+import (
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/metrics"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRenderCache(t *testing.T) {
+	newKey := func(templateRef string) renderCacheKey {
+		return renderCacheKey{templateRef: templateRef, resourceVersion: "1"}
+	}
+	objs := []runtimeclient.Object{newConfigMapObject("johnsmith-dev", "cm")}
+
+	t.Run("returns deep copies", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		cache := newRenderCache(10)
+		cache.add(newKey("basic-dev-abcde11"), objs)
+		objs[0].SetLabels(map[string]string{"modified": "after-add"})
+
+		// when
+		cached, found := cache.get(newKey("basic-dev-abcde11"))
+
+		// then
+		require.True(t, found)
+		require.Len(t, cached, 1)
+		assert.Empty(t, cached[0].GetLabels())
+		cached[0].SetLabels(map[string]string{"modified": "after-get"})
+		cachedAgain, _ := cache.get(newKey("basic-dev-abcde11"))
+		assert.Empty(t, cachedAgain[0].GetLabels())
+		assert.InDelta(t, float64(2), promtestutil.ToFloat64(metrics.TierTemplateRenderCacheHitsCounter), 0.01)
+		assert.InDelta(t, float64(0), promtestutil.ToFloat64(metrics.TierTemplateRenderCacheMissesCounter), 0.01)
+	})
+
+	t.Run("evicts the least recently used entry", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		cache := newRenderCache(2)
+		cache.add(newKey("basic-dev-abcde11"), objs)
+		cache.add(newKey("basic-stage-abcde11"), objs)
+		_, _ = cache.get(newKey("basic-dev-abcde11"))
+
+		// when
+		cache.add(newKey("basic-clusterresources-abcde11"), objs)
+
+		// then
+		_, found := cache.get(newKey("basic-dev-abcde11"))
+		assert.True(t, found)
+		_, found = cache.get(newKey("basic-stage-abcde11"))
+		assert.False(t, found)
+		_, found = cache.get(newKey("basic-clusterresources-abcde11"))
+		assert.True(t, found)
+		assert.InDelta(t, float64(3), promtestutil.ToFloat64(metrics.TierTemplateRenderCacheHitsCounter), 0.01)
+		assert.InDelta(t, float64(1), promtestutil.ToFloat64(metrics.TierTemplateRenderCacheMissesCounter), 0.01)
+	})
+
+	t.Run("invalidates the entries of a template", func(t *testing.T) {
+		// given
+		cache := newRenderCache(10)
+		cache.add(newKey("basic-dev-abcde11"), objs)
+		cache.add(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: "SPACE_NAME=johnsmith"}, objs)
+		cache.add(newKey("basic-stage-abcde11"), objs)
+
+		// when
+		cache.invalidate("basic-dev-abcde11")
+
+		// then
+		_, found := cache.get(newKey("basic-dev-abcde11"))
+		assert.False(t, found)
+		_, found = cache.get(newKey("basic-stage-abcde11"))
+		assert.True(t, found)
+		assert.Equal(t, 1, cache.lru.Len())
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+		t.Cleanup(restore)
+
+		t.Run("evicts beyond the default size", func(t *testing.T) {
+			// given
+			commonconfig.NewMemberOperatorConfigWithReset(t)
+			cache := newRenderCache(0)
+
+			// when
+			for i := 0; i <= defaultRenderCacheSize; i++ {
+				cache.add(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: fmt.Sprintf("SPACE_NAME=space-%d", i)}, objs)
+			}
+
+			// then
+			assert.Equal(t, defaultRenderCacheSize, cache.lru.Len())
+			_, found := cache.get(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: "SPACE_NAME=space-0"})
+			assert.False(t, found)
+		})
+
+		t.Run("has a default size", func(t *testing.T) {
+			// given
+			commonconfig.NewMemberOperatorConfigWithReset(t)
+			cache := newRenderCache(0)
+			cache.add(newKey("basic-dev-abcde11"), objs)
+
+			// when
+			capacity := cache.capacity()
+
+			// then
+			assert.Equal(t, defaultRenderCacheSize, capacity)
+		})
+
+		t.Run("configured in the MemberOperatorConfig", func(t *testing.T) {
+			// given
+			config := commonconfig.NewMemberOperatorConfigWithReset(t)
+			config.Annotations = map[string]string{
+				RenderCacheSizeAnnotationKey: "2",
+			}
+			_, err := membercfg.ForceLoadConfiguration(test.NewFakeClient(t, config))
+			require.NoError(t, err)
+			cache := newRenderCache(0)
+
+			// when
+			cache.add(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: "SPACE_NAME=john"}, objs)
+			cache.add(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: "SPACE_NAME=jane"}, objs)
+			cache.add(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: "SPACE_NAME=jack"}, objs)
+
+			// then
+			assert.Equal(t, 2, cache.capacity())
+			assert.Equal(t, 2, cache.lru.Len())
+			_, found := cache.get(renderCacheKey{templateRef: "basic-dev-abcde11", resourceVersion: "1", params: "SPACE_NAME=john"})
+			assert.False(t, found)
+		})
+	})
+}
+
+func TestNewRenderCacheKey(t *testing.T) {
+	tmpl := &tierTemplate{templateRef: "basic-dev-abcde11", resourceVersion: "42"}
+
+	t.Run("same key regardless of the order of the params", func(t *testing.T) {
+		// when
+		key1, ok1 := newRenderCacheKey(tmpl, map[string]string{SpaceName: "johnsmith", Username: "john"}, []template.FilterFunc{template.RetainNamespaces})
+		key2, ok2 := newRenderCacheKey(tmpl, map[string]string{Username: "john", SpaceName: "johnsmith"}, []template.FilterFunc{template.RetainNamespaces})
+
+		// then
+		require.True(t, ok1)
+		require.True(t, ok2)
+		assert.Equal(t, key1, key2)
+	})
+
+	t.Run("different keys for different filters", func(t *testing.T) {
+		// when
+		key1, _ := newRenderCacheKey(tmpl, map[string]string{SpaceName: "johnsmith"}, []template.FilterFunc{template.RetainNamespaces})
+		key2, _ := newRenderCacheKey(tmpl, map[string]string{SpaceName: "johnsmith"}, []template.FilterFunc{template.RetainAllButNamespaces})
+		key3, _ := newRenderCacheKey(tmpl, map[string]string{SpaceName: "johnsmith"}, nil)
+
+		// then
+		assert.NotEqual(t, key1, key2)
+		assert.NotEqual(t, key1, key3)
+		assert.NotEqual(t, key2, key3)
+	})
+
+	t.Run("not cacheable with unknown filter", func(t *testing.T) {
+		// when
+		_, ok := newRenderCacheKey(tmpl, map[string]string{SpaceName: "johnsmith"}, []template.FilterFunc{func(runtime.RawExtension) bool { return true }})
+
+		// then
+		assert.False(t, ok)
+	})
+
+	t.Run("not cacheable without resource version", func(t *testing.T) {
+		// when
+		_, ok := newRenderCacheKey(&tierTemplate{templateRef: "basic-dev-abcde11"}, map[string]string{SpaceName: "johnsmith"}, nil)
+
+		// then
+		assert.False(t, ok)
+	})
+}
+
+func TestProcessWithRenderCache(t *testing.T) {
+	// given
+	metrics.Reset()
+	tierTemplateRenderCache.purge()
+	t.Cleanup(tierTemplateRenderCache.purge)
+	tmpl := &tierTemplate{
+		templateRef:     "basic-dev-abcde11-ttr",
+		resourceVersion: "1",
+		ttr: &toolchainv1alpha1.TierTemplateRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: "basic-dev-abcde11-ttr"},
+			Spec: toolchainv1alpha1.TierTemplateRevisionSpec{
+				TemplateObjects: []runtime.RawExtension{
+					{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"{{.SPACE_NAME}}-config","namespace":"{{.SPACE_NAME}}-dev"}}`)},
+				},
+			},
+		},
+	}
+
+	// when
+	objs, err := tmpl.process(scheme.Scheme, map[string]string{SpaceName: "johnsmith"})
+	require.NoError(t, err)
+	cachedObjs, err := tmpl.process(scheme.Scheme, map[string]string{SpaceName: "johnsmith"})
+	require.NoError(t, err)
+	otherObjs, err := tmpl.process(scheme.Scheme, map[string]string{SpaceName: "janedoe"})
+	require.NoError(t, err)
+
+	// then
+	assert.Equal(t, objs, cachedObjs)
+	assert.NotSame(t, objs[0], cachedObjs[0])
+	assert.Equal(t, "johnsmith-config", cachedObjs[0].GetName())
+	assert.Equal(t, "janedoe-config", otherObjs[0].GetName())
+	assert.InDelta(t, float64(1), promtestutil.ToFloat64(metrics.TierTemplateRenderCacheHitsCounter), 0.01)
+	assert.InDelta(t, float64(2), promtestutil.ToFloat64(metrics.TierTemplateRenderCacheMissesCounter), 0.01)
+}
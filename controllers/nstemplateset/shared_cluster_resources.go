@@ -0,0 +1,184 @@
+package nstemplateset
+
+import (
+	"context"
+	"encoding/json"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	applycl "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// SharedClusterResourceAnnotationKey is the annotation set on a cluster-scoped template object to declare that the
+	// resource is shared by all the NSTemplateSets whose templates contain it (e.g., a common ClusterRole).
+	SharedClusterResourceAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "shared"
+	// SharedByAnnotationKey is the annotation set on a shared cluster-scoped resource which holds the references of the spaces
+	// that apply it, as a JSON object mapping the name of each space to the templateRef of the template it was applied from.
+	// The templateRef and tier labels are not set on the shared resources since the spaces may belong to different tiers.
+	SharedByAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "shared-by"
+)
+
+// isShared returns true if the given template object is annotated as a shared cluster-scoped resource
+func isShared(obj runtimeclient.Object) bool {
+	return obj.GetAnnotations()[SharedClusterResourceAnnotationKey] == "true"
+}
+
+// getSharedBy returns the templateRefs of the spaces that reference the given shared resource, indexed by the name of the space
+func getSharedBy(obj runtimeclient.Object) (map[string]string, error) {
+	sharedBy := map[string]string{}
+	value, found := obj.GetAnnotations()[SharedByAnnotationKey]
+	if !found || value == "" {
+		return sharedBy, nil
+	}
+	if err := json.Unmarshal([]byte(value), &sharedBy); err != nil {
+		return nil, errs.Wrapf(err, "unable to unmarshal the '%s' annotation of the shared cluster resource '%s'", SharedByAnnotationKey, obj.GetName())
+	}
+	return sharedBy, nil
+}
+
+// setSharedBy sets the templateRefs of the spaces that reference the given shared resource (the JSON keys are sorted by the encoder)
+func setSharedBy(obj runtimeclient.Object, sharedBy map[string]string) error {
+	value, err := json.Marshal(sharedBy)
+	if err != nil {
+		return errs.Wrapf(err, "unable to marshal the '%s' annotation of the shared cluster resource '%s'", SharedByAnnotationKey, obj.GetName())
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SharedByAnnotationKey] = string(value)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// mapSharedToSpaces returns the requests of all the NSTemplateSets which reference the given shared cluster resource.
+// The shared resources have no space label, so they can't be mapped to their owner by label.
+func mapSharedToSpaces(_ context.Context, obj runtimeclient.Object) []reconcile.Request {
+	if _, found := obj.GetAnnotations()[SharedByAnnotationKey]; !found {
+		return nil
+	}
+	sharedBy, err := getSharedBy(obj)
+	if err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(sharedBy))
+	for spacename := range sharedBy {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: spacename}})
+	}
+	return requests
+}
+
+// applyShared creates or updates the given shared cluster-scoped resource and records the templateRef of the space in its references.
+// Unlike the resources owned by a single NSTemplateSet, the shared resources don't get the space, tier and templateRef labels so that
+// they are not considered as belonging to any particular space.
+// Since several NSTemplateSets may update the same resource concurrently, the existing resource is updated with the resourceVersion
+// it was fetched with, and fetched again when the update failed because of a conflict.
+func (r *clusterResourcesManager) applyShared(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, tierTemplate *tierTemplate, object runtimeclient.Object) error {
+	labels := map[string]string{
+		toolchainv1alpha1.TypeLabelKey:     toolchainv1alpha1.ClusterResourcesTemplateType,
+		toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
+	}
+	err := retry.OnError(retry.DefaultRetry, isConflictOrAlreadyExists, func() error {
+		existing := object.DeepCopyObject().(runtimeclient.Object)
+		if err := r.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(object), existing); err != nil {
+			if !errors.IsNotFound(err) {
+				return errs.Wrapf(err, "failed to get the shared cluster resource")
+			}
+			toCreate := object.DeepCopyObject().(runtimeclient.Object)
+			if err := setSharedBy(toCreate, map[string]string{nsTmplSet.GetName(): tierTemplate.templateRef}); err != nil {
+				return err
+			}
+			log.FromContext(ctx).Info("creating shared cluster resource", "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName())
+			_, err := r.applyTemplateObjects(ctx, nsTmplSet, []runtimeclient.Object{toCreate}, labels)
+			return err
+		}
+		toUpdate := object.DeepCopyObject().(runtimeclient.Object)
+		applycl.MergeLabels(toUpdate, labels)
+		configuration := applycl.GetNewConfiguration(toUpdate)
+		applycl.MergeAnnotations(toUpdate, map[string]string{applycl.LastAppliedConfigurationAnnotationKey: configuration})
+		sharedBy, err := getSharedBy(existing)
+		if err != nil {
+			return err
+		}
+		if templateRef, found := sharedBy[nsTmplSet.GetName()]; found && templateRef == tierTemplate.templateRef &&
+			existing.GetAnnotations()[applycl.LastAppliedConfigurationAnnotationKey] == configuration {
+			return nil // already up-to-date
+		}
+		sharedBy[nsTmplSet.GetName()] = tierTemplate.templateRef
+		if err := setSharedBy(toUpdate, sharedBy); err != nil {
+			return err
+		}
+		toUpdate.SetResourceVersion(existing.GetResourceVersion())
+		log.FromContext(ctx).Info("updating shared cluster resource", "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName(), "shared_by", toUpdate.GetAnnotations()[SharedByAnnotationKey])
+		return newObjectError(applyOperation, toUpdate, r.Client.Update(ctx, toUpdate))
+	})
+	if err != nil {
+		return errs.Wrapf(err, "failed to apply shared cluster resource")
+	}
+	return nil
+}
+
+// releaseShared removes the space from the list of references of the given shared cluster-scoped resource.
+// The resource is deleted when the space was the last one referencing it.
+func (r *clusterResourcesManager) releaseShared(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, object runtimeclient.Object) error {
+	logger := log.FromContext(ctx)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing := object.DeepCopyObject().(runtimeclient.Object)
+		if err := r.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(object), existing); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return errs.Wrapf(err, "failed to get the shared cluster resource '%s'", object.GetName())
+		}
+		sharedBy, err := getSharedBy(existing)
+		if err != nil {
+			return err
+		}
+		if _, found := sharedBy[nsTmplSet.GetName()]; !found {
+			return nil // the space was not referencing the resource, nothing to do
+		}
+		delete(sharedBy, nsTmplSet.GetName())
+		if len(sharedBy) == 0 {
+			logger.Info("deleting shared cluster resource which is no longer referenced", "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName())
+			// the precondition makes sure that the resource was not referenced by another space in the meantime
+			if err := r.Client.Delete(ctx, existing, runtimeclient.Preconditions{ResourceVersion: ptr.To(existing.GetResourceVersion())}); err != nil && !errors.IsNotFound(err) {
+				return errs.Wrapf(newObjectError(deleteOperation, existing, err), "failed to delete the shared cluster resource '%s'", object.GetName())
+			}
+			return nil
+		}
+		if err := setSharedBy(existing, sharedBy); err != nil {
+			return err
+		}
+		logger.Info("releasing shared cluster resource", "object_name", object.GetObjectKind().GroupVersionKind().Kind+"/"+object.GetName(), "shared_by", existing.GetAnnotations()[SharedByAnnotationKey])
+		if err := r.Client.Update(ctx, existing); err != nil {
+			return errs.Wrapf(newObjectError(applyOperation, existing, err), "failed to release the shared cluster resource '%s'", object.GetName())
+		}
+		return nil
+	})
+}
+
+// isConflictOrAlreadyExists returns true if the given error is a conflict, or if the object to create already exists
+// (ie, it was created by another NSTemplateSet in the meantime)
+func isConflictOrAlreadyExists(err error) bool {
+	return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+}
+
+// splitShared splits the given objects into the ones that are shared and the ones that are not
+func splitShared(objs []runtimeclient.Object) (shared []runtimeclient.Object, notShared []runtimeclient.Object) {
+	for _, obj := range objs {
+		if isShared(obj) {
+			shared = append(shared, obj)
+		} else {
+			notShared = append(notShared, obj)
+		}
+	}
+	return shared, notShared
+}
@@ -0,0 +1,329 @@
+package nstemplateset
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	quotav1 "github.com/openshift/api/quota/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSharedClusterResources(t *testing.T) {
+	// given
+	logger := zap.New(zap.UseDevMode(true))
+	log.SetLogger(logger)
+	ctx := log.IntoContext(context.TODO(), logger)
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+	namespaceName := "toolchain-member"
+
+	t.Run("ensure", func(t *testing.T) {
+		t.Run("creates shared resource without space label", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "alice", "shared", withClusterResources("abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet)
+
+			// when
+			err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("for-alice", &quotav1.ClusterResourceQuota{}, WithLabel(toolchainv1alpha1.SpaceLabelKey, "alice")).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{},
+					WithoutLabel(toolchainv1alpha1.SpaceLabelKey),
+					WithLabel(toolchainv1alpha1.ProviderLabelKey, toolchainv1alpha1.ProviderLabelValue),
+					WithoutLabel(toolchainv1alpha1.TemplateRefLabelKey),
+					WithoutLabel(toolchainv1alpha1.TierLabelKey),
+					withSharedBy("alice"),
+					withSharedByTemplateRef("alice", "shared-clusterresources-abcde11"))
+		})
+
+		t.Run("keeps the templateRef of each space", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withClusterResources("abcde13"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice", "bob"))
+
+			// when
+			err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{},
+					withSharedBy("alice,bob"),
+					withSharedByTemplateRef("alice", "shared-clusterresources-abcde11"),
+					withSharedByTemplateRef("bob", "shared-clusterresources-abcde13"))
+		})
+
+		t.Run("adds the space to the existing shared resource", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withClusterResources("abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice", "john"))
+
+			// when
+			err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice,bob,john"))
+		})
+
+		t.Run("keeps the space added concurrently to the existing shared resource", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withClusterResources("abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice"))
+			addSpaceConcurrently(t, cl, "john")
+
+			// when
+			err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice,bob,john"))
+		})
+
+		t.Run("releases the shared resource when it is no longer in the template", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withClusterResources("abcde12"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice", "bob"))
+
+			// when
+			err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice"))
+		})
+
+		t.Run("deletes the shared resource when it is no longer in the template of the last space", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withClusterResources("abcde12"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("bob"))
+
+			// when
+			err := manager.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasNoResource("shared-tekton-view", &rbacv1.ClusterRole{})
+		})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		t.Run("keeps the shared resource when still referenced by other spaces", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withDeletionTs(), withClusterResources("abcde11"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice", "bob", "john"))
+
+			// when
+			err := manager.delete(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice,john"))
+		})
+
+		t.Run("keeps the space added concurrently to the released shared resource", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withDeletionTs(), withClusterResources("abcde11"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice", "bob"))
+			addSpaceConcurrently(t, cl, "john")
+
+			// when
+			err := manager.delete(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice,john"))
+		})
+
+		t.Run("deletes the shared resource when released by the last space", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withDeletionTs(), withClusterResources("abcde11"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("bob"))
+
+			// when
+			err := manager.delete(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasNoResource("shared-tekton-view", &rbacv1.ClusterRole{})
+		})
+
+		t.Run("does not change the shared resource when not referenced by the space", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withDeletionTs(), withClusterResources("abcde11"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice"))
+
+			// when
+			err := manager.delete(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice"))
+		})
+
+		t.Run("does not delete the shared resource not referenced by any space", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withDeletionTs(), withClusterResources("abcde11"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole())
+
+			// when
+			err := manager.delete(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy(""))
+		})
+
+		t.Run("failed to release the shared resource", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, "bob", "shared", withDeletionTs(), withClusterResources("abcde11"), withStatusClusterResourcesInTier("shared", "abcde11"))
+			manager, cl := prepareClusterResourcesManager(t, nsTmplSet, newSharedClusterRole("alice", "bob"))
+			cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*toolchainv1alpha1.NSTemplateSet); ok {
+					return test.Update(ctx, cl, obj, opts...)
+				}
+				return fmt.Errorf("mock error")
+			}
+
+			// when
+			err := manager.delete(ctx, nsTmplSet)
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "failed to release the shared cluster resource 'shared-tekton-view'")
+			AssertThatCluster(t, cl).
+				HasResource("shared-tekton-view", &rbacv1.ClusterRole{}, withSharedBy("alice,bob"))
+		})
+	})
+}
+
+func newSharedClusterRole(sharedBy ...string) *rbacv1.ClusterRole {
+	cr := &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ClusterRole",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "shared-tekton-view",
+			Labels: map[string]string{
+				toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
+			},
+			Annotations: map[string]string{
+				SharedClusterResourceAnnotationKey: "true",
+			},
+		},
+	}
+	refs := map[string]string{}
+	for _, spacename := range sharedBy {
+		refs[spacename] = "shared-clusterresources-abcde11"
+	}
+	if err := setSharedBy(cr, refs); err != nil {
+		panic(err)
+	}
+	return cr
+}
+
+// addSpaceConcurrently adds the given space to the shared ClusterRole right before its first update by the manager,
+// so that the update fails with a conflict
+func addSpaceConcurrently(t *testing.T, cl *test.FakeClient, spacename string) {
+	added := false
+	mockUpdate := cl.MockUpdate
+	cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+		if obj.GetName() == "shared-tekton-view" && !added {
+			added = true
+			cr := &rbacv1.ClusterRole{}
+			require.NoError(t, cl.Client.Get(ctx, client.ObjectKeyFromObject(obj), cr))
+			sharedBy, err := getSharedBy(cr)
+			require.NoError(t, err)
+			sharedBy[spacename] = "shared-clusterresources-abcde11"
+			require.NoError(t, setSharedBy(cr, sharedBy))
+			require.NoError(t, cl.Client.Update(ctx, cr))
+			return apierrors.NewConflict(rbacv1.Resource("clusterroles"), obj.GetName(), fmt.Errorf("the object has been modified"))
+		}
+		return mockUpdate(ctx, obj, opts...)
+	}
+}
+
+// withSharedBy checks the comma-separated names of the spaces that reference the shared resource
+func withSharedBy(expected string) ResourceOption {
+	return func(t test.T, obj client.Object) {
+		sharedBy, err := getSharedBy(obj)
+		require.NoError(t, err)
+		spacenames := slices.Sorted(maps.Keys(sharedBy))
+		assert.Equal(t, expected, strings.Join(spacenames, ","))
+	}
+}
+
+func withSharedByTemplateRef(spacename, expected string) ResourceOption {
+	return func(t test.T, obj client.Object) {
+		sharedBy, err := getSharedBy(obj)
+		require.NoError(t, err)
+		assert.Equal(t, expected, sharedBy[spacename])
+	}
+}
+
+func TestMapSharedToSpaces(t *testing.T) {
+	t.Run("maps to all the spaces referencing the shared resource", func(t *testing.T) {
+		// when
+		requests := mapSharedToSpaces(context.TODO(), newSharedClusterRole("alice", "bob"))
+
+		// then
+		assert.ElementsMatch(t, []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Name: "alice"}},
+			{NamespacedName: types.NamespacedName{Name: "bob"}},
+		}, requests)
+	})
+
+	t.Run("no request when the resource has no references", func(t *testing.T) {
+		// given
+		cr := newSharedClusterRole()
+		delete(cr.Annotations, SharedByAnnotationKey)
+
+		// when
+		requests := mapSharedToSpaces(context.TODO(), cr)
+
+		// then
+		assert.Empty(t, requests)
+	})
+
+	t.Run("no request when the references are invalid", func(t *testing.T) {
+		// given
+		cr := newSharedClusterRole()
+		cr.Annotations[SharedByAnnotationKey] = "alice,bob"
+
+		// when
+		requests := mapSharedToSpaces(context.TODO(), cr)
+
+		// then
+		assert.Empty(t, requests)
+	})
+}
@@ -0,0 +1,277 @@
+package nstemplateset
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/backup"
+	"github.com/codeready-toolchain/member-operator/pkg/utils/memberconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	routev1 "github.com/openshift/api/route/v1"
+	errs "github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SpaceBackupAnnotationKey is the annotation set on the MemberOperatorConfig to enable the backup of the user-created objects
+	// of the namespaces before they are deleted (when the NSTemplateSet is deleted or when a namespace type is removed from the tier).
+	// The value is the storage of the archives: `pvc` (see SpaceBackupDirectoryAnnotationKey) or `s3` (see SpaceBackupS3SecretAnnotationKey).
+	SpaceBackupAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-backup"
+	// SpaceBackupDirectoryAnnotationKey is the annotation set on the MemberOperatorConfig with the directory in which the archives are written
+	// when the `pvc` storage is used, ie, the mount path of the PersistentVolumeClaim in the operator pod.
+	SpaceBackupDirectoryAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-backup-directory"
+	// SpaceBackupS3SecretAnnotationKey is the annotation set on the MemberOperatorConfig with the name of the Secret (in the operator namespace)
+	// which contains the `endpoint`, `bucket`, `region`, `accessKeyID` and `secretAccessKey` of the S3-compatible bucket when the `s3` storage is used.
+	SpaceBackupS3SecretAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-backup-s3-secret"
+	// SpaceBackupIncludeSecretValuesAnnotationKey is the annotation set on the MemberOperatorConfig (with the `true` value)
+	// to include the values of the Secrets in the archives. By default, the values are redacted.
+	SpaceBackupIncludeSecretValuesAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-backup-include-secret-values"
+
+	// SpaceBackupsAnnotationKey is the annotation set on the NSTemplateSet with the references to the archives of its deleted namespaces
+	SpaceBackupsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-backups"
+	// RedactedAnnotationKey is the annotation set on the archived Secrets whose values were redacted
+	RedactedAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "redacted"
+
+	pvcBackupStorage            = "pvc"
+	s3BackupStorage             = "s3"
+	defaultSpaceBackupDirectory = "/var/backups/spaces"
+
+	// maxSpaceBackups is the maximum number of backup references kept in the annotation of the NSTemplateSet
+	maxSpaceBackups = 10
+)
+
+// SpaceBackup is the reference to the archive of a deleted namespace
+type SpaceBackup struct {
+	Namespace string      `json:"namespace"`
+	Location  string      `json:"location"`
+	Objects   int         `json:"objects"`
+	Time      metav1.Time `json:"time"`
+}
+
+// backupObjectKinds are the kinds of objects which are archived.
+// Note: the objects are listed as unstructured objects, so that the (namespace-scoped) cache of the client is bypassed.
+var backupObjectKinds = []schema.GroupVersionKind{
+	appsv1.SchemeGroupVersion.WithKind("Deployment"),
+	corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+	corev1.SchemeGroupVersion.WithKind("Service"),
+	routev1.GroupVersion.WithKind("Route"),
+	corev1.SchemeGroupVersion.WithKind("Secret"),
+}
+
+// generatedConfigMaps are the ConfigMaps which are created in every namespace by the platform
+var generatedConfigMaps = []string{"kube-root-ca.crt", "openshift-service-ca.crt"}
+
+// backupNamespace archives the user-created objects of the given namespace before it is deleted, if the backup is enabled,
+// and adds the reference to the archive in the annotations of the NSTemplateSet.
+func (r *namespacesManager) backupNamespace(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, ns *corev1.Namespace) error {
+	store, err := r.newBackupStore(ctx)
+	if err != nil || store == nil {
+		return err
+	}
+	archive, count, err := r.archiveNamespace(ctx, ns.Name, memberconfig.Annotation(SpaceBackupIncludeSecretValuesAnnotationKey) == "true")
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	location, err := store.Store(ctx, spaceBackupKey(nsTmplSet.Name, ns.Name), archive)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("backed up namespace before its deletion", "namespace", ns.Name, "location", location, "objects", count)
+	return r.addSpaceBackup(ctx, nsTmplSet, SpaceBackup{
+		Namespace: ns.Name,
+		Location:  location,
+		Objects:   count,
+		Time:      now,
+	})
+}
+
+// spaceBackupKey returns the key of the archive of the given namespace of the space in the storage.
+// The key only depends on the names of the space and the namespace, so that the archive can still be found
+// once the NSTemplateSet (and thus the reference in its annotations) is deleted, ie, when the user is deactivated.
+// As a consequence, the archive of a namespace replaces the archive of its previous deletion, if any.
+func spaceBackupKey(spaceName, namespace string) string {
+	return fmt.Sprintf("%s/%s.tar.gz", spaceName, namespace)
+}
+
+// newBackupStore returns the store configured in the MemberOperatorConfig, or `nil` if the backup is disabled
+func (r *namespacesManager) newBackupStore(ctx context.Context) (backup.Store, error) {
+	switch storage := memberconfig.Annotation(SpaceBackupAnnotationKey); storage {
+	case "":
+		return nil, nil
+	case pvcBackupStorage:
+		dir := memberconfig.Annotation(SpaceBackupDirectoryAnnotationKey)
+		if dir == "" {
+			dir = defaultSpaceBackupDirectory
+		}
+		return backup.NewDirectoryStore(dir), nil
+	case s3BackupStorage:
+		name := memberconfig.Annotation(SpaceBackupS3SecretAnnotationKey)
+		if name == "" {
+			return nil, fmt.Errorf("the '%s' annotation must be set on the MemberOperatorConfig when using the '%s' backup storage", SpaceBackupS3SecretAnnotationKey, s3BackupStorage)
+		}
+		namespace, err := configuration.GetWatchNamespace()
+		if err != nil {
+			return nil, err
+		}
+		secret := &corev1.Secret{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			return nil, errs.Wrapf(err, "unable to get the '%s' secret with the settings of the S3 bucket", name)
+		}
+		return backup.NewS3Store(backup.S3Config{
+			Endpoint:        string(secret.Data["endpoint"]),
+			Bucket:          string(secret.Data["bucket"]),
+			Region:          string(secret.Data["region"]),
+			AccessKeyID:     string(secret.Data["accessKeyID"]),
+			SecretAccessKey: string(secret.Data["secretAccessKey"]),
+		}, nil)
+	default:
+		return nil, fmt.Errorf("unknown backup storage '%s' (expected '%s' or '%s')", storage, pvcBackupStorage, s3BackupStorage)
+	}
+}
+
+// archiveNamespace returns a compressed tar archive with the YAML manifests of the user-created objects of the given namespace,
+// along with the number of archived objects.
+func (r *namespacesManager) archiveNamespace(ctx context.Context, namespace string, includeSecretValues bool) ([]byte, int, error) {
+	return newArchive(func(tarWriter *tar.Writer) (int, error) {
+		return r.archiveNamespaceObjects(ctx, tarWriter, namespace, "", includeSecretValues)
+	})
+}
+
+// newArchive returns a compressed tar archive with the content written by the given func, along with the number of files
+func newArchive(write func(*tar.Writer) (int, error)) ([]byte, int, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	count, err := write(tarWriter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return nil, 0, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), count, nil
+}
+
+// archiveNamespaceObjects writes the YAML manifests of the user-created objects of the given namespace in the archive,
+// as `<prefix><kind>/<name>.yaml` files, and returns the number of archived objects.
+func (r *namespacesManager) archiveNamespaceObjects(ctx context.Context, tarWriter *tar.Writer, namespace, prefix string, includeSecretValues bool) (int, error) {
+	count := 0
+	for _, gvk := range backupObjectKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := r.Client.List(ctx, list, runtimeclient.InNamespace(namespace)); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue // eg, Routes on a non-OpenShift cluster
+			}
+			return 0, errs.Wrapf(err, "unable to list the objects to back up in namespace '%s'", namespace)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !isUserObject(obj) {
+				continue
+			}
+			obj.SetGroupVersionKind(gvk)
+			if gvk.Kind == "Secret" && !includeSecretValues {
+				redact(obj)
+			}
+			manifest, err := toManifest(obj)
+			if err != nil {
+				return 0, err
+			}
+			header := &tar.Header{
+				Name:    fmt.Sprintf("%s%s/%s.yaml", prefix, strings.ToLower(gvk.Kind), obj.GetName()),
+				Mode:    0o600,
+				Size:    int64(len(manifest)),
+				ModTime: time.Now(),
+			}
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return 0, err
+			}
+			if _, err := tarWriter.Write(manifest); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// isUserObject returns `false` if the object was created by the operator (from the templates),
+// by another controller (ie, it has an owner) or by the platform
+func isUserObject(obj *unstructured.Unstructured) bool {
+	if _, found := obj.GetLabels()[toolchainv1alpha1.ProviderLabelKey]; found || len(obj.GetOwnerReferences()) > 0 {
+		return false
+	}
+	switch obj.GetKind() {
+	case "ConfigMap":
+		return !slices.Contains(generatedConfigMaps, obj.GetName())
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType != string(corev1.SecretTypeServiceAccountToken) && obj.GetAnnotations()[corev1.ServiceAccountNameKey] == ""
+	}
+	return true
+}
+
+// redact removes the values of the given Secret
+func redact(secret *unstructured.Unstructured) {
+	data, _, _ := unstructured.NestedMap(secret.Object, "data")
+	for key := range data {
+		data[key] = ""
+	}
+	if len(data) > 0 {
+		_ = unstructured.SetNestedMap(secret.Object, data, "data")
+	}
+	unstructured.RemoveNestedField(secret.Object, "stringData")
+	annotations := secret.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[RedactedAnnotationKey] = "true"
+	secret.SetAnnotations(annotations)
+}
+
+// toManifest returns the YAML manifest of the given object, without the fields which prevent from restoring it
+func toManifest(obj *unstructured.Unstructured) ([]byte, error) {
+	content := obj.DeepCopy().Object
+	for _, field := range []string{"resourceVersion", "uid", "creationTimestamp", "generation", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(content, "metadata", field)
+	}
+	unstructured.RemoveNestedField(content, "status")
+	if obj.GetKind() == "Service" {
+		unstructured.RemoveNestedField(content, "spec", "clusterIP")
+		unstructured.RemoveNestedField(content, "spec", "clusterIPs")
+	}
+	return yaml.Marshal(content)
+}
+
+// addSpaceBackup adds the reference to the archive in the annotations of the NSTemplateSet (replacing the previous one of the same namespace, if any)
+func (r *namespacesManager) addSpaceBackup(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, spaceBackup SpaceBackup) error {
+	backups := slices.DeleteFunc(getAnnotationJSON[[]SpaceBackup](ctx, nsTmplSet, SpaceBackupsAnnotationKey), func(b SpaceBackup) bool {
+		return b.Namespace == spaceBackup.Namespace
+	})
+	backups = append([]SpaceBackup{spaceBackup}, backups...)
+	if len(backups) > maxSpaceBackups {
+		backups = backups[:maxSpaceBackups]
+	}
+	return r.setAnnotationJSON(ctx, nsTmplSet, SpaceBackupsAnnotationKey, backups)
+}
@@ -0,0 +1,214 @@
+package nstemplateset
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+func TestBackupNamespaceBeforeDeletion(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	userObjects := func() []runtimeclient.Object {
+		return []runtimeclient.Object{
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: spacename + "-dev"},
+				Data:       map[string]string{"color": "blue"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: spacename + "-dev"},
+				Data:       map[string][]byte{"password": []byte("s3cr3t")},
+			},
+			// not archived
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: spacename + "-dev"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "from-template",
+					Namespace: spacename + "-dev",
+					Labels:    map[string]string{toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "default-token",
+					Namespace:   spacename + "-dev",
+					Annotations: map[string]string{corev1.ServiceAccountNameKey: "default"},
+				},
+				Type: corev1.SecretTypeServiceAccountToken,
+			},
+		}
+	}
+
+	t.Run("backup disabled by default", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"), withDeletionTs())
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(userObjects(), nsTmplSet, devNS)...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).DoesNotExist()
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasNoAnnotation(SpaceBackupsAnnotationKey)
+	})
+
+	t.Run("backup in directory", func(t *testing.T) {
+
+		t.Run("when the NSTemplateSet is deleted", func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			config := newBackupConfig(t, map[string]string{
+				SpaceBackupAnnotationKey:          "pvc",
+				SpaceBackupDirectoryAnnotationKey: dir,
+			})
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"), withDeletionTs())
+			devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(userObjects(), nsTmplSet, devNS, config)...)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			AssertThatNamespace(t, spacename+"-dev", fakeClient).DoesNotExist()
+			backups := getStoredAnnotationJSON[[]SpaceBackup](t, fakeClient, namespaceName, spacename, SpaceBackupsAnnotationKey)
+			require.Len(t, backups, 1)
+			assert.Equal(t, spacename+"-dev", backups[0].Namespace)
+			assert.Equal(t, 2, backups[0].Objects)
+			assert.Equal(t, filepath.Join(dir, spacename, spacename+"-dev.tar.gz"), backups[0].Location)
+			manifests := readArchive(t, backups[0].Location)
+			require.Len(t, manifests, 2)
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, yaml.Unmarshal(manifests["configmap/settings.yaml"], cm))
+			assert.Equal(t, "ConfigMap", cm.Kind)
+			assert.Equal(t, map[string]string{"color": "blue"}, cm.Data)
+			assert.Empty(t, cm.ResourceVersion)
+			secret := &corev1.Secret{}
+			require.NoError(t, yaml.Unmarshal(manifests["secret/credentials.yaml"], secret))
+			assert.Equal(t, map[string][]byte{"password": {}}, secret.Data)
+			assert.Equal(t, "true", secret.Annotations[RedactedAnnotationKey])
+		})
+
+		t.Run("when the namespace type is removed from the tier", func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			config := newBackupConfig(t, map[string]string{
+				SpaceBackupAnnotationKey:                    "pvc",
+				SpaceBackupDirectoryAnnotationKey:           dir,
+				SpaceBackupIncludeSecretValuesAnnotationKey: "true",
+			})
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "stage"), withConditions(Provisioned()))
+			devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+			r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(userObjects(), nsTmplSet, devNS, config)...)
+			_, err := membercfg.ForceLoadConfiguration(fakeClient)
+			require.NoError(t, err)
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			AssertThatNamespace(t, spacename+"-dev", fakeClient).DoesNotExist()
+			backups := getStoredAnnotationJSON[[]SpaceBackup](t, fakeClient, namespaceName, spacename, SpaceBackupsAnnotationKey)
+			require.Len(t, backups, 1)
+			assert.Equal(t, spacename+"-dev", backups[0].Namespace)
+			secret := &corev1.Secret{}
+			require.NoError(t, yaml.Unmarshal(readArchive(t, backups[0].Location)["secret/credentials.yaml"], secret))
+			assert.Equal(t, map[string][]byte{"password": []byte("s3cr3t")}, secret.Data)
+			assert.NotContains(t, secret.Annotations, RedactedAnnotationKey)
+		})
+	})
+
+	t.Run("namespace is not deleted when the backup fails", func(t *testing.T) {
+		// given
+		config := newBackupConfig(t, map[string]string{
+			SpaceBackupAnnotationKey:         "s3",
+			SpaceBackupS3SecretAnnotationKey: "backup-bucket",
+		})
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"), withDeletionTs())
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(userObjects(), nsTmplSet, devNS, config)...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.EqualError(t, err, "failed to ensure namespace deletion: failed to back up user namespace 'johnsmith-dev': unable to get the 'backup-bucket' secret with the settings of the S3 bucket: secrets \"backup-bucket\" not found")
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).HasLabel(toolchainv1alpha1.SpaceLabelKey, spacename)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasNoAnnotation(SpaceBackupsAnnotationKey).
+			HasConditions(UnableToTerminate("failed to back up user namespace 'johnsmith-dev': unable to get the 'backup-bucket' secret with the settings of the S3 bucket: secrets \"backup-bucket\" not found"))
+	})
+
+	t.Run("unknown storage", func(t *testing.T) {
+		// given
+		config := newBackupConfig(t, map[string]string{
+			SpaceBackupAnnotationKey: "tape",
+		})
+		manager, fakeClient := prepareNamespacesManager(t, config)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = manager.newBackupStore(context.TODO())
+
+		// then
+		require.EqualError(t, err, "unknown backup storage 'tape' (expected 'pvc' or 's3')")
+	})
+}
+
+func newBackupConfig(t *testing.T, annotations map[string]string) *toolchainv1alpha1.MemberOperatorConfig {
+	config := commonconfig.NewMemberOperatorConfigWithReset(t)
+	config.Annotations = annotations
+	return config
+}
+
+// readArchive returns the content of the files of the archive, indexed by their name
+func readArchive(t *testing.T, path string) map[string][]byte {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	files := map[string][]byte{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+	return files
+}
@@ -3,12 +3,16 @@ package nstemplateset
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"maps"
 	"reflect"
+	"strings"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -29,6 +33,8 @@ func (r *spaceRolesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1al
 			"failed to list namespaces for workspace '%s'", nsTmplSet.Name)
 	}
 	logger.Info("ensuring space roles", "namespace_count", len(nss), "role_count", len(nsTmplSet.Spec.SpaceRoles))
+	_, features := featureAnnotationNeedsUpdate(nsTmplSet)
+	params := featureParams(features)
 	for _, ns := range nss {
 		// space roles previously applied
 		// read annotation to see what was applied last time, so we can compare with the new SpaceRoles and remove all obsolete resources (based on their kind/names)
@@ -46,12 +52,12 @@ func (r *spaceRolesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1al
 				return false, err
 			}
 		}
-		lastAppliedSpaceRoleObjs, err := r.getSpaceRolesObjects(lctx, &ns, lastAppliedSpaceRoles)
+		lastAppliedSpaceRoleObjs, err := r.getSpaceRolesObjects(lctx, &ns, lastAppliedSpaceRoles, params)
 		if err != nil {
 			return false, r.wrapErrorWithStatusUpdateForSpaceRolesFailure(lctx, nsTmplSet, err, "failed to retrieve last applied space roles")
 		}
 		// space roles to apply now
-		spaceRoleObjs, err := r.getSpaceRolesObjects(lctx, &ns, nsTmplSet.Spec.SpaceRoles)
+		spaceRoleObjs, err := r.getSpaceRolesObjects(lctx, &ns, nsTmplSet.Spec.SpaceRoles, params)
 		if err != nil {
 			return false, r.wrapErrorWithStatusUpdateForSpaceRolesFailure(lctx, nsTmplSet, err, "failed to retrieve space roles to apply")
 		}
@@ -63,8 +69,8 @@ func (r *spaceRolesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1al
 		}
 		logger.Info("applying space role objects", "count", len(spaceRoleObjs))
 		// create (or update existing) objects based the tier template
-		if _, err = r.ApplyToolchainObjects(lctx, spaceRoleObjs, labels); err != nil {
-			return false, r.wrapErrorWithStatusUpdate(lctx, nsTmplSet, r.setStatusNamespaceProvisionFailed, err, "failed to provision namespace '%s' with space roles", ns.Name)
+		if _, err = r.applyTemplateObjects(lctx, nsTmplSet, spaceRoleObjs, labels); err != nil {
+			return false, r.wrapErrorWithPendingStatusUpdate(lctx, nsTmplSet, err, r.setStatusNamespaceProvisionFailed, "failed to provision namespace '%s' with space roles", ns.Name)
 		}
 
 		if err := deleteObsoleteObjects(lctx, r.Client, lastAppliedSpaceRoleObjs, spaceRoleObjs); err != nil {
@@ -94,9 +100,16 @@ func (r *spaceRolesManager) ensure(ctx context.Context, nsTmplSet *toolchainv1al
 	return false, nil
 }
 
-// Get the space role objects from the templates specified in the given `spaceRoles`
+// GroupSubjectPrefix is the prefix of the entries of the `SpaceRole.Usernames` which refer to a Group instead of a User,
+// eg: `group:my-team`. The NSTemplateSetSpaceRole API only has the `Usernames` field, so the groups are listed there too:
+// the usernames provisioned by the host are DNS-1123 compliant and thus never contain the `:` character of the prefix.
+// The space role templates must declare the `SUBJECT_KIND` parameter to be processed for a group (see tierTemplate.acceptsGroups).
+const GroupSubjectPrefix = "group:"
+
+// Get the space role objects from the templates specified in the given `spaceRoles`, processed with the given parameters of
+// the feature toggles (which never override the parameters of the subject, such as `USERNAME`)
 // Returns the objects, or an error if something wrong happened when processing the templates
-func (r *spaceRolesManager) getSpaceRolesObjects(ctx context.Context, ns *corev1.Namespace, spaceRoles []toolchainv1alpha1.NSTemplateSetSpaceRole) ([]runtimeclient.Object, error) {
+func (r *spaceRolesManager) getSpaceRolesObjects(ctx context.Context, ns *corev1.Namespace, spaceRoles []toolchainv1alpha1.NSTemplateSetSpaceRole, featureParams map[string]string) ([]runtimeclient.Object, error) {
 	// store by kind and name
 	spaceRoleObjects := []runtimeclient.Object{}
 	for _, spaceRole := range spaceRoles {
@@ -104,16 +117,49 @@ func (r *spaceRolesManager) getSpaceRolesObjects(ctx context.Context, ns *corev1
 		if err != nil {
 			return nil, err
 		}
-		for _, username := range spaceRole.Usernames {
-			objs, err := tierTemplate.process(r.Scheme, map[string]string{
-				Namespace: ns.Name,
-				Username:  username,
-			})
+		for _, subject := range spaceRole.Usernames {
+			subjectParams, err := spaceRoleParams(tierTemplate, ns.Name, subject)
 			if err != nil {
-				return nil, errors.Wrapf(err, "failed to process space roles template '%s' for the user '%s' in namespace '%s'", spaceRole.TemplateRef, username, ns.Name)
+				return nil, errors.Wrapf(err, "failed to process space roles template '%s' in namespace '%s'", spaceRole.TemplateRef, ns.Name)
+			}
+			params := maps.Clone(featureParams)
+			if params == nil {
+				params = make(map[string]string, len(subjectParams))
+			}
+			maps.Copy(params, subjectParams)
+			objs, err := tierTemplate.process(r.Scheme, params)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to process space roles template '%s' for the %s '%s' in namespace '%s'", spaceRole.TemplateRef, strings.ToLower(params[SubjectKind]), params[SubjectName], ns.Name)
 			}
 			spaceRoleObjects = append(spaceRoleObjects, objs...)
 		}
 	}
 	return spaceRoleObjects, nil
 }
+
+// spaceRoleParams returns the parameters to process the space role template for the given subject, which is either a username or
+// a group name with the `group:` prefix. In both cases, the `SUBJECT_KIND` (`User` or `Group`) and `SUBJECT_NAME` parameters are set,
+// along with the `USERNAME` or `GROUPNAME` parameter.
+func spaceRoleParams(tierTemplate *tierTemplate, namespace, subject string) (map[string]string, error) {
+	groupName, isGroup := strings.CutPrefix(subject, GroupSubjectPrefix)
+	if !isGroup {
+		return map[string]string{
+			Namespace:   namespace,
+			Username:    subject,
+			SubjectKind: rbac.UserKind,
+			SubjectName: subject,
+		}, nil
+	}
+	if groupName == "" {
+		return nil, fmt.Errorf("invalid subject '%s': missing group name", subject)
+	}
+	if !tierTemplate.acceptsGroups() {
+		return nil, fmt.Errorf("the template does not support groups (the '%s' parameter is not declared) but got the group '%s'", SubjectKind, groupName)
+	}
+	return map[string]string{
+		Namespace:   namespace,
+		GroupName:   groupName,
+		SubjectKind: rbac.GroupKind,
+		SubjectName: groupName,
+	}, nil
+}
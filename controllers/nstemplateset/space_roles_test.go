@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -203,6 +204,93 @@ func TestEnsureSpaceRoles(t *testing.T) {
 
 	})
 
+	t.Run("groups", func(t *testing.T) {
+
+		t.Run("create rolebindings for users and groups", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(commontest.MemberOperatorNs, "oddity", "basic",
+				withSpaceRoles(map[string][]string{
+					"basic-subjectadmin-abcde11": {"user1", GroupSubjectPrefix + "team1"},
+				}))
+			ns := newNamespace(nsTmplSet.Spec.TierName, "oddity", "dev", // ns.name=oddity-dev
+				withTemplateRefUsingRevision("abcde11"),
+			)
+			mgr, memberClient := prepareSpaceRolesManager(t, nsTmplSet, ns)
+
+			// when
+			createdOrUpdated, err := mgr.ensure(ctx, nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, createdOrUpdated)
+			AssertThatRoleBinding(t, "oddity-dev", "user1-space-admin", memberClient).
+				HasSubject(rbacv1.UserKind, "user1").
+				HasLabel(toolchainv1alpha1.SpaceLabelKey, nsTmplSet.GetName())
+			AssertThatRoleBinding(t, "oddity-dev", "team1-space-admin", memberClient).
+				HasSubject(rbacv1.GroupKind, "team1").
+				HasLabel(toolchainv1alpha1.SpaceLabelKey, nsTmplSet.GetName())
+			lastApplied, err := json.Marshal(nsTmplSet.Spec.SpaceRoles)
+			require.NoError(t, err)
+			AssertThatNamespace(t, "oddity-dev", memberClient.Client).
+				HasAnnotation(toolchainv1alpha1.LastAppliedSpaceRolesAnnotationKey, string(lastApplied))
+
+			t.Run("replace group", func(t *testing.T) {
+				// given
+				nsTmplSet.Spec.SpaceRoles[0].Usernames = []string{"user1", GroupSubjectPrefix + "team2"}
+
+				// when
+				createdOrUpdated, err := mgr.ensure(ctx, nsTmplSet)
+
+				// then
+				require.NoError(t, err)
+				assert.True(t, createdOrUpdated)
+				AssertThatRoleBinding(t, "oddity-dev", "user1-space-admin", memberClient).Exists()                              // unchanged
+				AssertThatRoleBinding(t, "oddity-dev", "team1-space-admin", memberClient).DoesNotExist()                        // deleted
+				AssertThatRoleBinding(t, "oddity-dev", "team2-space-admin", memberClient).HasSubject(rbacv1.GroupKind, "team2") // created
+			})
+		})
+
+		t.Run("template without subject kind does not accept groups", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(commontest.MemberOperatorNs, "oddity", "basic",
+				withSpaceRoles(map[string][]string{
+					"basic-admin-abcde11": {"user1", GroupSubjectPrefix + "team1"},
+				}))
+			ns := newNamespace(nsTmplSet.Spec.TierName, "oddity", "dev",
+				withTemplateRefUsingRevision("abcde11"),
+			)
+			mgr, memberClient := prepareSpaceRolesManager(t, nsTmplSet, ns)
+
+			// when
+			_, err := mgr.ensure(ctx, nsTmplSet)
+
+			// then
+			require.EqualError(t, err, "failed to retrieve space roles to apply: failed to process space roles template 'basic-admin-abcde11' in namespace 'oddity-dev': "+
+				"the template does not support groups (the 'SUBJECT_KIND' parameter is not declared) but got the group 'team1'")
+			AssertThatRoleBinding(t, "oddity-dev", "user1-space-admin", memberClient).DoesNotExist()
+			AssertThatRoleBinding(t, "oddity-dev", "johnsmith-space-admin", memberClient).DoesNotExist()
+		})
+
+		t.Run("missing group name", func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(commontest.MemberOperatorNs, "oddity", "basic",
+				withSpaceRoles(map[string][]string{
+					"basic-subjectadmin-abcde11": {GroupSubjectPrefix},
+				}))
+			ns := newNamespace(nsTmplSet.Spec.TierName, "oddity", "dev",
+				withTemplateRefUsingRevision("abcde11"),
+			)
+			mgr, _ := prepareSpaceRolesManager(t, nsTmplSet, ns)
+
+			// when
+			_, err := mgr.ensure(ctx, nsTmplSet)
+
+			// then
+			require.EqualError(t, err, "failed to retrieve space roles to apply: failed to process space roles template 'basic-subjectadmin-abcde11' in namespace 'oddity-dev': "+
+				"invalid subject 'group:': missing group name")
+		})
+	})
+
 	t.Run("failures", func(t *testing.T) {
 
 		t.Run("error while listing namespaces", func(t *testing.T) {
@@ -2,6 +2,7 @@ package nstemplateset
 
 import (
 	"context"
+	"errors"
 	"slices"
 	"sort"
 
@@ -41,15 +42,42 @@ func (r *statusManager) wrapErrorWithStatusUpdate(ctx context.Context, nsTmplSet
 	if err == nil {
 		return nil
 	}
+	if err := r.recordFailedObject(ctx, nsTmplSet, err, err.Error()); err != nil {
+		log.FromContext(ctx).Error(err, "failed to record the failed object in the provisioning status")
+	}
 	if err := updateStatus(ctx, nsTmplSet, err.Error()); err != nil {
 		log.FromContext(ctx).Error(err, "status update failed")
 	}
 	return errs.Wrapf(err, format, args...)
 }
 
+// wrapErrorWithPendingStatusUpdate sets the status to reflect that the NSTemplateSet is waiting for the objects of an apply wave
+// to become ready or for an object to be deleted before it is re-created, if the given error is a *wavePendingError or a *recreatePendingError.
+// Otherwise, it uses the given statusUpdater.
+func (r *statusManager) wrapErrorWithPendingStatusUpdate(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, err error, updateStatus statusUpdater, format string, args ...interface{}) error {
+	var wavePending *wavePendingError
+	if errors.As(err, &wavePending) {
+		if err := r.setStatusInProgress(ctx, nsTmplSet, wavePending.Error()); err != nil {
+			return err
+		}
+		return wavePending
+	}
+	var recreatePending *recreatePendingError
+	if errors.As(err, &recreatePending) {
+		if err := r.setStatusInProgress(ctx, nsTmplSet, recreatePending.Error()); err != nil {
+			return err
+		}
+		return recreatePending
+	}
+	return r.wrapErrorWithStatusUpdate(ctx, nsTmplSet, updateStatus, err, format, args...)
+}
+
 func (r *statusManager) updateStatusConditions(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, newConditions ...toolchainv1alpha1.Condition) error {
 	var updated bool
 	nsTmplSet.Status.Conditions, updated = condition.AddOrUpdateStatusConditions(nsTmplSet.Status.Conditions, newConditions...)
+	if readyCondition, found := condition.FindConditionByType(newConditions, toolchainv1alpha1.ConditionReady); found {
+		recordStatusTransition(nsTmplSet, readyCondition, updated)
+	}
 	if !updated {
 		// Nothing changed
 		return nil
@@ -81,6 +109,11 @@ func (r *statusManager) updateStatusProvisionedNamespaces(ctx context.Context, n
 }
 
 func (r *statusManager) setStatusReady(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	if err := r.clearProvisioningStatus(ctx, nsTmplSet); err != nil {
+		return err
+	}
+	nsTemplateSetProvisioningTracker.observePhase(nsTmplSet, readyPhase)
+	nsTemplateSetProvisioningTracker.generationApplied(nsTmplSet)
 	return r.updateStatusConditions(
 		ctx,
 		nsTmplSet,
@@ -93,6 +126,7 @@ func (r *statusManager) setStatusReady(ctx context.Context, nsTmplSet *toolchain
 
 // updateStatusClusterResourcesRevisions updates the cluster resources and features list in the status of the nstemplateset
 func (r *statusManager) updateStatusClusterResourcesRevisions(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	nsTemplateSetProvisioningTracker.observePhase(nsTmplSet, clusterResourcesPhase)
 	updateFeatureAnnotation, featureAnnotation := featureAnnotationNeedsUpdate(nsTmplSet)
 	if updateFeatureAnnotation || clusterResourcesNeedsUpdate(nsTmplSet) {
 		// save the feature toggles into the status
@@ -108,7 +142,11 @@ func (r *statusManager) updateStatusClusterResourcesRevisions(ctx context.Contex
 // featureAnnotationNeedsUpdate checks if the feature annotation has changed on the nstemlpateset compared to what was last time saved in the status
 func featureAnnotationNeedsUpdate(nsTmplSet *toolchainv1alpha1.NSTemplateSet) (bool, []string) {
 	featureAnnotation := nsTmplSet.Annotations[toolchainv1alpha1.FeatureToggleNameAnnotationKey]
-	featureAnnotationList := utils.SplitCommaSeparatedList(featureAnnotation)
+	// use the canonical form of the features (with their parameters), so that a change of a parameter is detected as well
+	featureAnnotationList := []string{}
+	for _, toggle := range parseFeatureToggles(utils.SplitCommaSeparatedList(featureAnnotation)) {
+		featureAnnotationList = append(featureAnnotationList, toggle.String())
+	}
 	// sort and deduplicate the list, so that the caller can use the "cleaned up" value
 	slices.Sort(featureAnnotationList)
 	featureAnnotationList = slices.Compact(featureAnnotationList)
@@ -140,6 +178,7 @@ func clusterResourcesNeedsUpdate(nsTmplSet *toolchainv1alpha1.NSTemplateSet) boo
 }
 
 func (r *statusManager) updateStatusNamespacesRevisions(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	nsTemplateSetProvisioningTracker.observePhase(nsTmplSet, namespacesPhase)
 	// order is not important, so we are sorting the lists just for the sake of the comparison
 	transform := cmp.Transformer("Sort", func(in []toolchainv1alpha1.NSTemplateSetNamespace) []toolchainv1alpha1.NSTemplateSetNamespace {
 		out := append([]toolchainv1alpha1.NSTemplateSetNamespace(nil), in...) // Copy input to avoid mutating it
@@ -156,6 +195,7 @@ func (r *statusManager) updateStatusNamespacesRevisions(ctx context.Context, nsT
 }
 
 func (r *statusManager) updateStatusSpaceRolesRevisions(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	nsTemplateSetProvisioningTracker.observePhase(nsTmplSet, spaceRolesPhase)
 	// order is not important, so we are sorting the lists just for the sake of the comparison
 	transform := cmp.Transformer("Sort", func(in []toolchainv1alpha1.NSTemplateSetSpaceRole) []toolchainv1alpha1.NSTemplateSetSpaceRole {
 		out := append([]toolchainv1alpha1.NSTemplateSetSpaceRole(nil), in...) // Copy input to avoid mutating it
@@ -193,6 +233,35 @@ func (r *statusManager) setStatusProvisioningIfNotUpdating(ctx context.Context,
 		})
 }
 
+// setStatusInProgress keeps the `provisioning` or `updating` reason and sets the given message (eg, about a pending apply wave)
+func (r *statusManager) setStatusInProgress(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, message string) error {
+	reason := toolchainv1alpha1.NSTemplateSetProvisioningReason
+	if readyCondition, found := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady); found &&
+		readyCondition.Reason == toolchainv1alpha1.NSTemplateSetUpdatingReason {
+		reason = toolchainv1alpha1.NSTemplateSetUpdatingReason
+	}
+	return r.updateStatusConditions(
+		ctx,
+		nsTmplSet,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		})
+}
+
+// clearWavePendingStatus clears the message about the pending apply wave from the Ready condition of the NSTemplateSet,
+// if there is such a message (the status is not updated otherwise)
+func (r *statusManager) clearWavePendingStatus(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	readyCondition, found := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	if !found || readyCondition.Message == "" ||
+		(readyCondition.Reason != toolchainv1alpha1.NSTemplateSetProvisioningReason && readyCondition.Reason != toolchainv1alpha1.NSTemplateSetUpdatingReason) {
+		return nil
+	}
+	return r.setStatusInProgress(ctx, nsTmplSet, "")
+}
+
 func (r *statusManager) setStatusProvisionFailed(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, message string) error {
 	return r.updateStatusConditions(
 		ctx,
@@ -241,14 +310,25 @@ func (r *statusManager) setStatusSpaceRolesProvisionFailed(ctx context.Context,
 		})
 }
 
+// setStatusTerminating sets the `terminating` status, unless it is already set (so that the diagnostics of the deletion are kept)
 func (r *statusManager) setStatusTerminating(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) error {
+	if readyCondition, found := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady); found &&
+		readyCondition.Reason == toolchainv1alpha1.NSTemplateSetTerminatingReason {
+		return nil
+	}
+	return r.setStatusTerminatingWithMessage(ctx, nsTmplSet, "")
+}
+
+// setStatusTerminatingWithMessage sets the `terminating` status with the given message (eg, the diagnostics of the deletion of the namespaces)
+func (r *statusManager) setStatusTerminatingWithMessage(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet, message string) error {
 	return r.updateStatusConditions(
 		ctx,
 		nsTmplSet,
 		toolchainv1alpha1.Condition{
-			Type:   toolchainv1alpha1.ConditionReady,
-			Status: corev1.ConditionFalse,
-			Reason: toolchainv1alpha1.NSTemplateSetTerminatingReason,
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.NSTemplateSetTerminatingReason,
+			Message: message,
 		})
 }
 
@@ -320,6 +320,20 @@ func TestFeatureAnnotationNeedsUpdate(t *testing.T) {
 			changed:         true,
 			featuresToApply: []string{"feature1", "feature3"},
 		},
+		{
+			name:            "should report change when the parameters of a feature differ",
+			statusFeatures:  []string{"feature1;cpu=4"},
+			annoFeatures:    "feature1;cpu=8",
+			changed:         true,
+			featuresToApply: []string{"feature1;cpu=8"},
+		},
+		{
+			name:            "should report no change when the parameters of a feature are in a different order",
+			statusFeatures:  []string{"feature1;cpu=4;memory=8Gi"},
+			annoFeatures:    "feature=feature1; memory=8Gi;cpu=4",
+			changed:         false,
+			featuresToApply: []string{"feature1;cpu=4;memory=8Gi"},
+		},
 		{
 			name:            "should detect duplicates",
 			statusFeatures:  []string{"feature1", "feature2", "feature1"},
@@ -0,0 +1,166 @@
+package nstemplateset
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	gotemp "text/template"
+)
+
+// templateFuncs returns the library of functions available to the Go templates of the TierTemplateRevisions.
+// Since all the templates (namespaces, cluster resources and space roles) are rendered via `tierTemplate.process`,
+// the functions are available in all of them.
+//
+// All the functions are deterministic: the same input always produces the same output, so that rendering a template
+// twice gives the same objects (which is required to detect the obsolete objects, or to avoid needless updates).
+// For this reason, there are no functions related to the time, random values or the environment.
+//
+// Note: since the templates are executed with the `missingkey=error` option, referencing an undefined parameter
+// with `.PARAM` fails. Use `index . "PARAM"` instead to get an empty value, e.g. `{{ index . "PARAM" | default "value" }}`
+func templateFuncs() gotemp.FuncMap {
+	return gotemp.FuncMap{
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"trim":      strings.TrimSpace,
+		"replace":   replace,
+		"trunc":     trunc,
+		"sha256sum": sha256sum,
+		"b64enc":    b64enc,
+		"b64dec":    b64dec,
+		"default":   defaultValue,
+		"quote":     quote,
+		"toJson":    toJSON,
+		"add":       add,
+		"sub":       sub,
+		"mul":       mul,
+	}
+}
+
+// replace replaces all occurrences of `from` by `to` in the given string, e.g. `{{ .USERNAME | replace "." "-" }}`
+func replace(from, to, s string) string {
+	return strings.ReplaceAll(s, from, to)
+}
+
+// trunc truncates the given string to the given number of characters (if it is longer), e.g. `{{ .SPACE_NAME | trunc 63 }}`.
+// The string is truncated on runes, so that a multi-byte character is never split.
+func trunc(length int, s string) string {
+	runes := []rune(s)
+	if length < 0 || len(runes) <= length {
+		return s
+	}
+	return string(runes[:length])
+}
+
+// sha256sum returns the hex-encoded SHA-256 checksum of the given string
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// b64enc returns the standard base64 encoding of the given string
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// b64dec decodes the given base64-encoded string
+func b64dec(s string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// defaultValue returns the given value if it is not empty, or the default value otherwise,
+// e.g. `{{ index . "STORAGE" | default "5Gi" }}`
+func defaultValue(defaultVal, given interface{}) interface{} {
+	if given == nil {
+		return defaultVal
+	}
+	if v := reflect.ValueOf(given); v.IsZero() || ((v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0) {
+		return defaultVal
+	}
+	return given
+}
+
+// quote returns the double-quoted, escaped representation of the given string
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// toJSON returns the JSON representation of the given value
+func toJSON(v interface{}) (string, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// add returns the sum of the given integers (which can also be given as strings, like the template parameters)
+func add(a, b interface{}) (int64, error) {
+	x, y, err := toInt64s(a, b)
+	return x + y, err
+}
+
+// sub returns the difference of the given integers (which can also be given as strings, like the template parameters)
+func sub(a, b interface{}) (int64, error) {
+	x, y, err := toInt64s(a, b)
+	return x - y, err
+}
+
+// mul returns the product of the given integers (which can also be given as strings, like the template parameters)
+func mul(a, b interface{}) (int64, error) {
+	x, y, err := toInt64s(a, b)
+	return x * y, err
+}
+
+func toInt64s(a, b interface{}) (int64, int64, error) {
+	x, err := toInt64(a)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := toInt64(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to convert '%s' to an integer: %w", v, err)
+		}
+		return i, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return uint64ToInt64(uint64(v))
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return uint64ToInt64(v)
+	default:
+		return 0, fmt.Errorf("unable to convert '%v' of type %T to an integer", v, v)
+	}
+}
+
+func uint64ToInt64(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("unable to convert '%d' to an integer: value out of range", v)
+	}
+	return int64(v), nil
+}
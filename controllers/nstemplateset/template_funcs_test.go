@@ -0,0 +1,168 @@
+package nstemplateset
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	gotemp "text/template"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/apis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	params := map[string]string{
+		"SPACE_NAME": "John.Smith",
+		"LONG_NAME":  strings.Repeat("a", 70),
+		"COUNT":      "3",
+		"EMPTY":      "",
+		"NOT_NUMBER": "three",
+	}
+
+	testCases := map[string]struct {
+		template      string
+		expected      string
+		expectedError string
+	}{
+		// string manipulation
+		"lower":          {template: `{{ .SPACE_NAME | lower }}`, expected: "john.smith"},
+		"upper":          {template: `{{ .SPACE_NAME | upper }}`, expected: "JOHN.SMITH"},
+		"trim":           {template: `{{ trim "  john  " }}`, expected: "john"},
+		"replace":        {template: `{{ .SPACE_NAME | replace "." "-" }}`, expected: "John-Smith"},
+		"trunc long":     {template: `{{ .LONG_NAME | trunc 63 }}`, expected: strings.Repeat("a", 63)},
+		"trunc short":    {template: `{{ .SPACE_NAME | trunc 63 }}`, expected: "John.Smith"},
+		"trunc negative": {template: `{{ .SPACE_NAME | trunc -1 }}`, expected: "John.Smith"},
+		"trunc runes":    {template: `{{ "Zoë Ünlü" | trunc 3 }}`, expected: "Zoë"},
+		// hashing and encoding
+		"sha256sum":           {template: `{{ sha256sum "john" }}`, expected: "96d9632f363564cc3032521409cf22a852f2032eec099ed5967c0d000cec607a"},
+		"sha256sum truncated": {template: `{{ sha256sum "john" | trunc 7 }}`, expected: "96d9632"},
+		"b64enc":              {template: `{{ .SPACE_NAME | b64enc }}`, expected: "Sm9obi5TbWl0aA=="},
+		"b64dec":              {template: `{{ "Sm9obi5TbWl0aA==" | b64dec }}`, expected: "John.Smith"},
+		"b64dec invalid":      {template: `{{ "not base64!" | b64dec }}`, expectedError: "illegal base64 data"},
+		// default
+		"default with value":         {template: `{{ .SPACE_NAME | default "foo" }}`, expected: "John.Smith"},
+		"default with empty value":   {template: `{{ .EMPTY | default "foo" }}`, expected: "foo"},
+		"default with missing value": {template: `{{ index . "MISSING" | default "foo" }}`, expected: "foo"},
+		"default with zero int":      {template: `{{ sub 3 3 | default 5 }}`, expected: "5"},
+		// quote and toJson
+		"quote":                 {template: `{{ quote "john \"the\" smith" }}`, expected: `"john \"the\" smith"`},
+		"toJson string":         {template: `{{ .SPACE_NAME | toJson }}`, expected: `"John.Smith"`},
+		"toJson map is ordered": {template: `{{ toJson . }}`, expected: `{"COUNT":"3","EMPTY":"","LONG_NAME":"` + strings.Repeat("a", 70) + `","NOT_NUMBER":"three","SPACE_NAME":"John.Smith"}`},
+		"toJson escapes":        {template: `{{ toJson "a\"b" }}`, expected: `"a\"b"`},
+		// arithmetic
+		"add":                {template: `{{ add .COUNT 2 }}`, expected: "5"},
+		"add strings":        {template: `{{ add .COUNT "2" }}`, expected: "5"},
+		"sub":                {template: `{{ sub .COUNT 5 }}`, expected: "-2"},
+		"mul":                {template: `{{ mul .COUNT 4 }}`, expected: "12"},
+		"mul then add":       {template: `{{ mul .COUNT 2 | add 1 }}`, expected: "7"},
+		"add not a number":   {template: `{{ add .NOT_NUMBER 1 }}`, expectedError: "unable to convert 'three' to an integer"},
+		"add unsupported":    {template: `{{ add 1.5 1 }}`, expectedError: "unable to convert '1.5' of type float64 to an integer"},
+		"missing key errors": {template: `{{ .MISSING | default "foo" }}`, expectedError: `map has no entry for key "MISSING"`},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// given
+			tmpl, err := gotemp.New(name).Option("missingkey=error").Funcs(templateFuncs()).Parse(tc.template)
+			require.NoError(t, err)
+			var out bytes.Buffer
+
+			// when
+			err = tmpl.Execute(&out, params)
+
+			// then
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+
+	t.Run("functions are deterministic", func(t *testing.T) {
+		for name := range templateFuncs() {
+			assert.NotContains(t, []string{"now", "date", "rand", "randAlphaNum", "uuidv4", "env"}, name)
+		}
+	})
+}
+
+func TestProcessWithTemplateFuncs(t *testing.T) {
+	// given
+	s := runtime.NewScheme()
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	cmTemplate := `{
+		"apiVersion": "v1",
+		"kind": "ConfigMap",
+		"metadata": {
+			"name": "{{ .SPACE_NAME | lower | replace "." "-" | trunc 20 }}",
+			"namespace": "{{ .NAMESPACE }}",
+			"labels": {
+				"hash": "{{ sha256sum .SPACE_NAME | trunc 10 }}"
+			}
+		},
+		"data": {
+			"storage": {{ index . "STORAGE" | default "5Gi" | quote }},
+			"replicas": "{{ add .REPLICAS 1 }}",
+			"encoded": "{{ b64enc .SPACE_NAME }}"
+		}
+	}`
+	ttr := createTestTTR("test-ttr", []string{cmTemplate}, []toolchainv1alpha1.Parameter{
+		{Name: "NAMESPACE", Value: "test-namespace"},
+		{Name: "REPLICAS", Value: "2"},
+	})
+	tierTemplate := createTestTierTemplate(ttr)
+
+	// when
+	objects, err := tierTemplate.process(s, map[string]string{
+		"SPACE_NAME": "John.Smith.With.A.Very.Long.Name",
+	})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	cm := objects[0]
+	assert.Equal(t, "john-smith-with-a-ve", cm.GetName())
+	assert.Equal(t, "test-namespace", cm.GetNamespace())
+	assert.Equal(t, sha256sum("John.Smith.With.A.Very.Long.Name")[:10], cm.GetLabels()["hash"])
+	data, err := toJSON(cm)
+	require.NoError(t, err)
+	assert.Contains(t, data, `"storage":"5Gi"`)
+	assert.Contains(t, data, `"replicas":"3"`)
+	assert.Contains(t, data, `"encoded":"`+b64enc("John.Smith.With.A.Very.Long.Name")+`"`)
+}
+
+func TestToInt64(t *testing.T) {
+	for name, tc := range map[string]struct {
+		value         interface{}
+		expected      int64
+		expectedError string
+	}{
+		"int":             {value: 3, expected: 3},
+		"uint":            {value: uint(3), expected: 3},
+		"uint32":          {value: uint32(3), expected: 3},
+		"uint64":          {value: uint64(3), expected: 3},
+		"uint64 overflow": {value: uint64(math.MaxUint64), expectedError: "unable to convert '18446744073709551615' to an integer: value out of range"},
+		"string":          {value: " 3 ", expected: 3},
+		"float":           {value: 3.5, expectedError: "unable to convert '3.5' of type float64 to an integer"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			result, err := toInt64(tc.value)
+
+			// then
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package nstemplateset
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RenderOptions are the parameters and the filter used to render a TierTemplate or a TierTemplateRevision
+// the way the operator does, but outside of the cluster (see RenderTierTemplate)
+type RenderOptions struct {
+	SpaceName string
+	Username  string
+	Namespace string
+	// Features are the entries of the feature annotation of the NSTemplateSet, possibly with parameters (eg, `quota-boost;cpu=4`)
+	Features []string
+	// Filter is the name of the filter applied on the objects of the template:
+	// `namespaces`, `all-but-namespaces` or empty to retain all the objects
+	Filter string
+}
+
+// RenderProblem is a problem found when rendering a TierTemplate or a TierTemplateRevision (see RenderTierTemplate)
+type RenderProblem struct {
+	Message string
+	// Informational is true if the problem does not prevent the template from being applied by the operator
+	// (eg, an object of an unknown kind which is annotated as an optional resource)
+	Informational bool
+}
+
+// newRenderProblems converts the given messages into (non-informational) problems
+func newRenderProblems(messages ...string) []RenderProblem {
+	result := make([]RenderProblem, 0, len(messages))
+	for _, msg := range messages {
+		result = append(result, RenderProblem{Message: msg})
+	}
+	return result
+}
+
+// renderFilters are the filters which can be used when rendering a template, indexed by name
+var renderFilters = map[string]template.FilterFunc{
+	"namespaces":         template.RetainNamespaces,
+	"all-but-namespaces": template.RetainAllButNamespaces,
+}
+
+// templateParamRef matches the references to the parameters in the objects of the OpenShift templates, eg: `${SPACE_NAME}` or `${{REPLICAS}}`
+var templateParamRef = regexp.MustCompile(`\$\{\{?([a-zA-Z0-9_]+)\}?\}`)
+
+// RenderTierTemplate renders the given TierTemplate or TierTemplateRevision with the given options, the same way the operator does
+// when provisioning a space: the objects whose feature is not enabled are excluded.
+// It also validates the template and the rendered objects against the given scheme, and returns the problems that were found:
+// the missing or undeclared parameters, the invalid objects and the objects of unknown kinds without the optional-resource annotation.
+// The objects of unknown kinds with the optional-resource annotation are reported as informational problems.
+func RenderTierTemplate(scheme *runtime.Scheme, tmpl runtime.Object, opts RenderOptions) ([]runtimeclient.Object, []RenderProblem, error) {
+	var filters []template.FilterFunc
+	if opts.Filter != "" {
+		filter, found := renderFilters[opts.Filter]
+		if !found {
+			return nil, nil, fmt.Errorf("unknown filter '%s'", opts.Filter)
+		}
+		filters = append(filters, filter)
+	}
+	params := featureParams(opts.Features)
+	for name, value := range map[string]string{SpaceName: opts.SpaceName, Username: opts.Username, Namespace: opts.Namespace} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+
+	var tierTmpl *tierTemplate
+	var renderProblems []RenderProblem
+	switch tmpl := tmpl.(type) {
+	case *toolchainv1alpha1.TierTemplate:
+		tierTmpl = &tierTemplate{
+			templateRef: tmpl.Name,
+			tierName:    tmpl.Spec.TierName,
+			typeName:    tmpl.Spec.Type,
+			template:    tmpl.Spec.Template,
+		}
+		renderProblems = append(renderProblems, newRenderProblems(checkTemplateParams(tmpl.Spec.Template, params)...)...)
+	case *toolchainv1alpha1.TierTemplateRevision:
+		tierTmpl = &tierTemplate{
+			templateRef: tmpl.Name,
+			ttr:         tmpl,
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported kind of template: %T", tmpl)
+	}
+
+	objs, err := tierTmpl.process(scheme, params, filters...)
+	if err != nil {
+		return nil, renderProblems, err
+	}
+
+	nsTmplSet := &toolchainv1alpha1.NSTemplateSet{}
+	if len(opts.Features) > 0 {
+		nsTmplSet.Annotations = map[string]string{
+			toolchainv1alpha1.FeatureToggleNameAnnotationKey: strings.Join(opts.Features, ","),
+		}
+	}
+	rendered := make([]runtimeclient.Object, 0, len(objs))
+	for _, obj := range objs {
+		if !shouldCreate(obj, nsTmplSet) {
+			continue
+		}
+		rendered = append(rendered, obj)
+		if problem := checkRenderedObject(scheme, obj); problem != nil {
+			renderProblems = append(renderProblems, *problem)
+		}
+	}
+	return rendered, renderProblems, nil
+}
+
+// checkTemplateParams returns the parameters of the given OpenShift template which have no value, and the parameters
+// which are referenced in the objects of the template but which are not declared
+func checkTemplateParams(tmpl templatev1.Template, params map[string]string) []string {
+	var problems []string
+	declared := map[string]bool{MemberOperatorNS: true}
+	for _, param := range tmpl.Parameters {
+		declared[param.Name] = true
+		if _, provided := params[param.Name]; provided || param.Name == MemberOperatorNS {
+			continue
+		}
+		if param.Value == "" && param.Generate == "" {
+			problems = append(problems, fmt.Sprintf("missing value for the parameter '%s'", param.Name))
+		}
+	}
+	var undeclared []string
+	for _, obj := range tmpl.Objects {
+		for _, match := range templateParamRef.FindAllStringSubmatch(string(obj.Raw), -1) {
+			if !declared[match[1]] {
+				undeclared = append(undeclared, match[1])
+			}
+		}
+	}
+	slices.Sort(undeclared)
+	for _, name := range slices.Compact(undeclared) {
+		problems = append(problems, fmt.Sprintf("the parameter '%s' is referenced but not declared", name))
+	}
+	return problems
+}
+
+// checkRenderedObject validates the given rendered object against the scheme: its kind must be known, or it must be
+// annotated as an optional resource (ie, skipped when its API group is not available), in which case the returned problem
+// is only informational
+func checkRenderedObject(scheme *runtime.Scheme, obj runtimeclient.Object) *RenderProblem {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	name := obj.GetName()
+	if obj.GetNamespace() != "" {
+		name = obj.GetNamespace() + "/" + name
+	}
+	if !scheme.Recognizes(gvk) {
+		if _, optional := obj.GetAnnotations()[toolchainv1alpha1.TierTemplateObjectOptionalResourceAnnotation]; optional {
+			return &RenderProblem{
+				Message:       fmt.Sprintf("unknown kind '%s' of the object '%s' (optional resource)", gvk, name),
+				Informational: true,
+			}
+		}
+		return &RenderProblem{Message: fmt.Sprintf("unknown kind '%s' of the object '%s' which is not annotated with '%s'",
+			gvk, name, toolchainv1alpha1.TierTemplateObjectOptionalResourceAnnotation)}
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		typed, err := scheme.New(gvk)
+		if err != nil {
+			return &RenderProblem{Message: fmt.Sprintf("unable to validate the object '%s': %s", name, err)}
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(u.Object, typed, true); err != nil {
+			return &RenderProblem{Message: fmt.Sprintf("invalid %s '%s': %s", gvk.Kind, name, err)}
+		}
+	}
+	return nil
+}
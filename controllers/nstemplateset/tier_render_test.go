@@ -0,0 +1,147 @@
+package nstemplateset
+
+import (
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/apis"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRenderTierTemplate(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	s := runtime.NewScheme()
+	require.NoError(t, apis.AddToScheme(s))
+
+	newTierTemplate := func(params []templatev1.Parameter, objs ...string) *toolchainv1alpha1.TierTemplate {
+		tmpl := templatev1.Template{Parameters: params}
+		for _, obj := range objs {
+			tmpl.Objects = append(tmpl.Objects, runtime.RawExtension{Raw: []byte(obj)})
+		}
+		return &toolchainv1alpha1.TierTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "base-dev-abcde11", Namespace: "toolchain-host-operator"},
+			Spec: toolchainv1alpha1.TierTemplateSpec{
+				TierName: "base",
+				Type:     "dev",
+				Template: tmpl,
+			},
+		}
+	}
+	namespace := `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "${SPACE_NAME}-dev"}}`
+	quota := `{"apiVersion": "v1", "kind": "ResourceQuota", "metadata": {"name": "compute", "namespace": "${SPACE_NAME}-dev"}, "spec": {"hard": {"limits.cpu": "${CPU}"}}}`
+	boost := `{"apiVersion": "v1", "kind": "ResourceQuota", "metadata": {"name": "boost", "namespace": "${SPACE_NAME}-dev", "annotations": {"toolchain.dev.openshift.com/feature": "quota-boost"}}}`
+	params := []templatev1.Parameter{{Name: SpaceName, Required: true}, {Name: "CPU", Value: "1"}}
+
+	t.Run("TierTemplate", func(t *testing.T) {
+
+		t.Run("all objects", func(t *testing.T) {
+			// when
+			objs, problems, err := RenderTierTemplate(s, newTierTemplate(params, namespace, quota, boost), RenderOptions{SpaceName: "johnsmith"})
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+			assert.Equal(t, []string{"Namespace/johnsmith-dev", "ResourceQuota/johnsmith-dev/compute"}, renderedNames(objs))
+		})
+
+		t.Run("with filter and feature parameters", func(t *testing.T) {
+			// when
+			objs, problems, err := RenderTierTemplate(s, newTierTemplate(params, namespace, quota, boost), RenderOptions{
+				SpaceName: "johnsmith",
+				Features:  []string{"quota-boost;CPU=4"},
+				Filter:    "all-but-namespaces",
+			})
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+			assert.Equal(t, []string{"ResourceQuota/johnsmith-dev/compute", "ResourceQuota/johnsmith-dev/boost"}, renderedNames(objs))
+			cpu, _, err := unstructured.NestedString(objs[0].(*unstructured.Unstructured).Object, "spec", "hard", "limits.cpu")
+			require.NoError(t, err)
+			assert.Equal(t, "4", cpu)
+		})
+
+		t.Run("with problems", func(t *testing.T) {
+			// given
+			widget := `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "widget", "namespace": "${SPACE_NAME}-${ENV}"}}`
+			optionalWidget := `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "optional", "annotations": {"toolchain.dev.openshift.com/optional-resource": "true"}}}`
+			invalidQuota := `{"apiVersion": "v1", "kind": "ResourceQuota", "metadata": {"name": "invalid"}, "spec": {"unknown": true}}`
+
+			// when
+			objs, problems, err := RenderTierTemplate(s, newTierTemplate(append(params, templatev1.Parameter{Name: "MISSING"}), widget, optionalWidget, invalidQuota),
+				RenderOptions{SpaceName: "johnsmith"})
+
+			// then
+			require.NoError(t, err)
+			assert.Len(t, objs, 3)
+			require.Len(t, problems, 5)
+			assert.Equal(t, RenderProblem{Message: "missing value for the parameter 'MISSING'"}, problems[0])
+			assert.Equal(t, RenderProblem{Message: "the parameter 'ENV' is referenced but not declared"}, problems[1])
+			assert.Equal(t, RenderProblem{Message: "unknown kind 'example.com/v1, Kind=Widget' of the object 'johnsmith-${ENV}/widget' which is not annotated with 'toolchain.dev.openshift.com/optional-resource'"}, problems[2])
+			assert.Equal(t, RenderProblem{Message: "unknown kind 'example.com/v1, Kind=Widget' of the object 'optional' (optional resource)", Informational: true}, problems[3])
+			assert.Contains(t, problems[4].Message, "invalid ResourceQuota 'invalid'")
+			assert.False(t, problems[4].Informational)
+		})
+
+		t.Run("unknown filter", func(t *testing.T) {
+			// when
+			_, _, err := RenderTierTemplate(s, newTierTemplate(params, namespace), RenderOptions{Filter: "unknown"})
+
+			// then
+			require.EqualError(t, err, "unknown filter 'unknown'")
+		})
+	})
+
+	t.Run("TierTemplateRevision", func(t *testing.T) {
+		// given
+		ttr := createTestTTR("base-dev-abcde11-ttr", []string{configMapTemplate}, []toolchainv1alpha1.Parameter{{Name: "CONFIG_VALUE", Value: "value"}})
+
+		t.Run("success", func(t *testing.T) {
+			// when
+			objs, problems, err := RenderTierTemplate(s, ttr, RenderOptions{SpaceName: "johnsmith", Namespace: "johnsmith-dev"})
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+			assert.Equal(t, []string{"ConfigMap/johnsmith-dev/config-johnsmith"}, renderedNames(objs))
+		})
+
+		t.Run("missing parameter", func(t *testing.T) {
+			// when
+			_, _, err := RenderTierTemplate(s, ttr, RenderOptions{SpaceName: "johnsmith"})
+
+			// then
+			require.ErrorContains(t, err, `map has no entry for key "NAMESPACE"`)
+		})
+	})
+
+	t.Run("unsupported kind", func(t *testing.T) {
+		// when
+		_, _, err := RenderTierTemplate(s, &toolchainv1alpha1.NSTemplateSet{}, RenderOptions{})
+
+		// then
+		require.EqualError(t, err, "unsupported kind of template: *v1alpha1.NSTemplateSet")
+	})
+}
+
+func renderedNames(objs []runtimeclient.Object) []string {
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		name := obj.GetObjectKind().GroupVersionKind().Kind + "/"
+		if obj.GetNamespace() != "" {
+			name += obj.GetNamespace() + "/"
+		}
+		names = append(names, name+obj.GetName())
+	}
+	return names
+}
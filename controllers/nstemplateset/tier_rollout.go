@@ -0,0 +1,206 @@
+package nstemplateset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/constants"
+	"github.com/codeready-toolchain/member-operator/pkg/tierrollout"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// TierRolloutMaxConcurrentUpdatesAnnotationKey is the annotation on the MemberOperatorConfig with the maximum number of NSTemplateSets
+	// which can be updated to a new tier revision at the same time (ie, with the `Updating` reason). There is no limit when the annotation is not set.
+	TierRolloutMaxConcurrentUpdatesAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "tier-rollout-max-concurrent-updates"
+	// TierRolloutFailureThresholdAnnotationKey is the annotation on the MemberOperatorConfig with the percentage of the NSTemplateSets
+	// of the member cluster whose update failed (ie, with the `UpdateFailed` reason) above which the tier rollout is halted
+	// (see tierrollout.HaltedAnnotationKey). The rollout is never halted when the annotation is not set.
+	TierRolloutFailureThresholdAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "tier-rollout-failure-threshold"
+
+	// tierUpdateDeferredRequeueDelay is the delay after which a NSTemplateSet whose tier update was deferred is reconciled again
+	tierUpdateDeferredRequeueDelay = 30 * time.Second
+	// tierRolloutCountsRefreshPeriod is the period after which the NSTemplateSets are listed again to count the ones being updated
+	// or whose update failed (see tierRolloutCounter)
+	tierRolloutCountsRefreshPeriod = 10 * time.Second
+)
+
+// tierUpdatePending returns `true` if the given NSTemplateSet is provisioned, but its spec refers to other tier templates
+// than the ones which were applied, ie, if its tier update did not start yet
+func tierUpdatePending(nsTmplSet *toolchainv1alpha1.NSTemplateSet) bool {
+	readyCondition, found := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	if !found || readyCondition.Reason != toolchainv1alpha1.NSTemplateSetProvisionedReason {
+		return false
+	}
+	namespaceRefs := func(namespaces []toolchainv1alpha1.NSTemplateSetNamespace) []string {
+		refs := make([]string, 0, len(namespaces))
+		for _, ns := range namespaces {
+			refs = append(refs, ns.TemplateRef)
+		}
+		slices.Sort(refs)
+		return refs
+	}
+	spaceRoleRefs := func(spaceRoles []toolchainv1alpha1.NSTemplateSetSpaceRole) []string {
+		refs := make([]string, 0, len(spaceRoles))
+		for _, role := range spaceRoles {
+			refs = append(refs, role.TemplateRef)
+		}
+		slices.Sort(refs)
+		return slices.Compact(refs)
+	}
+	return clusterResourcesNeedsUpdate(nsTmplSet) ||
+		!slices.Equal(namespaceRefs(nsTmplSet.Spec.Namespaces), namespaceRefs(nsTmplSet.Status.Namespaces)) ||
+		!slices.Equal(spaceRoleRefs(nsTmplSet.Spec.SpaceRoles), spaceRoleRefs(nsTmplSet.Status.SpaceRoles))
+}
+
+// tierRolloutCounts are the numbers of NSTemplateSets of the member cluster by state of their tier update
+type tierRolloutCounts struct {
+	updating, failed, total int
+}
+
+// tierRolloutCounter keeps the counts of the NSTemplateSets by state of their tier update, so that the NSTemplateSets are listed
+// at most once per tierRolloutCountsRefreshPeriod rather than for every NSTemplateSet whose tier update is pending.
+// In-between, the counter is incremented for each tier update which is allowed to start.
+type tierRolloutCounter struct {
+	sync.Mutex
+	counts      tierRolloutCounts
+	refreshTime time.Time
+	// halted is `true` once the rollout was halted because the ratio of failures crossed the threshold, until it drops below the threshold.
+	// It is used to not halt the rollout again when the halt annotation was removed manually in-between
+	// (note: this state is not persisted, so the rollout is halted again after a restart if the ratio is still above the threshold).
+	halted bool
+}
+
+// get returns the current counts, which are refreshed by listing the NSTemplateSets in the given namespace if they are too old.
+// The caller must hold the lock.
+func (c *tierRolloutCounter) get(ctx context.Context, cl runtimeclient.Client, namespace string) (tierRolloutCounts, error) {
+	if time.Since(c.refreshTime) < tierRolloutCountsRefreshPeriod {
+		return c.counts, nil
+	}
+	nsTmplSets := &toolchainv1alpha1.NSTemplateSetList{}
+	if err := cl.List(ctx, nsTmplSets, runtimeclient.InNamespace(namespace)); err != nil {
+		return tierRolloutCounts{}, errs.Wrap(err, "unable to list the NSTemplateSets")
+	}
+	counts := tierRolloutCounts{}
+	for _, nsTmplSet := range nsTmplSets.Items {
+		if nsTmplSet.DeletionTimestamp != nil {
+			continue
+		}
+		counts.total++
+		readyCondition, _ := condition.FindConditionByType(nsTmplSet.Status.Conditions, toolchainv1alpha1.ConditionReady)
+		switch readyCondition.Reason {
+		case toolchainv1alpha1.NSTemplateSetUpdatingReason:
+			counts.updating++
+		case toolchainv1alpha1.NSTemplateSetUpdateFailedReason:
+			counts.failed++
+		}
+	}
+	c.counts = counts
+	c.refreshTime = time.Now()
+	return counts, nil
+}
+
+// deferTierUpdate returns a non-empty message if the tier update of the given NSTemplateSet must not start yet, ie, if the tier rollout
+// is halted, or if too many NSTemplateSets are already being updated. The rollout is halted (via an annotation on the MemberStatus)
+// when the ratio of the NSTemplateSets whose update failed crosses the configured threshold, and it is resumed (ie, the annotation
+// is removed) once the ratio drops below the threshold. The annotation can also be removed manually to override the halt,
+// in which case the rollout is not halted again until the ratio drops below the threshold and crosses it again.
+// The rollout is not controlled unless the maximum number of concurrent updates or the failure threshold is configured.
+func (r *Reconciler) deferTierUpdate(ctx context.Context, nsTmplSet *toolchainv1alpha1.NSTemplateSet) (string, error) {
+	maxConcurrentUpdates := memberOperatorConfigInt(ctx, TierRolloutMaxConcurrentUpdatesAnnotationKey)
+	failureThreshold := memberOperatorConfigInt(ctx, TierRolloutFailureThresholdAnnotationKey)
+	if (maxConcurrentUpdates == 0 && failureThreshold == 0) || !tierUpdatePending(nsTmplSet) {
+		return "", nil
+	}
+
+	r.tierRollout.Lock()
+	defer r.tierRollout.Unlock()
+	counts, err := r.tierRollout.get(ctx, r.Client, nsTmplSet.Namespace)
+	if err != nil {
+		return "", err
+	}
+	memberStatus := &toolchainv1alpha1.MemberStatus{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: nsTmplSet.Namespace, Name: constants.MemberStatusName}, memberStatus); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", errs.Wrap(err, "unable to get the MemberStatus")
+		}
+		memberStatus = nil
+	}
+
+	failing := failureThreshold > 0 && counts.failed > 0 && counts.failed*100 >= failureThreshold*counts.total
+	if memberStatus != nil {
+		if _, halted, _ := tierrollout.GetHalt(memberStatus); halted && !failing {
+			if err := r.resumeTierRollout(ctx, memberStatus, counts); err != nil {
+				return "", err
+			}
+		} else if halted {
+			r.tierRollout.halted = true
+			return fmt.Sprintf("the tier rollout is halted via the '%s' annotation on the MemberStatus", tierrollout.HaltedAnnotationKey), nil
+		}
+	}
+	if !failing {
+		r.tierRollout.halted = false
+	} else if !r.tierRollout.halted {
+		if err := r.haltTierRollout(ctx, memberStatus, counts); err != nil {
+			return "", err
+		}
+		r.tierRollout.halted = memberStatus != nil
+		return fmt.Sprintf("the tier rollout is halted since the update of %d out of %d NSTemplateSets failed", counts.failed, counts.total), nil
+	}
+	// otherwise, the halt annotation was removed manually while the ratio of failures is still above the threshold
+	if maxConcurrentUpdates > 0 && counts.updating >= maxConcurrentUpdates {
+		return fmt.Sprintf("%d NSTemplateSets are already being updated", counts.updating), nil
+	}
+	// the update of the NSTemplateSet is about to start
+	r.tierRollout.counts.updating++
+	return "", nil
+}
+
+// haltTierRollout sets the annotation which halts the tier rollout on the given MemberStatus
+func (r *Reconciler) haltTierRollout(ctx context.Context, memberStatus *toolchainv1alpha1.MemberStatus, counts tierRolloutCounts) error {
+	if memberStatus == nil {
+		// the rollout is still halted as long as the ratio of failures is above the threshold
+		log.FromContext(ctx).Info("unable to record the halt of the tier rollout: MemberStatus not found")
+		return nil
+	}
+	value, err := json.Marshal(tierrollout.Halt{
+		FailedSpaces: counts.failed,
+		TotalSpaces:  counts.total,
+		HaltTime:     metav1.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("halting the tier rollout", "failed", counts.failed, "total", counts.total)
+	patch := runtimeclient.MergeFrom(memberStatus.DeepCopy())
+	if memberStatus.Annotations == nil {
+		memberStatus.Annotations = map[string]string{}
+	}
+	memberStatus.Annotations[tierrollout.HaltedAnnotationKey] = string(value)
+	if err := r.Client.Patch(ctx, memberStatus, patch); err != nil {
+		return errs.Wrap(err, "unable to halt the tier rollout")
+	}
+	return nil
+}
+
+// resumeTierRollout removes the annotation which halts the tier rollout from the given MemberStatus
+func (r *Reconciler) resumeTierRollout(ctx context.Context, memberStatus *toolchainv1alpha1.MemberStatus, counts tierRolloutCounts) error {
+	log.FromContext(ctx).Info("resuming the tier rollout", "failed", counts.failed, "total", counts.total)
+	patch := runtimeclient.MergeFrom(memberStatus.DeepCopy())
+	delete(memberStatus.Annotations, tierrollout.HaltedAnnotationKey)
+	if err := r.Client.Patch(ctx, memberStatus, patch); err != nil {
+		return errs.Wrap(err, "unable to resume the tier rollout")
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package nstemplateset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/constants"
+	"github.com/codeready-toolchain/member-operator/pkg/tierrollout"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTierUpdatePending(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+
+	for name, tc := range map[string]struct {
+		nsTmplSet *toolchainv1alpha1.NSTemplateSet
+		expected  bool
+	}{
+		"new revision of the namespaces": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde12", "dev"), withStatusNamespaces("abcde11", "dev"),
+				withConditions(Provisioned())),
+			expected: true,
+		},
+		"new revision of the cluster resources": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "advanced", withClusterResources("abcde12"), withStatusClusterResources("abcde11"),
+				withConditions(Provisioned())),
+			expected: true,
+		},
+		"new revision of the space roles": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "basic", withSpaceRoles(map[string][]string{"basic-admin-abcde12": {spacename}}),
+				withStatusSpaceRoles(map[string][]string{"basic-admin-abcde11": {spacename}}), withConditions(Provisioned())),
+			expected: true,
+		},
+		"new users of the space roles": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "basic", withSpaceRoles(map[string][]string{"basic-admin-abcde11": {spacename, "jane"}}),
+				withStatusSpaceRoles(map[string][]string{"basic-admin-abcde11": {spacename}}), withConditions(Provisioned())),
+			expected: false,
+		},
+		"same revisions": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde11", "dev"), withStatusNamespaces("abcde11", "dev"),
+				withConditions(Provisioned())),
+			expected: false,
+		},
+		"update already started": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde12", "dev"), withStatusNamespaces("abcde11", "dev"),
+				withConditions(Updating())),
+			expected: false,
+		},
+		"not provisioned yet": {
+			nsTmplSet: newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde12", "dev")),
+			expected:  false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tierUpdatePending(tc.nsTmplSet))
+		})
+	}
+}
+
+func TestTierRollout(t *testing.T) {
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	// the space to update from `abcde11` to `abcde12`, along with other spaces being updated, failed to be updated or already updated
+	spaces := func(otherConditions ...toolchainv1alpha1.Condition) []runtimeclient.Object {
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withNamespaces("abcde12", "dev"), withStatusNamespaces("abcde11", "dev"),
+			withConditions(Provisioned()))
+		devNS := newNamespace("basic", spacename, "dev", withTemplateRefUsingRevision("abcde11"))
+		objs := []runtimeclient.Object{nsTmplSet, devNS, newRoleBinding(devNS.Name, "crtadmin-pods", spacename)}
+		for i, cond := range otherConditions {
+			objs = append(objs, newNSTmplSet(namespaceName, spacename+"-"+string(rune('a'+i)), "basic", withConditions(cond)))
+		}
+		return objs
+	}
+	memberStatus := func(annotations map[string]string) *toolchainv1alpha1.MemberStatus {
+		return &toolchainv1alpha1.MemberStatus{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespaceName,
+				Name:        constants.MemberStatusName,
+				Annotations: annotations,
+			},
+		}
+	}
+	config := func(t *testing.T, annotations map[string]string) *toolchainv1alpha1.MemberOperatorConfig {
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t)
+		cfg.Annotations = annotations
+		return cfg
+	}
+
+	t.Run("deferred when too many spaces are being updated", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(Updating(), Updating(), Provisioned()),
+			memberStatus(nil), config(t, map[string]string{TierRolloutMaxConcurrentUpdatesAnnotationKey: "2"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tierUpdateDeferredRequeueDelay, result.RequeueAfter)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Provisioned())
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).HasLabel(toolchainv1alpha1.TemplateRefLabelKey, "basic-dev-abcde11")
+	})
+
+	t.Run("updated when the number of spaces being updated is below the limit", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(Updating(), Provisioned()),
+			memberStatus(nil), config(t, map[string]string{TierRolloutMaxConcurrentUpdatesAnnotationKey: "2"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.NotEqual(t, tierUpdateDeferredRequeueDelay, result.RequeueAfter)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).HasLabel(toolchainv1alpha1.TemplateRefLabelKey, "basic-dev-abcde12")
+	})
+
+	t.Run("halted when too many updates failed", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(UpdateFailed("oops"), Provisioned(), Provisioned()),
+			memberStatus(nil), config(t, map[string]string{TierRolloutFailureThresholdAnnotationKey: "25"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		result, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tierUpdateDeferredRequeueDelay, result.RequeueAfter)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Provisioned())
+		halt, halted := getStoredTierRolloutHalt(t, fakeClient, namespaceName)
+		require.True(t, halted)
+		assert.Equal(t, 1, halt.FailedSpaces)
+		assert.Equal(t, 4, halt.TotalSpaces)
+
+		t.Run("resumed when the halt is cleared manually although the failures are not fixed", func(t *testing.T) {
+			// given
+			ms := &toolchainv1alpha1.MemberStatus{}
+			require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespaceName, Name: constants.MemberStatusName}, ms))
+			delete(ms.Annotations, tierrollout.HaltedAnnotationKey)
+			require.NoError(t, fakeClient.Update(context.TODO(), ms))
+
+			// when
+			result, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.NotEqual(t, tierUpdateDeferredRequeueDelay, result.RequeueAfter)
+			AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+			_, halted := getStoredTierRolloutHalt(t, fakeClient, namespaceName)
+			assert.False(t, halted) // not set again
+		})
+	})
+
+	t.Run("resumed once the failures are fixed", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(UpdateFailed("oops"), Provisioned(), Provisioned()),
+			memberStatus(nil), config(t, map[string]string{TierRolloutFailureThresholdAnnotationKey: "25"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		_, err = r.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		_, halted := getStoredTierRolloutHalt(t, fakeClient, namespaceName)
+		require.True(t, halted)
+		failed := &toolchainv1alpha1.NSTemplateSet{}
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespaceName, Name: spacename + "-a"}, failed))
+		failed.Status.Conditions = []toolchainv1alpha1.Condition{Provisioned()}
+		require.NoError(t, fakeClient.Status().Update(context.TODO(), failed))
+
+		t.Run("not before the counts are refreshed", func(t *testing.T) {
+			// when
+			result, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tierUpdateDeferredRequeueDelay, result.RequeueAfter)
+			AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Provisioned())
+		})
+
+		// given
+		r.tierRollout.refreshTime = time.Time{}
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+		_, halted = getStoredTierRolloutHalt(t, fakeClient, namespaceName)
+		assert.False(t, halted)
+	})
+
+	t.Run("not halted when the failures are below the threshold", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(UpdateFailed("oops"), Provisioned(), Provisioned()),
+			memberStatus(nil), config(t, map[string]string{TierRolloutFailureThresholdAnnotationKey: "30"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+		_, halted := getStoredTierRolloutHalt(t, fakeClient, namespaceName)
+		assert.False(t, halted)
+	})
+
+	t.Run("resumed when halted but the failures are below the threshold", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(UpdateFailed("oops"), Provisioned(), Provisioned()),
+			memberStatus(map[string]string{tierrollout.HaltedAnnotationKey: `{"failedSpaces":10,"totalSpaces":20}`}),
+			config(t, map[string]string{TierRolloutFailureThresholdAnnotationKey: "30"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+		_, halted := getStoredTierRolloutHalt(t, fakeClient, namespaceName)
+		assert.False(t, halted)
+	})
+
+	t.Run("NSTemplateSets listed once per batch of updates", func(t *testing.T) {
+		// given
+		objs := spaces(Updating())
+		for _, name := range []string{"alice", "bob"} {
+			objs = append(objs, newNSTmplSet(namespaceName, name, "basic", withNamespaces("abcde12", "dev"), withStatusNamespaces("abcde11", "dev"),
+				withConditions(Provisioned())))
+		}
+		r, fakeClient := prepareController(t, append(objs, memberStatus(nil),
+			config(t, map[string]string{TierRolloutMaxConcurrentUpdatesAnnotationKey: "2"}))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+		lists := 0
+		fakeClient.MockList = func(ctx context.Context, list runtimeclient.ObjectList, opts ...runtimeclient.ListOption) error {
+			if _, ok := list.(*toolchainv1alpha1.NSTemplateSetList); ok {
+				lists++
+			}
+			return fakeClient.Client.List(ctx, list, opts...)
+		}
+		deferred := 0
+		for _, name := range []string{spacename, "alice", "bob"} {
+			nsTmplSet := &toolchainv1alpha1.NSTemplateSet{}
+			require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespaceName, Name: name}, nsTmplSet))
+
+			// when
+			message, err := r.deferTierUpdate(context.TODO(), nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+			if message != "" {
+				deferred++
+			}
+		}
+		assert.Equal(t, 1, lists)
+		assert.Equal(t, 2, deferred) // one update was already in progress and one was allowed to start
+	})
+
+	t.Run("not controlled when not configured", func(t *testing.T) {
+		// given
+		r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, append(spaces(Updating()),
+			memberStatus(map[string]string{tierrollout.HaltedAnnotationKey: `{"failedSpaces":10,"totalSpaces":20}`}),
+			config(t, nil))...)
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).HasConditions(Updating())
+	})
+}
+
+func getStoredTierRolloutHalt(t *testing.T, cl runtimeclient.Client, namespace string) (tierrollout.Halt, bool) {
+	ms := &toolchainv1alpha1.MemberStatus{}
+	require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: constants.MemberStatusName}, ms))
+	halt, halted, err := tierrollout.GetHalt(ms)
+	require.NoError(t, err)
+	return halt, halted
+}
@@ -0,0 +1,168 @@
+package nstemplateset
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ApplyWaveAnnotationKey is the annotation set on a template object to specify the (integer) wave in which the
+	// object is applied. The objects are applied in ascending order of their waves. Objects without this annotation
+	// belong to the wave `0`.
+	ApplyWaveAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "apply-wave"
+	// WaitForReadyAnnotationKey is the annotation set on a template object to specify that the object must be ready
+	// before the next wave of objects is applied (and before the NSTemplateSet is considered as provisioned).
+	WaitForReadyAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "wait-for-ready"
+
+	// wavePendingRequeueDelay is the delay after which the NSTemplateSet is reconciled again when a wave is pending
+	wavePendingRequeueDelay = 5 * time.Second
+)
+
+// applyWave is a set of template objects that are applied together
+type applyWave struct {
+	number  int
+	objects []runtimeclient.Object
+}
+
+// wavePendingError is returned when some objects of an apply wave are not ready yet, so the next
+// wave cannot be applied.
+type wavePendingError struct {
+	wave     int
+	notReady []string
+}
+
+func (e *wavePendingError) Error() string {
+	return fmt.Sprintf("apply wave %d is pending: waiting for %s to become ready", e.wave, strings.Join(e.notReady, ", "))
+}
+
+// waveOf returns the apply wave of the given template object
+func waveOf(obj runtimeclient.Object) (int, error) {
+	value, found := obj.GetAnnotations()[ApplyWaveAnnotationKey]
+	if !found {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value of the '%s' annotation on %s '%s': %w", ApplyWaveAnnotationKey, obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), err)
+	}
+	return wave, nil
+}
+
+// groupByWave returns the given objects grouped by their apply waves, in ascending order of the waves.
+// The order of the objects within a wave is preserved.
+func groupByWave(objs []runtimeclient.Object) ([]applyWave, error) {
+	var waves []applyWave
+	for _, obj := range objs {
+		number, err := waveOf(obj)
+		if err != nil {
+			return nil, err
+		}
+		idx := slices.IndexFunc(waves, func(w applyWave) bool {
+			return w.number == number
+		})
+		if idx < 0 {
+			waves = append(waves, applyWave{number: number})
+			idx = len(waves) - 1
+		}
+		waves[idx].objects = append(waves[idx].objects, obj)
+	}
+	slices.SortStableFunc(waves, func(a, b applyWave) int {
+		return a.number - b.number
+	})
+	return waves, nil
+}
+
+// checkReadiness verifies that all the objects of the wave that are annotated with `wait-for-ready` are ready in the cluster.
+// Returns a *wavePendingError if at least one of them is not ready yet.
+func checkReadiness(ctx context.Context, cl runtimeclient.Client, wave applyWave) error {
+	var notReady []string
+	for _, obj := range wave.objects {
+		if obj.GetAnnotations()[WaitForReadyAnnotationKey] != "true" {
+			continue
+		}
+		actual := &unstructured.Unstructured{}
+		actual.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+		if err := cl.Get(ctx, runtimeclient.ObjectKeyFromObject(obj), actual); err != nil {
+			return errs.Wrapf(err, "failed to get %s '%s' to check its readiness", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+		}
+		if ready, reason := isReady(actual); !ready {
+			log.FromContext(ctx).Info("object is not ready yet", "wave", wave.number, "object_namespace", obj.GetNamespace(),
+				"object_name", obj.GetObjectKind().GroupVersionKind().Kind+"/"+obj.GetName(), "reason", reason)
+			notReady = append(notReady, fmt.Sprintf("%s/%s (%s)", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), reason))
+		}
+	}
+	if len(notReady) > 0 {
+		return &wavePendingError{wave: wave.number, notReady: notReady}
+	}
+	return nil
+}
+
+// isReady checks the readiness of the given object based on its kind and status:
+//   - Deployments and StatefulSets must have been fully rolled out,
+//   - ResourceQuotas must have been processed by the quota controller (ie, `status.hard` is set),
+//   - objects with a `Ready` (or `Available`) condition must have this condition set to `True`,
+//   - objects with a `status.phase` must be in a phase that denotes a ready/bound/running object.
+//
+// Objects that have none of the above are considered as ready as soon as they exist.
+// If the object is not ready, then the reason is also returned.
+func isReady(obj *unstructured.Unstructured) (bool, string) {
+	generation := obj.GetGeneration()
+	observedGeneration, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if found && observedGeneration < generation {
+		return false, "status not observed yet"
+	}
+
+	switch obj.GetObjectKind().GroupVersionKind().GroupKind().String() {
+	case "Deployment.apps", "StatefulSet.apps":
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		if updated < replicas || ready < replicas {
+			return false, fmt.Sprintf("%d/%d replicas updated and %d/%d ready", updated, replicas, ready, replicas)
+		}
+		return true, ""
+	case "ResourceQuota":
+		hard, _, _ := unstructured.NestedMap(obj.Object, "status", "hard")
+		if len(hard) == 0 {
+			return false, "quota not enforced yet"
+		}
+		return true, ""
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, conditionType := range []string{"Ready", "Available"} {
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != conditionType {
+				continue
+			}
+			if condition["status"] != "True" {
+				return false, fmt.Sprintf("condition %s is not True", conditionType)
+			}
+			return true, ""
+		}
+	}
+
+	if phase, found, _ := unstructured.NestedString(obj.Object, "status", "phase"); found {
+		switch phase {
+		case "Active", "Bound", "Ready", "Running", "Succeeded":
+			return true, ""
+		default:
+			return false, fmt.Sprintf("phase is %s", phase)
+		}
+	}
+	return true, ""
+}
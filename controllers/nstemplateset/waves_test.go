@@ -0,0 +1,342 @@
+package nstemplateset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	quotav1 "github.com/openshift/api/quota/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestGroupByWave(t *testing.T) {
+	t.Run("objects are grouped in ascending order of waves", func(t *testing.T) {
+		// given
+		objs := []runtimeclient.Object{
+			newObjectInWave("a", "2"),
+			newObjectInWave("b", ""),
+			newObjectInWave("c", "-1"),
+			newObjectInWave("d", "2"),
+			newObjectInWave("e", "0"),
+		}
+
+		// when
+		waves, err := groupByWave(objs)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, waves, 3)
+		assert.Equal(t, -1, waves[0].number)
+		assert.Equal(t, []string{"c"}, names(waves[0].objects))
+		assert.Equal(t, 0, waves[1].number)
+		assert.Equal(t, []string{"b", "e"}, names(waves[1].objects))
+		assert.Equal(t, 2, waves[2].number)
+		assert.Equal(t, []string{"a", "d"}, names(waves[2].objects))
+	})
+
+	t.Run("no objects", func(t *testing.T) {
+		// when
+		waves, err := groupByWave(nil)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, waves)
+	})
+
+	t.Run("invalid wave", func(t *testing.T) {
+		// when
+		_, err := groupByWave([]runtimeclient.Object{newObjectInWave("a", "first")})
+
+		// then
+		require.EqualError(t, err, `invalid value of the 'toolchain.dev.openshift.com/apply-wave' annotation on ConfigMap 'a': strconv.Atoi: parsing "first": invalid syntax`)
+	})
+}
+
+func TestIsReady(t *testing.T) {
+	testCases := map[string]struct {
+		obj            map[string]interface{}
+		expectedReady  bool
+		expectedReason string
+	}{
+		"no status": {
+			obj:           map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"},
+			expectedReady: true,
+		},
+		"deployment rolled out": {
+			obj: map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment",
+				"metadata": map[string]interface{}{"generation": int64(2)},
+				"spec":     map[string]interface{}{"replicas": int64(2)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(2), "readyReplicas": int64(2)}},
+			expectedReady: true,
+		},
+		"deployment not observed yet": {
+			obj: map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment",
+				"metadata": map[string]interface{}{"generation": int64(3)},
+				"spec":     map[string]interface{}{"replicas": int64(2)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(2), "readyReplicas": int64(2)}},
+			expectedReason: "status not observed yet",
+		},
+		"deployment rolling out": {
+			obj: map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment",
+				"status": map[string]interface{}{"updatedReplicas": int64(1)}},
+			expectedReason: "1/1 replicas updated and 0/1 ready",
+		},
+		"deployment without ready replicas": {
+			obj: map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment",
+				"spec": map[string]interface{}{"replicas": int64(3)}},
+			expectedReason: "0/3 replicas updated and 0/3 ready",
+		},
+		"resource quota enforced": {
+			obj: map[string]interface{}{"apiVersion": "v1", "kind": "ResourceQuota",
+				"status": map[string]interface{}{"hard": map[string]interface{}{"limits.cpu": "2"}}},
+			expectedReady: true,
+		},
+		"resource quota not enforced": {
+			obj:            map[string]interface{}{"apiVersion": "v1", "kind": "ResourceQuota"},
+			expectedReason: "quota not enforced yet",
+		},
+		"ready condition true": {
+			obj: map[string]interface{}{"apiVersion": "example.com/v1", "kind": "Widget",
+				"status": map[string]interface{}{"conditions": []interface{}{
+					map[string]interface{}{"type": "Synced", "status": "False"},
+					map[string]interface{}{"type": "Ready", "status": "True"},
+				}}},
+			expectedReady: true,
+		},
+		"ready condition false": {
+			obj: map[string]interface{}{"apiVersion": "example.com/v1", "kind": "Widget",
+				"status": map[string]interface{}{"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False"},
+				}}},
+			expectedReason: "condition Ready is not True",
+		},
+		"available condition unknown": {
+			obj: map[string]interface{}{"apiVersion": "example.com/v1", "kind": "Widget",
+				"status": map[string]interface{}{"conditions": []interface{}{
+					map[string]interface{}{"type": "Available", "status": "Unknown"},
+				}}},
+			expectedReason: "condition Available is not True",
+		},
+		"bound phase": {
+			obj: map[string]interface{}{"apiVersion": "v1", "kind": "PersistentVolumeClaim",
+				"status": map[string]interface{}{"phase": "Bound"}},
+			expectedReady: true,
+		},
+		"pending phase": {
+			obj: map[string]interface{}{"apiVersion": "v1", "kind": "PersistentVolumeClaim",
+				"status": map[string]interface{}{"phase": "Pending"}},
+			expectedReason: "phase is Pending",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// when
+			ready, reason := isReady(&unstructured.Unstructured{Object: tc.obj})
+
+			// then
+			assert.Equal(t, tc.expectedReady, ready)
+			assert.Equal(t, tc.expectedReason, reason)
+		})
+	}
+}
+
+func TestClearWavePendingStatus(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+
+	t.Run("message of the pending wave cleared", func(t *testing.T) {
+		// given
+		nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withConditions(toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.NSTemplateSetUpdatingReason,
+			Message: "apply wave 1 is pending: waiting for Deployment 'app' to become ready",
+		}))
+		manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+
+		// when
+		err := manager.clearWavePendingStatus(context.TODO(), nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(Updating())
+	})
+
+	for name, cond := range map[string]toolchainv1alpha1.Condition{
+		"ready":        Provisioned(),
+		"provisioning": Provisioning(),
+		"failed":       UnableToProvisionNamespace("mock error"),
+	} {
+		t.Run("status not updated when "+name, func(t *testing.T) {
+			// given
+			nsTmplSet := newNSTmplSet(namespaceName, spacename, "basic", withConditions(cond))
+			manager, fakeClient := prepareStatusManager(t, nsTmplSet)
+			fakeClient.MockStatusUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.SubResourceUpdateOption) error {
+				return fmt.Errorf("unexpected status update")
+			}
+
+			// when
+			err := manager.clearWavePendingStatus(context.TODO(), nsTmplSet)
+
+			// then
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestApplyWavesWithReadinessGating(t *testing.T) {
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+	nsTmplSet := newNSTmplSet(namespaceName, spacename, "waves", withNamespaces("abcde11", "dev"),
+		withConditions(Provisioning()))
+	devNS := newNamespace("", spacename, "dev") // namespace exists but was not provisioned yet
+	r, req, fakeClient := prepareReconcile(t, namespaceName, spacename, nsTmplSet, devNS)
+
+	// when
+	res, err := r.Reconcile(context.TODO(), req)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{RequeueAfter: wavePendingRequeueDelay}, res)
+	AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+		HasConditions(toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.NSTemplateSetProvisioningReason,
+			Message: "apply wave 0 is pending: waiting for ResourceQuota/compute (quota not enforced yet) to become ready",
+		})
+	AssertThatNamespace(t, spacename+"-dev", fakeClient).
+		HasNoLabel(toolchainv1alpha1.TemplateRefLabelKey).
+		HasResource("compute", &corev1.ResourceQuota{}).
+		HasNoResource("crtadmin-pods", &rbacv1.RoleBinding{})
+
+	t.Run("next wave is applied once the quota is enforced", func(t *testing.T) {
+		// given
+		// the quota controller sets the status of the quota (which is not part of the updates made by the operator)
+		fakeClient.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			if err := fakeClient.Client.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() == "ResourceQuota" {
+				return unstructured.SetNestedStringMap(u.Object, map[string]string{"limits.cpu": "2"}, "status", "hard")
+			}
+			return nil
+		}
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(Provisioning())
+		AssertThatNamespace(t, spacename+"-dev", fakeClient).
+			HasLabel(toolchainv1alpha1.TemplateRefLabelKey, "waves-dev-abcde11").
+			HasResource("crtadmin-pods", &rbacv1.RoleBinding{})
+	})
+}
+
+func TestApplyClusterResourcesWavesWithReadinessGating(t *testing.T) {
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	// given
+	spacename := "johnsmith"
+	namespaceName := "toolchain-member"
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, "my-member-operator-namespace")
+	t.Cleanup(restore)
+	nsTmplSet := newNSTmplSet(namespaceName, spacename, "waves", withClusterResources("abcde11"),
+		withConditions(Provisioning()))
+	manager, fakeClient := prepareClusterResourcesManager(t, nsTmplSet)
+	// the quota is not ready until its Ready condition is set to True
+	ready := "False"
+	fakeClient.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+		if err := fakeClient.Client.Get(ctx, key, obj, opts...); err != nil {
+			return err
+		}
+		if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() == "ClusterResourceQuota" {
+			return unstructured.SetNestedSlice(u.Object, []interface{}{
+				map[string]interface{}{"type": "Ready", "status": ready},
+			}, "status", "conditions")
+		}
+		return nil
+	}
+
+	// when
+	err := manager.ensure(context.TODO(), nsTmplSet)
+
+	// then
+	require.ErrorAs(t, err, new(*wavePendingError))
+	AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+		HasConditions(toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.NSTemplateSetProvisioningReason,
+			Message: "apply wave 0 is pending: waiting for ClusterResourceQuota/for-johnsmith (condition Ready is not True) to become ready",
+		})
+	AssertThatCluster(t, fakeClient).
+		HasResource("for-"+spacename, &quotav1.ClusterResourceQuota{}).
+		HasNoResource(spacename+"-tekton-view", &rbacv1.ClusterRoleBinding{})
+
+	t.Run("next wave is applied and message cleared once the quota is ready", func(t *testing.T) {
+		// given
+		ready = "True"
+
+		// when
+		err := manager.ensure(context.TODO(), nsTmplSet)
+
+		// then
+		require.NoError(t, err)
+		AssertThatNSTemplateSet(t, namespaceName, spacename, fakeClient).
+			HasConditions(Provisioning())
+		AssertThatCluster(t, fakeClient).
+			HasResource(spacename+"-tekton-view", &rbacv1.ClusterRoleBinding{})
+	})
+}
+
+func newObjectInWave(name, wave string) runtimeclient.Object {
+	obj := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	if wave != "" {
+		obj.Annotations = map[string]string{
+			ApplyWaveAnnotationKey: wave,
+		}
+	}
+	return obj
+}
+
+func names(objs []runtimeclient.Object) []string {
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		names = append(names, obj.GetName())
+	}
+	return names
+}
@@ -3,8 +3,13 @@ package useraccount
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
@@ -16,12 +21,104 @@ const (
 	ConsoleUserSettingsRoleBindingSuffix  = "-rolebinding"
 )
 
+// consoleUserSettingsKind is a kind of the resources which are created by the Openshift Web Console for each user
+type consoleUserSettingsKind struct {
+	kind string
+	// newList returns an empty list of resources of this kind
+	newList func() client.ObjectList
+	// newObject returns an empty resource of this kind, to be deleted by name when no resource of this kind could be found by label
+	newObject func() client.Object
+}
+
+// consoleUserSettingsKinds are all the kinds of the resources which are created by the Openshift Web Console for each user
+var consoleUserSettingsKinds = []consoleUserSettingsKind{
+	{
+		kind:      "ConfigMap",
+		newList:   func() client.ObjectList { return &corev1.ConfigMapList{} },
+		newObject: func() client.Object { return &corev1.ConfigMap{} },
+	},
+	{
+		kind:      "Role",
+		newList:   func() client.ObjectList { return &rbac.RoleList{} },
+		newObject: func() client.Object { return &rbac.Role{TypeMeta: metav1.TypeMeta{Kind: "Role"}} },
+	},
+	{
+		kind:      "RoleBinding",
+		newList:   func() client.ObjectList { return &rbac.RoleBindingList{} },
+		newObject: func() client.Object { return &rbac.RoleBinding{TypeMeta: metav1.TypeMeta{Kind: "RoleBinding"}} },
+	},
+}
+
+// deleteUserResources deletes the user settings resources (configmap, role and role-binding) associated with the specified user, created for a user by Openshift Web Console.
+// This function only looks for these resources in the namespace - openshift-console-user-settings
+// Returns the kinds and names of the deleted resources (eg, `ConfigMap/user-settings-<UserUID>`), or an error if any of the list or deletion operations fail.
+func (r *Reconciler) deleteUserResources(ctx context.Context, userUID string) ([]string, error) {
+	var deleted []string
+	for _, k := range consoleUserSettingsKinds {
+		// Users which were created in the cluster with the OCP versions which includes https://issues.redhat.com/browse/OCPBUGS-32321 fix
+		// will have a label which will help to map the User to the User settings resources.
+		names, err := deleteResourcesByLabel(ctx, r.APIReader, r.Client, userUID, k.newList())
+		if err != nil {
+			return deleted, err
+		}
+		if len(names) == 0 {
+			// Users created before that won't have that label, and we have to rely on the name of the resource being of type `user-settings-<UserUID>`
+			obj := k.newObject()
+			found, err := deleteResource(ctx, r.Client, userUID, obj)
+			if err != nil {
+				return deleted, err
+			}
+			if found {
+				names = append(names, obj.GetName())
+			}
+		}
+		for _, name := range names {
+			deleted = append(deleted, k.kind+"/"+name)
+		}
+	}
+	if len(deleted) > 0 {
+		log.FromContext(ctx).Info("deleted console user settings resources", "resources", deleted)
+	}
+	return deleted, nil
+}
+
+// deleteResourcesByLabel deletes the resources of the kind of the given list which have the console user settings labels of the given user.
+// The resources are listed with the given reader (which must be able to read in the openshift-console-user-settings namespace) and deleted with the given client.
+// Returns the names of the deleted resources.
+func deleteResourcesByLabel(ctx context.Context, reader client.Reader, cl client.Client, userUID string, list client.ObjectList) ([]string, error) {
+	if err := reader.List(ctx, list, client.InNamespace(UserSettingNS), client.MatchingLabels{
+		ConsoleUserSettingsIdentifier: "true",
+		ConsoleUserSettingsUID:        userUID,
+	}); err != nil {
+		return nil, err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, o := range objs {
+		obj, ok := o.(client.Object)
+		if !ok {
+			continue
+		}
+		if err := cl.Delete(ctx, obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted = append(deleted, obj.GetName())
+	}
+	return deleted, nil
+}
+
 // deleteResource deletes the specified resource associated with a user from console setting.
 // It attempts to delete the resource by name, and does nothing if not found.
 //
 // userUID : The unique identifier of the user for whom the resource is being deleted.
-// Returns an error if the deletion operation fails. Returns nil if the operation is successful or there is nothing to delete.
-func deleteResource(ctx context.Context, cl client.Client, userUID string, toDelete client.Object) error {
+// Returns `true` if the resource was deleted, or an error if the deletion operation fails. Returns `false, nil` if there is nothing to delete.
+func deleteResource(ctx context.Context, cl client.Client, userUID string, toDelete client.Object) (bool, error) {
 
 	name := ConsoleUserSettingsResourceNamePrefix + userUID
 	if toDelete.GetObjectKind().GroupVersionKind().Kind == "Role" {
@@ -34,8 +131,9 @@ func deleteResource(ctx context.Context, cl client.Client, userUID string, toDel
 	toDelete.SetNamespace(UserSettingNS)
 	if err := cl.Delete(ctx, toDelete); err != nil {
 		if !errors.IsNotFound(err) {
-			return err
+			return false, err
 		}
+		return false, nil
 	}
-	return nil
+	return true, nil
 }
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	. "github.com/codeready-toolchain/member-operator/test"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"testing"
@@ -27,10 +29,11 @@ func TestDeleteConsoleSettingObjects(t *testing.T) {
 		cl := test.NewFakeClient(t, cm)
 
 		// when
-		err := deleteResource(ctx, cl, "johnsmith", &corev1.ConfigMap{})
+		deleted, err := deleteResource(ctx, cl, "johnsmith", &corev1.ConfigMap{})
 
 		// then
 		require.NoError(t, err)
+		assert.True(t, deleted)
 		// check that the configmap doesn't exist anymore
 		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith", &corev1.ConfigMap{})
 	})
@@ -46,10 +49,11 @@ func TestDeleteConsoleSettingObjects(t *testing.T) {
 		cl := test.NewFakeClient(t, role)
 
 		// when
-		err := deleteResource(ctx, cl, "johnsmith", &rbac.Role{TypeMeta: metav1.TypeMeta{Kind: "Role"}})
+		deleted, err := deleteResource(ctx, cl, "johnsmith", &rbac.Role{TypeMeta: metav1.TypeMeta{Kind: "Role"}})
 
 		// then
 		require.NoError(t, err)
+		assert.True(t, deleted)
 		// check that the role doesn't exist anymore
 		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-role", &rbac.Role{})
 	})
@@ -66,10 +70,11 @@ func TestDeleteConsoleSettingObjects(t *testing.T) {
 		cl := test.NewFakeClient(t, rb)
 
 		// when
-		err := deleteResource(ctx, cl, "johnsmith", &rbac.RoleBinding{TypeMeta: metav1.TypeMeta{Kind: "RoleBinding"}})
+		deleted, err := deleteResource(ctx, cl, "johnsmith", &rbac.RoleBinding{TypeMeta: metav1.TypeMeta{Kind: "RoleBinding"}})
 
 		// then
 		require.NoError(t, err)
+		assert.True(t, deleted)
 		// check that the rolebinding doesn't exist anymore
 		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-rolebinding", &rbac.RoleBinding{})
 	})
@@ -83,10 +88,11 @@ func TestDeleteConsoleSettingObjects(t *testing.T) {
 		}
 
 		// when
-		err := deleteResource(context.TODO(), cl, "johnsmith", &corev1.ConfigMap{})
+		deleted, err := deleteResource(context.TODO(), cl, "johnsmith", &corev1.ConfigMap{})
 
 		// then
 		require.Error(t, err)
+		assert.False(t, deleted)
 		require.Equal(t, "error in deleting configmap", err.Error())
 	})
 	t.Run("No Error is returned when no object is found", func(t *testing.T) {
@@ -103,9 +109,163 @@ func TestDeleteConsoleSettingObjects(t *testing.T) {
 		}
 		cl := test.NewFakeClient(t, noiseObject)
 		// when
-		err := deleteResource(context.TODO(), cl, "johnsmith", &corev1.ConfigMap{})
+		deleted, err := deleteResource(context.TODO(), cl, "johnsmith", &corev1.ConfigMap{})
 		// then
 		require.NoError(t, err)
+		assert.False(t, deleted)
 	})
 
 }
+
+func TestDeleteUserResources(t *testing.T) {
+	// given
+	labeled := func(name, userUID string, obj client.Object) client.Object {
+		obj.SetName(name)
+		obj.SetNamespace(UserSettingNS)
+		obj.SetLabels(map[string]string{
+			ConsoleUserSettingsIdentifier: "true",
+			ConsoleUserSettingsUID:        userUID,
+		})
+		return obj
+	}
+	unlabeled := func(name string, obj client.Object) client.Object {
+		obj.SetName(name)
+		obj.SetNamespace(UserSettingNS)
+		return obj
+	}
+	newReconciler := func(cl client.Client) *Reconciler {
+		return &Reconciler{
+			Client:    cl,
+			APIReader: cl,
+		}
+	}
+
+	t.Run("resources found by label and deleted", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t,
+			labeled("user-settings-johnsmith", "johnsmith", &corev1.ConfigMap{}),
+			labeled("user-settings-johnsmith-extra", "johnsmith", &corev1.ConfigMap{}),
+			labeled("user-settings-johnsmith-role", "johnsmith", &rbac.Role{}),
+			labeled("user-settings-johnsmith-rolebinding", "johnsmith", &rbac.RoleBinding{}),
+			labeled("user-settings-janedoe", "janedoe", &corev1.ConfigMap{}))
+
+		// when
+		deleted, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"ConfigMap/user-settings-johnsmith",
+			"ConfigMap/user-settings-johnsmith-extra",
+			"Role/user-settings-johnsmith-role",
+			"RoleBinding/user-settings-johnsmith-rolebinding",
+		}, deleted)
+		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith", &corev1.ConfigMap{})
+		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-extra", &corev1.ConfigMap{})
+		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-role", &rbac.Role{})
+		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-rolebinding", &rbac.RoleBinding{})
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(UserSettingNS, "user-settings-janedoe"), &corev1.ConfigMap{}))
+	})
+
+	t.Run("resources without label found by name and deleted", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t,
+			labeled("user-settings-johnsmith", "johnsmith", &corev1.ConfigMap{}),
+			unlabeled("user-settings-johnsmith-role", &rbac.Role{}),
+			unlabeled("user-settings-johnsmith-rolebinding", &rbac.RoleBinding{}),
+			unlabeled("user-settings-johnsmith-other", &corev1.ConfigMap{}))
+
+		// when
+		deleted, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"ConfigMap/user-settings-johnsmith",
+			"Role/user-settings-johnsmith-role",
+			"RoleBinding/user-settings-johnsmith-rolebinding",
+		}, deleted)
+		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-role", &rbac.Role{})
+		AssertObjectNotFound(t, cl, UserSettingNS, "user-settings-johnsmith-rolebinding", &rbac.RoleBinding{})
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(UserSettingNS, "user-settings-johnsmith-other"), &corev1.ConfigMap{}))
+	})
+
+	t.Run("nothing to delete", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, labeled("user-settings-janedoe", "janedoe", &corev1.ConfigMap{}))
+
+		// when
+		deleted, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, deleted)
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(UserSettingNS, "user-settings-janedoe"), &corev1.ConfigMap{}))
+	})
+
+	t.Run("only the kinds created by the console are listed", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		var listed []string
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			listed = append(listed, fmt.Sprintf("%T", list))
+			return cl.Client.List(ctx, list, opts...)
+		}
+
+		// when
+		_, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"*v1.ConfigMapList", "*v1.RoleList", "*v1.RoleBindingList"}, listed)
+	})
+
+	t.Run("forbidden error when listing the resources", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, labeled("user-settings-johnsmith", "johnsmith", &corev1.ConfigMap{}))
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			return apierrors.NewForbidden(corev1.Resource("configmaps"), "", fmt.Errorf("forbidden"))
+		}
+
+		// when
+		_, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.True(t, apierrors.IsForbidden(err))
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(UserSettingNS, "user-settings-johnsmith"), &corev1.ConfigMap{}))
+	})
+
+	t.Run("error when listing the resources", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			return fmt.Errorf("error in listing roles")
+		}
+
+		// when
+		_, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.EqualError(t, err, "error in listing roles")
+	})
+
+	t.Run("error when deleting a labeled resource", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t,
+			labeled("user-settings-johnsmith", "johnsmith", &corev1.ConfigMap{}),
+			labeled("user-settings-johnsmith-role", "johnsmith", &rbac.Role{}))
+		cl.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*rbac.Role); ok {
+				return fmt.Errorf("error in deleting role")
+			}
+			return cl.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		deleted, err := newReconciler(cl).deleteUserResources(context.TODO(), "johnsmith")
+
+		// then
+		require.EqualError(t, err, "error in deleting role")
+		assert.Equal(t, []string{"ConfigMap/user-settings-johnsmith"}, deleted)
+	})
+}
@@ -0,0 +1,155 @@
+package useraccount
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/utils"
+	userv1 "github.com/openshift/api/user/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// GroupsAnnotationKey is the annotation set on the UserAccount (by the host operator) with the comma-separated names of the
+	// OpenShift Groups which the user is a member of, eg, `tier-base,company-acme`.
+	// The groups are created (with the provider label) if they don't exist, and the user is removed from the groups with the
+	// provider label which are not listed anymore, as well as from all of them when the UserAccount is disabled or deleted.
+	GroupsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "groups"
+
+	// UserAccountUnableToUpdateGroupsReason is the reason of the Ready condition of the UserAccount when the memberships of the user
+	// in its groups could not be updated
+	UserAccountUnableToUpdateGroupsReason = "UnableToUpdateGroups"
+)
+
+// expectedGroups returns the names of the groups listed in the annotation of the given UserAccount
+func expectedGroups(userAcc *toolchainv1alpha1.UserAccount) []string {
+	var groups []string
+	for _, group := range utils.SplitCommaSeparatedList(userAcc.GetAnnotations()[GroupsAnnotationKey]) {
+		if group = strings.TrimSpace(group); group != "" && !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// ensureGroupMemberships ensures that the user of the given UserAccount is a member of all the groups listed in its annotation
+// (creating the groups if needed), and that it is not a member of any other group with the provider label
+func (r *Reconciler) ensureGroupMemberships(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount) error {
+	groups := expectedGroups(userAcc)
+	for _, name := range groups {
+		if err := r.addGroupMembership(ctx, userAcc.Name, name); err != nil {
+			return r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusGroupsUpdateFailed, err, "failed to add user '%s' to group '%s'", userAcc.Name, name)
+		}
+	}
+	if err := r.removeGroupMemberships(ctx, userAcc.Name, groups...); err != nil {
+		return r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusGroupsUpdateFailed, err, "failed to remove user '%s' from groups", userAcc.Name)
+	}
+	return nil
+}
+
+// addGroupMembership adds the given user to the given group, creating the group if it does not exist yet.
+// Returns an error if the group exists but does not have the provider label, ie, if it is not managed by the toolchain.
+// The update is retried on conflicts, since the same groups (eg, `tier-base`) are shared by many users.
+func (r *Reconciler) addGroupMembership(ctx context.Context, username, name string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		group := &userv1.Group{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: name}, group); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			log.FromContext(ctx).Info("creating group", "group", name)
+			err := r.Client.Create(ctx, &userv1.Group{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+					Labels: map[string]string{
+						toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue,
+					},
+				},
+				Users: userv1.OptionalNames{username},
+			})
+			if errors.IsAlreadyExists(err) {
+				// created in the meantime for another user: retry to add this user to it
+				return errors.NewConflict(userv1.Resource("groups"), name, err)
+			}
+			return err
+		}
+		if group.Labels[toolchainv1alpha1.ProviderLabelKey] != toolchainv1alpha1.ProviderLabelValue {
+			return fmt.Errorf("the group is not managed by the toolchain (missing '%s' label)", toolchainv1alpha1.ProviderLabelKey)
+		}
+		if slices.Contains(group.Users, username) {
+			return nil
+		}
+		log.FromContext(ctx).Info("adding user to group", "group", name)
+		group.Users = append(group.Users, username)
+		return r.Client.Update(ctx, group)
+	})
+}
+
+// removeGroupMemberships removes the given user from all the groups with the provider label, except the given ones.
+// The groups which have no member left are deleted.
+func (r *Reconciler) removeGroupMemberships(ctx context.Context, username string, except ...string) error {
+	groups := &userv1.GroupList{}
+	if err := r.Client.List(ctx, groups, client.MatchingLabels{toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue}); err != nil {
+		return err
+	}
+	for i := range groups.Items {
+		group := &groups.Items[i]
+		if slices.Contains(except, group.Name) || !slices.Contains(group.Users, username) {
+			continue
+		}
+		if err := r.removeGroupMembership(ctx, username, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGroupMembership removes the given user from the given group, or deletes the group if the user is its last member.
+// The update (or deletion) is retried on conflicts with the latest version of the group.
+func (r *Reconciler) removeGroupMembership(ctx context.Context, username string, group *userv1.Group) error {
+	logger := log.FromContext(ctx).WithValues("group", group.Name)
+	first := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !first {
+			if err := r.Client.Get(ctx, types.NamespacedName{Name: group.Name}, group); err != nil {
+				return client.IgnoreNotFound(err)
+			}
+		}
+		first = false
+		users := slices.DeleteFunc(slices.Clone(group.Users), func(user string) bool {
+			return user == username
+		})
+		if len(users) == len(group.Users) {
+			return nil
+		}
+		if len(users) == 0 {
+			logger.Info("deleting group without any member left")
+			// the deletion fails with a conflict if a user was added to the group in the meantime
+			return client.IgnoreNotFound(r.Client.Delete(ctx, group, client.Preconditions{UID: &group.UID, ResourceVersion: &group.ResourceVersion}))
+		}
+		logger.Info("removing user from group")
+		group.Users = users
+		return r.Client.Update(ctx, group)
+	})
+}
+
+func (r *Reconciler) setStatusGroupsUpdateFailed(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount, message string) error {
+	return r.updateStatusConditions(
+		ctx,
+		userAcc,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  UserAccountUnableToUpdateGroupsReason,
+			Message: message,
+		})
+}
@@ -0,0 +1,204 @@
+package useraccount
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	userv1 "github.com/openshift/api/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExpectedGroups(t *testing.T) {
+	for name, tc := range map[string]struct {
+		annotation string
+		expected   []string
+	}{
+		"no annotation": {},
+		"single group": {
+			annotation: "tier-base",
+			expected:   []string{"tier-base"},
+		},
+		"several groups": {
+			annotation: "tier-base, company-acme,,tier-base",
+			expected:   []string{"tier-base", "company-acme"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			userAcc := newUserAccount("johnsmith", "sub-123")
+			if tc.annotation != "" {
+				userAcc.Annotations = map[string]string{GroupsAnnotationKey: tc.annotation}
+			}
+
+			// then
+			assert.Equal(t, tc.expected, expectedGroups(userAcc))
+		})
+	}
+}
+
+func TestGroupMemberships(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	username := "johnsmith"
+	withGroups := func(groups string) userAccountOption {
+		return func(userAcc *toolchainv1alpha1.UserAccount) {
+			userAcc.Annotations = map[string]string{GroupsAnnotationKey: groups}
+		}
+	}
+
+	t.Run("user added to its groups", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withGroups("tier-base,company-acme"))
+		existing := newGroup("company-acme", true, "janedoe")
+		r, req, fakeClient, _ := prepareReconcile(t, username, userAcc, existing)
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		assertGroup(t, fakeClient, "tier-base", true, username)
+		assertGroup(t, fakeClient, "company-acme", true, "janedoe", username)
+
+		t.Run("user removed from the groups which are not listed anymore", func(t *testing.T) {
+			// given
+			require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, userAcc))
+			userAcc.Annotations[GroupsAnnotationKey] = "tier-base"
+			require.NoError(t, fakeClient.Update(context.TODO(), userAcc))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertGroup(t, fakeClient, "tier-base", true, username)
+			assertGroup(t, fakeClient, "company-acme", true, "janedoe")
+		})
+
+		t.Run("user removed from all its groups when disabled", func(t *testing.T) {
+			// given
+			require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, userAcc))
+			userAcc.Spec.Disabled = true
+			require.NoError(t, fakeClient.Update(context.TODO(), userAcc))
+
+			// when
+			for i := 0; i < 5; i++ {
+				_, err := r.Reconcile(context.TODO(), req)
+				require.NoError(t, err)
+			}
+
+			// then
+			assertUserNotFound(t, r, userAcc)
+			assertGroupNotFound(t, fakeClient, "tier-base") // deleted once empty
+			assertGroup(t, fakeClient, "company-acme", true, "janedoe")
+			assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisabledReason, ""))
+		})
+	})
+
+	t.Run("user removed from all its groups when deleted", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withGroups("tier-base"), withFinalizer())
+		r, req, fakeClient, _ := prepareReconcile(t, username, userAcc)
+		reconcileUntilProvisioned(t, r, req)
+		assertGroup(t, fakeClient, "tier-base", true, username)
+		require.NoError(t, fakeClient.Delete(context.TODO(), userAcc))
+
+		// when
+		for i := 0; i < 5; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+		}
+
+		// then
+		assertGroupNotFound(t, fakeClient, "tier-base") // deleted once empty
+		err := fakeClient.Get(context.TODO(), req.NamespacedName, &toolchainv1alpha1.UserAccount{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+
+	t.Run("group updates retried on conflicts", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withGroups("tier-base"))
+		existing := newGroup("tier-base", true, "janedoe")
+		r, req, fakeClient, _ := prepareReconcile(t, username, userAcc, existing)
+		conflicts := 0
+		fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			if group, ok := obj.(*userv1.Group); ok && conflicts < 2 {
+				conflicts++
+				// another user was added to the group in the meantime
+				latest := newGroup("tier-base", true, "janedoe", fmt.Sprintf("user-%d", conflicts))
+				latest.ResourceVersion = group.ResourceVersion
+				if err := fakeClient.Client.Update(ctx, latest); err != nil {
+					return err
+				}
+				return apierrors.NewConflict(userv1.Resource("groups"), group.Name, fmt.Errorf("the object has been modified"))
+			}
+			return fakeClient.Client.Update(ctx, obj, opts...)
+		}
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		assert.Equal(t, 2, conflicts)
+		assertGroup(t, fakeClient, "tier-base", true, "janedoe", "user-2", username)
+		assertConditions(t, fakeClient, userAcc, provisioned())
+	})
+
+	t.Run("groups which are not managed by the toolchain are left untouched", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withGroups("admins"))
+		admins := newGroup("admins", false, "janedoe")
+		others := newGroup("others", false, username)
+		r, req, fakeClient, _ := prepareReconcile(t, username, userAcc, admins, others)
+
+		// when
+		var err error
+		for i := 0; i < 5 && err == nil; i++ {
+			_, err = r.Reconcile(context.TODO(), req)
+		}
+
+		// then
+		require.EqualError(t, err, "failed to add user 'johnsmith' to group 'admins': the group is not managed by the toolchain (missing 'toolchain.dev.openshift.com/provider' label)")
+		assertGroup(t, fakeClient, "admins", false, "janedoe")
+		assertGroup(t, fakeClient, "others", false, username)
+		assertConditions(t, fakeClient, userAcc, notReady(UserAccountUnableToUpdateGroupsReason,
+			"the group is not managed by the toolchain (missing 'toolchain.dev.openshift.com/provider' label)"))
+	})
+}
+
+func newGroup(name string, managed bool, users ...string) *userv1.Group {
+	group := &userv1.Group{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Users: users,
+	}
+	if managed {
+		group.Labels = map[string]string{toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue}
+	}
+	return group
+}
+
+func assertGroup(t *testing.T, cl client.Client, name string, managed bool, users ...string) {
+	group := &userv1.Group{}
+	require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Name: name}, group))
+	if managed {
+		assert.Equal(t, toolchainv1alpha1.ProviderLabelValue, group.Labels[toolchainv1alpha1.ProviderLabelKey])
+	}
+	assert.ElementsMatch(t, users, group.Users, "group %s", name)
+}
+
+func assertGroupNotFound(t *testing.T, cl client.Client, name string) {
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: name}, &userv1.Group{})
+	require.True(t, apierrors.IsNotFound(err), "group %s", name)
+}
@@ -0,0 +1,120 @@
+package useraccount
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/utils/memberconfig"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	commonidentity "github.com/codeready-toolchain/toolchain-common/pkg/identity"
+)
+
+const (
+	// IdentityProvidersAnnotationKey is the annotation on the MemberOperatorConfig with the (JSON-encoded) list of the identity providers
+	// through which the users log in, each with the claims of the UserAccount from which the names of its identities are built, eg:
+	// `[{"name":"rhd","claims":["sub","originalSub","userID"]},{"name":"github","claims":["userID"]}]`.
+	// When the annotation is not set, the users log in through the identity provider of the `auth.idp` setting only, with the
+	// `sub`, `originalSub` and `userID` claims.
+	// The identities of the users for an identity provider which is removed from the list are deleted (see deleteStaleIdentities).
+	IdentityProvidersAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "identity-providers"
+
+	SubClaim         = "sub"
+	OriginalSubClaim = "originalSub"
+	UserIDClaim      = "userID"
+	AccountIDClaim   = "accountID"
+	EmailClaim       = "email"
+)
+
+// supportedClaims are the claims of the UserAccount from which the names of the identities can be built
+var supportedClaims = []string{SubClaim, OriginalSubClaim, UserIDClaim, AccountIDClaim, EmailClaim}
+
+// identityProvider is an identity provider through which the users log in
+type identityProvider struct {
+	// Name is the name of the identity provider, used as the prefix of the names of its identities
+	Name string `json:"name"`
+	// Claims are the claims of the UserAccount from which the names of the identities are built (one identity per non-empty claim)
+	Claims []string `json:"claims"`
+}
+
+// getIdentityProviders returns the identity providers configured in the annotation of the MemberOperatorConfig,
+// or the identity provider of the `auth.idp` setting if the annotation is not set
+func getIdentityProviders(config membercfg.Configuration) ([]identityProvider, error) {
+	value := memberconfig.Annotation(IdentityProvidersAnnotationKey)
+	if value == "" {
+		return []identityProvider{{
+			Name:   config.Auth().Idp(),
+			Claims: []string{SubClaim, OriginalSubClaim, UserIDClaim},
+		}}, nil
+	}
+	var idps []identityProvider
+	if err := json.Unmarshal([]byte(value), &idps); err != nil {
+		return nil, fmt.Errorf("invalid value of the '%s' annotation: %w", IdentityProvidersAnnotationKey, err)
+	}
+	if len(idps) == 0 {
+		return nil, fmt.Errorf("invalid value of the '%s' annotation: no identity provider", IdentityProvidersAnnotationKey)
+	}
+	for _, idp := range idps {
+		if idp.Name == "" {
+			return nil, fmt.Errorf("invalid value of the '%s' annotation: missing name of identity provider", IdentityProvidersAnnotationKey)
+		}
+		for _, claim := range idp.Claims {
+			if !slices.Contains(supportedClaims, claim) {
+				return nil, fmt.Errorf("invalid value of the '%s' annotation: unsupported claim '%s' for identity provider '%s'", IdentityProvidersAnnotationKey, claim, idp.Name)
+			}
+		}
+	}
+	return idps, nil
+}
+
+// claimValue returns the value of the given claim of the UserAccount
+func claimValue(userAcc *toolchainv1alpha1.UserAccount, claim string) string {
+	switch claim {
+	case SubClaim:
+		return userAcc.Spec.PropagatedClaims.Sub
+	case OriginalSubClaim:
+		return userAcc.Spec.PropagatedClaims.OriginalSub
+	case UserIDClaim:
+		return userAcc.Spec.PropagatedClaims.UserID
+	case AccountIDClaim:
+		return userAcc.Spec.PropagatedClaims.AccountID
+	case EmailClaim:
+		return userAcc.Spec.PropagatedClaims.Email
+	default:
+		return ""
+	}
+}
+
+// expectedIdentities returns the naming standards of the identities of the given UserAccount, for all the given identity providers,
+// in the order of the identity providers and of their claims. Empty claims are skipped, and so are the claims which have the
+// same value as a previous claim for the same identity provider (eg, when the `userID` claim is the same as the `sub` claim).
+func expectedIdentities(userAcc *toolchainv1alpha1.UserAccount, idps []identityProvider) []commonidentity.NamingStandard {
+	var identities []commonidentity.NamingStandard
+	var names []string
+	for _, idp := range idps {
+		for _, claim := range idp.Claims {
+			value := claimValue(userAcc, claim)
+			if value == "" {
+				continue
+			}
+			ins := commonidentity.NewIdentityNamingStandard(value, idp.Name)
+			if slices.Contains(names, ins.IdentityName()) {
+				continue
+			}
+			names = append(names, ins.IdentityName())
+			identities = append(identities, ins)
+		}
+	}
+	return identities
+}
+
+// expectedIdentityNames returns the names of the identities of the given UserAccount, for all the given identity providers
+func expectedIdentityNames(userAcc *toolchainv1alpha1.UserAccount, idps []identityProvider) []string {
+	identities := expectedIdentities(userAcc, idps)
+	names := make([]string, 0, len(identities))
+	for _, ins := range identities {
+		names = append(names, ins.IdentityName())
+	}
+	return names
+}
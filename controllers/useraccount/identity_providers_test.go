@@ -0,0 +1,184 @@
+package useraccount
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestGetIdentityProviders(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	load := func(t *testing.T, annotations map[string]string) membercfg.Configuration {
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t, testconfig.Auth().Idp("sso"))
+		cfg.Annotations = annotations
+		config, err := membercfg.ForceLoadConfiguration(test.NewFakeClient(t, cfg))
+		require.NoError(t, err)
+		return config
+	}
+
+	t.Run("default identity provider", func(t *testing.T) {
+		// given
+		config := load(t, nil)
+
+		// when
+		idps, err := getIdentityProviders(config)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []identityProvider{{Name: "sso", Claims: []string{SubClaim, OriginalSubClaim, UserIDClaim}}}, idps)
+	})
+
+	t.Run("configured identity providers", func(t *testing.T) {
+		// given
+		config := load(t, map[string]string{
+			IdentityProvidersAnnotationKey: `[{"name":"sso","claims":["sub","userID"]},{"name":"github","claims":["email"]}]`,
+		})
+
+		// when
+		idps, err := getIdentityProviders(config)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []identityProvider{
+			{Name: "sso", Claims: []string{SubClaim, UserIDClaim}},
+			{Name: "github", Claims: []string{EmailClaim}},
+		}, idps)
+	})
+
+	for name, tc := range map[string]struct {
+		value         string
+		expectedError string
+	}{
+		"invalid JSON": {
+			value:         `{"name":"sso"}`,
+			expectedError: "invalid value of the '" + IdentityProvidersAnnotationKey + "' annotation: json: cannot unmarshal object into Go value of type []useraccount.identityProvider",
+		},
+		"no identity provider": {
+			value:         `[]`,
+			expectedError: "invalid value of the '" + IdentityProvidersAnnotationKey + "' annotation: no identity provider",
+		},
+		"missing name": {
+			value:         `[{"claims":["sub"]}]`,
+			expectedError: "invalid value of the '" + IdentityProvidersAnnotationKey + "' annotation: missing name of identity provider",
+		},
+		"unsupported claim": {
+			value:         `[{"name":"github","claims":["login"]}]`,
+			expectedError: "invalid value of the '" + IdentityProvidersAnnotationKey + "' annotation: unsupported claim 'login' for identity provider 'github'",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			config := load(t, map[string]string{IdentityProvidersAnnotationKey: tc.value})
+
+			// when
+			_, err := getIdentityProviders(config)
+
+			// then
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestExpectedIdentityNames(t *testing.T) {
+	// given
+	userAcc := newUserAccount("johnsmith", "sub-123")
+	userAcc.Spec.PropagatedClaims.OriginalSub = "original:sub"
+
+	t.Run("default identity provider", func(t *testing.T) {
+		// when
+		names := expectedIdentityNames(userAcc, []identityProvider{{Name: "rhd", Claims: []string{SubClaim, OriginalSubClaim, UserIDClaim}}})
+
+		// then
+		assert.Equal(t, []string{"rhd:sub-123", "rhd:b64:b3JpZ2luYWw6c3Vi", "rhd:123456"}, names)
+	})
+
+	t.Run("same value for several claims", func(t *testing.T) {
+		// given
+		userAcc := userAcc.DeepCopy()
+		userAcc.Spec.PropagatedClaims.UserID = userAcc.Spec.PropagatedClaims.Sub
+
+		// when
+		names := expectedIdentityNames(userAcc, []identityProvider{{Name: "rhd", Claims: []string{SubClaim, UserIDClaim}}})
+
+		// then
+		assert.Equal(t, []string{"rhd:sub-123"}, names)
+	})
+
+	t.Run("several identity providers", func(t *testing.T) {
+		// when
+		names := expectedIdentityNames(userAcc, []identityProvider{
+			{Name: "rhd", Claims: []string{SubClaim}},
+			{Name: "github", Claims: []string{UserIDClaim, AccountIDClaim, EmailClaim}},
+		})
+
+		// then
+		assert.Equal(t, []string{"rhd:sub-123", "github:123456", "github:987654", "github:johnsmith@acme.com"}, names)
+	})
+}
+
+func TestReconcileWithSeveralIdentityProviders(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	username := "johnsmith"
+	userAcc := newUserAccount(username, "sub-123")
+	cfg := commonconfig.NewMemberOperatorConfigWithReset(t, testconfig.Auth().Idp("sso"))
+	cfg.Annotations = map[string]string{
+		IdentityProvidersAnnotationKey: `[{"name":"sso","claims":["sub"]},{"name":"github","claims":["userID"]}]`,
+	}
+	r, req, fakeClient, _ := prepareReconcile(t, username, cfg, userAcc)
+
+	// when
+	reconcileUntilProvisioned(t, r, req)
+
+	// then
+	user := assertUser(t, r, userAcc)
+	ssoIdentity := assertIdentityForUserID(t, r, userAcc, "sub-123", "sso")
+	githubIdentity := assertIdentityForUserID(t, r, userAcc, "123456", "github")
+	assert.Equal(t, "github", githubIdentity.ProviderName)
+	checkMapping(t, user, ssoIdentity, githubIdentity)
+
+	t.Run("invalid identity providers", func(t *testing.T) {
+		// given
+		cfg.Annotations[IdentityProvidersAnnotationKey] = `invalid`
+		require.NoError(t, fakeClient.Update(context.TODO(), cfg))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.ErrorContains(t, err, "failed to get the identity providers")
+		assertConditions(t, fakeClient, userAcc,
+			notReady(toolchainv1alpha1.UserAccountUnableToCreateIdentityReason, "invalid value of the '"+IdentityProvidersAnnotationKey+"' annotation: invalid character 'i' looking for beginning of value"))
+		// the identity is not deleted
+		assertIdentityForUserID(t, r, userAcc, "sub-123", "sso")
+	})
+}
+
+// reconcileUntilProvisioned reconciles the UserAccount until its User and Identities are all created, mapped and cleaned up
+func reconcileUntilProvisioned(t *testing.T, r *Reconciler, req reconcile.Request) {
+	for i := 0; i < 10; i++ {
+		_, err := r.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		userAcc := &toolchainv1alpha1.UserAccount{}
+		require.NoError(t, r.Client.Get(context.TODO(), req.NamespacedName, userAcc))
+		for _, cond := range userAcc.Status.Conditions {
+			if cond.Type == toolchainv1alpha1.ConditionReady && cond.Reason == toolchainv1alpha1.UserAccountProvisionedReason {
+				return
+			}
+		}
+	}
+	require.Fail(t, "UserAccount not provisioned after 10 reconciles")
+}
@@ -0,0 +1,48 @@
+package useraccount
+
+import (
+	"context"
+
+	oauthv1 "github.com/openshift/api/oauth/v1"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// oauthTokenUserNameField is the field of the OAuth tokens with the name of their user, which is supported in the field selectors
+// by the API server (the tokens are not cached by the manager, so the field selector is not evaluated against a cache index)
+const oauthTokenUserNameField = "userName"
+
+// deleteOAuthTokens deletes the OAuthAccessTokens and OAuthAuthorizeTokens of the given user, so that the user can't keep on
+// accessing the cluster with a token which was issued before its UserAccount was disabled or deleted
+func (r *Reconciler) deleteOAuthTokens(ctx context.Context, username string) error {
+	accessTokens := &oauthv1.OAuthAccessTokenList{}
+	if err := r.Client.List(ctx, accessTokens, client.MatchingFields{oauthTokenUserNameField: username}); err != nil {
+		return errs.Wrap(err, "failed to list the OAuthAccessTokens")
+	}
+	for i := range accessTokens.Items {
+		if err := r.deleteOAuthToken(ctx, "OAuthAccessToken", &accessTokens.Items[i]); err != nil {
+			return errs.Wrap(err, "failed to delete an OAuthAccessToken")
+		}
+	}
+	authorizeTokens := &oauthv1.OAuthAuthorizeTokenList{}
+	if err := r.Client.List(ctx, authorizeTokens, client.MatchingFields{oauthTokenUserNameField: username}); err != nil {
+		return errs.Wrap(err, "failed to list the OAuthAuthorizeTokens")
+	}
+	for i := range authorizeTokens.Items {
+		if err := r.deleteOAuthToken(ctx, "OAuthAuthorizeToken", &authorizeTokens.Items[i]); err != nil {
+			return errs.Wrap(err, "failed to delete an OAuthAuthorizeToken")
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) deleteOAuthToken(ctx context.Context, kind string, token client.Object) error {
+	// the name of the token is not logged since it is derived from the token itself
+	log.FromContext(ctx).Info("deleting OAuth token", "kind", kind)
+	if err := r.Client.Delete(ctx, token); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
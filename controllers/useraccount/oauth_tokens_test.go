@@ -0,0 +1,211 @@
+package useraccount
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	oauthv1 "github.com/openshift/api/oauth/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDeleteOAuthTokens(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	username := "johnsmith"
+	tokens := func() []client.Object {
+		return []client.Object{
+			newOAuthAccessToken("sha256~access-john", username),
+			newOAuthAuthorizeToken("sha256~authorize-john", username),
+			newOAuthAccessToken("sha256~access-jane", "janedoe"),
+			newOAuthAuthorizeToken("sha256~authorize-jane", "janedoe"),
+		}
+	}
+
+	t.Run("tokens deleted when disabled", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, req, fakeClient, _ := prepareReconcile(t, username, append(tokens(), userAcc)...)
+		reconcileUntilProvisioned(t, r, req)
+		require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, userAcc))
+		userAcc.Spec.Disabled = true
+		require.NoError(t, fakeClient.Update(context.TODO(), userAcc))
+
+		// when
+		for i := 0; i < 5; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+		}
+
+		// then
+		assertUserNotFound(t, r, userAcc)
+		assertOAuthTokenDeleted(t, fakeClient, "sha256~access-john", &oauthv1.OAuthAccessToken{})
+		assertOAuthTokenDeleted(t, fakeClient, "sha256~authorize-john", &oauthv1.OAuthAuthorizeToken{})
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sha256~access-jane"}, &oauthv1.OAuthAccessToken{}))
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sha256~authorize-jane"}, &oauthv1.OAuthAuthorizeToken{}))
+		assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisabledReason, ""))
+
+		t.Run("tokens not listed again once disabled", func(t *testing.T) {
+			// given
+			listed := 0
+			fakeClient.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				switch list.(type) {
+				case *oauthv1.OAuthAccessTokenList, *oauthv1.OAuthAuthorizeTokenList:
+					listed++
+				}
+				return listOAuthTokensByUserName(fakeClient)(ctx, list, opts...)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Zero(t, listed)
+			assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisabledReason, ""))
+		})
+	})
+
+	t.Run("tokens listed by user name", func(t *testing.T) {
+		// given
+		r, _, fakeClient, _ := prepareReconcile(t, username, tokens()...)
+		var selectors []string
+		fakeClient.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			listOpts := &client.ListOptions{}
+			listOpts.ApplyOptions(opts)
+			require.NotNil(t, listOpts.FieldSelector)
+			selectors = append(selectors, listOpts.FieldSelector.String())
+			return listOAuthTokensByUserName(fakeClient)(ctx, list, opts...)
+		}
+
+		// when
+		err := r.deleteOAuthTokens(context.TODO(), username)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"userName=johnsmith", "userName=johnsmith"}, selectors)
+		assertOAuthTokenDeleted(t, fakeClient, "sha256~access-john", &oauthv1.OAuthAccessToken{})
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sha256~access-jane"}, &oauthv1.OAuthAccessToken{}))
+	})
+
+	t.Run("tokens deleted when deleted", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, req, fakeClient, _ := prepareReconcile(t, username, append(tokens(), userAcc)...)
+		reconcileUntilProvisioned(t, r, req)
+		require.NoError(t, fakeClient.Delete(context.TODO(), userAcc))
+
+		// when
+		for i := 0; i < 5; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+		}
+
+		// then
+		assertOAuthTokenDeleted(t, fakeClient, "sha256~access-john", &oauthv1.OAuthAccessToken{})
+		assertOAuthTokenDeleted(t, fakeClient, "sha256~authorize-john", &oauthv1.OAuthAuthorizeToken{})
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sha256~access-jane"}, &oauthv1.OAuthAccessToken{}))
+		err := fakeClient.Get(context.TODO(), req.NamespacedName, &toolchainv1alpha1.UserAccount{})
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("not disabled until the tokens are deleted", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer(), disabled())
+		r, req, fakeClient, _ := prepareReconcile(t, username, append(tokens(), userAcc)...)
+		fakeClient.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*oauthv1.OAuthAuthorizeToken); ok {
+				return apierrors.NewInternalError(assert.AnError)
+			}
+			return fakeClient.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.ErrorContains(t, err, "failed to delete the OAuth tokens of the user: failed to delete an OAuthAuthorizeToken")
+		assertOAuthTokenDeleted(t, fakeClient, "sha256~access-john", &oauthv1.OAuthAccessToken{})
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sha256~authorize-john"}, &oauthv1.OAuthAuthorizeToken{}))
+		assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisablingReason,
+			"failed to delete the OAuth tokens of the user: failed to delete an OAuthAuthorizeToken: "+apierrors.NewInternalError(assert.AnError).Error()))
+
+		t.Run("disabled once the tokens are deleted", func(t *testing.T) {
+			// given
+			fakeClient.MockDelete = nil
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertOAuthTokenDeleted(t, fakeClient, "sha256~authorize-john", &oauthv1.OAuthAuthorizeToken{})
+			assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisabledReason, ""))
+		})
+	})
+}
+
+func newOAuthAccessToken(name, username string) *oauthv1.OAuthAccessToken {
+	return &oauthv1.OAuthAccessToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		UserName: username,
+	}
+}
+
+func newOAuthAuthorizeToken(name, username string) *oauthv1.OAuthAuthorizeToken {
+	return &oauthv1.OAuthAuthorizeToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		UserName: username,
+	}
+}
+
+func assertOAuthTokenDeleted(t *testing.T, cl client.Client, name string, token client.Object) {
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: name}, token)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsNotFound(err), "token %s", name)
+}
+
+// listOAuthTokensByUserName evaluates the field selector on the user name of the OAuth tokens, which is supported by the API server
+// but which would require an index in the fake client
+func listOAuthTokensByUserName(cl *test.FakeClient) func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+		listOpts := &client.ListOptions{}
+		listOpts.ApplyOptions(opts)
+		if listOpts.FieldSelector == nil {
+			return cl.Client.List(ctx, list, opts...)
+		}
+		username, found := listOpts.FieldSelector.RequiresExactMatch(oauthTokenUserNameField)
+		if !found {
+			return cl.Client.List(ctx, list, opts...)
+		}
+		listOpts.FieldSelector = nil
+		if err := cl.Client.List(ctx, list, listOpts); err != nil {
+			return err
+		}
+		switch tokens := list.(type) {
+		case *oauthv1.OAuthAccessTokenList:
+			tokens.Items = slices.DeleteFunc(tokens.Items, func(token oauthv1.OAuthAccessToken) bool {
+				return token.UserName != username
+			})
+		case *oauthv1.OAuthAuthorizeTokenList:
+			tokens.Items = slices.DeleteFunc(tokens.Items, func(token oauthv1.OAuthAuthorizeToken) bool {
+				return token.UserName != username
+			})
+		}
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package useraccount
+
+import (
+	"context"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/useraccountstatus"
+	userv1 "github.com/openshift/api/user/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// recordProvisionedResources records the given User and the Identities owned by the UserAccount in its status
+func (r *Reconciler) recordProvisionedResources(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount, user *userv1.User) error {
+	identityList := &userv1.IdentityList{}
+	if err := r.Client.List(ctx, identityList, listByOwnerLabel(userAcc.Name)); err != nil {
+		return err
+	}
+	var identities []useraccountstatus.ProvisionedIdentity
+	for _, identity := range identityList.Items {
+		identities = append(identities, useraccountstatus.ProvisionedIdentity{
+			Name:     identity.Name,
+			Provider: identity.ProviderName,
+			Mapped:   identity.User.Name == user.Name && identity.User.UID == user.UID && slices.Contains(user.Identities, identity.Name),
+		})
+	}
+	return r.updateProvisionedResources(ctx, userAcc, func(resources *useraccountstatus.ProvisionedResources) {
+		resources.User = &useraccountstatus.ProvisionedUser{
+			Name: user.Name,
+			UID:  user.UID,
+		}
+		resources.Identities = identities
+	})
+}
+
+// recordConsoleSettingsCleanup records the cleanup of the console user settings in the status of the UserAccount,
+// once its User was deleted. The resources which were deleted during a previous cleanup are kept in the record.
+func (r *Reconciler) recordConsoleSettingsCleanup(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount, deleted []string) error {
+	return r.updateProvisionedResources(ctx, userAcc, func(resources *useraccountstatus.ProvisionedResources) {
+		// the Identities are deleted before the User
+		resources.User = nil
+		resources.Identities = nil
+		if resources.ConsoleSettings == nil {
+			resources.ConsoleSettings = &useraccountstatus.ConsoleSettings{}
+		}
+		resources.ConsoleSettings.CleanedUp = true
+		for _, d := range deleted {
+			if !slices.Contains(resources.ConsoleSettings.Deleted, d) {
+				resources.ConsoleSettings.Deleted = append(resources.ConsoleSettings.Deleted, d)
+			}
+		}
+	})
+}
+
+// updateProvisionedResources applies the given changes to the provisioned resources stored in the status of the UserAccount,
+// and updates the status unless the record is unchanged
+func (r *Reconciler) updateProvisionedResources(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount, update func(*useraccountstatus.ProvisionedResources)) error {
+	resources, _, err := useraccountstatus.Get(userAcc)
+	if err != nil {
+		// the condition is maintained by the controller only, so an invalid value is replaced
+		log.FromContext(ctx).Error(err, "invalid provisioned resources condition, replacing it")
+	}
+	update(&resources)
+	cond, err := useraccountstatus.NewCondition(resources)
+	if err != nil {
+		return err
+	}
+	return r.updateStatusConditions(ctx, userAcc, cond)
+}
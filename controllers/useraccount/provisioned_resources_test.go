@@ -0,0 +1,230 @@
+package useraccount
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/useraccountstatus"
+	. "github.com/codeready-toolchain/member-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	userv1 "github.com/openshift/api/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestProvisionedResources(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	username := "johnsmith"
+	userUID := types.UID("johnsmith-uid")
+	user := func() *userv1.User {
+		return &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   username,
+				UID:    userUID,
+				Labels: map[string]string{toolchainv1alpha1.OwnerLabelKey: username, toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue},
+			},
+		}
+	}
+
+	t.Run("user and identities recorded when provisioned", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, req, fakeClient, _ := prepareReconcile(t, username, userAcc, user())
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+			HasProvisionedUser(username, userUID).
+			HasProvisionedIdentities(
+				useraccountstatus.ProvisionedIdentity{Name: "rhd:sub-123", Provider: "rhd", Mapped: true},
+				useraccountstatus.ProvisionedIdentity{Name: "rhd:123456", Provider: "rhd", Mapped: true})
+
+		t.Run("unchanged when reconciling again", func(t *testing.T) {
+			// given
+			before := &toolchainv1alpha1.UserAccount{}
+			require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, before))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			after := &toolchainv1alpha1.UserAccount{}
+			require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, after))
+			assert.Equal(t, before.ResourceVersion, after.ResourceVersion)
+		})
+
+		t.Run("console settings cleanup recorded when disabled", func(t *testing.T) {
+			// given
+			settings := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "user-settings-" + string(userUID),
+					Namespace: UserSettingNS,
+					Labels: map[string]string{
+						ConsoleUserSettingsIdentifier: "true",
+						ConsoleUserSettingsUID:        string(userUID),
+					},
+				},
+			}
+			require.NoError(t, fakeClient.Create(context.TODO(), settings))
+			require.NoError(t, fakeClient.Get(context.TODO(), req.NamespacedName, userAcc))
+			userAcc.Spec.Disabled = true
+			require.NoError(t, fakeClient.Update(context.TODO(), userAcc))
+
+			// when
+			for i := 0; i < 5; i++ {
+				_, err := r.Reconcile(context.TODO(), req)
+				require.NoError(t, err)
+			}
+
+			// then
+			AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+				HasNoProvisionedUser().
+				HasProvisionedIdentities().
+				HasConsoleSettingsCleanedUp("ConfigMap/user-settings-" + string(userUID))
+			assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisabledReason, ""))
+		})
+	})
+
+	t.Run("identity not mapped to the user", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, _, fakeClient, _ := prepareReconcile(t, username, userAcc)
+		identity := &userv1.Identity{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "rhd:sub-123",
+				Labels: map[string]string{toolchainv1alpha1.OwnerLabelKey: username},
+			},
+			ProviderName: "rhd",
+			User:         corev1.ObjectReference{Name: username, UID: "other-uid"},
+		}
+		require.NoError(t, fakeClient.Create(context.TODO(), identity))
+
+		// when
+		err := r.recordProvisionedResources(context.TODO(), userAcc, user())
+
+		// then
+		require.NoError(t, err)
+		AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+			HasProvisionedUser(username, userUID).
+			HasProvisionedIdentities(useraccountstatus.ProvisionedIdentity{Name: "rhd:sub-123", Provider: "rhd", Mapped: false})
+	})
+
+	t.Run("identity not listed by the user", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, _, fakeClient, _ := prepareReconcile(t, username, userAcc)
+		identity := &userv1.Identity{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "rhd:sub-123",
+				Labels: map[string]string{toolchainv1alpha1.OwnerLabelKey: username},
+			},
+			ProviderName: "rhd",
+			User:         corev1.ObjectReference{Name: username, UID: userUID},
+		}
+		require.NoError(t, fakeClient.Create(context.TODO(), identity))
+		u := user()
+		u.Identities = []string{"rhd:other"}
+
+		// when
+		err := r.recordProvisionedResources(context.TODO(), userAcc, u)
+
+		// then
+		require.NoError(t, err)
+		AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+			HasProvisionedIdentities(useraccountstatus.ProvisionedIdentity{Name: "rhd:sub-123", Provider: "rhd", Mapped: false})
+	})
+
+	t.Run("console settings cleanup merged into the recorded resources", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		userAcc.Status.Conditions = []toolchainv1alpha1.Condition{{
+			Type:    useraccountstatus.ProvisionedResourcesCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  useraccountstatus.ProvisionedResourcesRecordedReason,
+			Message: `{"consoleSettings":{"cleanedUp":true,"deleted":["ConfigMap/user-settings-previous"]}}`,
+		}}
+		r, _, fakeClient, _ := prepareReconcile(t, username, userAcc)
+
+		// when
+		err := r.recordConsoleSettingsCleanup(context.TODO(), userAcc, []string{"ConfigMap/user-settings-previous", "Role/user-settings-johnsmith-uid-role"})
+
+		// then
+		require.NoError(t, err)
+		AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+			HasNoProvisionedUser().
+			HasConsoleSettingsCleanedUp("ConfigMap/user-settings-previous", "Role/user-settings-johnsmith-uid-role")
+
+		t.Run("user recorded without losing the console settings cleanup", func(t *testing.T) {
+			// when
+			err := r.recordProvisionedResources(context.TODO(), userAcc, user())
+
+			// then
+			require.NoError(t, err)
+			AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+				HasProvisionedUser(username, userUID).
+				HasConsoleSettingsCleanedUp("ConfigMap/user-settings-previous", "Role/user-settings-johnsmith-uid-role")
+		})
+	})
+
+	t.Run("status updated only when changed", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, _, fakeClient, _ := prepareReconcile(t, username, userAcc)
+		fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			return fmt.Errorf("unexpected update")
+		}
+		updated := 0
+		fakeClient.MockStatusUpdate = func(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			updated++
+			return fakeClient.Client.Status().Update(ctx, obj, opts...)
+		}
+
+		// when
+		err := r.recordConsoleSettingsCleanup(context.TODO(), userAcc, []string{"ConfigMap/user-settings-johnsmith-uid"})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, updated)
+		AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+			HasConsoleSettingsCleanedUp("ConfigMap/user-settings-johnsmith-uid")
+
+		t.Run("not updated again when unchanged", func(t *testing.T) {
+			// when
+			err := r.recordConsoleSettingsCleanup(context.TODO(), userAcc, []string{"ConfigMap/user-settings-johnsmith-uid"})
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 1, updated)
+		})
+	})
+
+	t.Run("nothing recorded when user creation is skipped", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t, testconfig.SkipUserCreation(true))
+		r, req, fakeClient, _ := prepareReconcile(t, username, userAcc, cfg)
+
+		// when
+		for i := 0; i < 5; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+		}
+
+		// then
+		AssertThatUserAccount(t, test.MemberOperatorNs, username, fakeClient).
+			HasNoProvisionedResources()
+	})
+}
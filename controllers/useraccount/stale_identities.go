@@ -0,0 +1,73 @@
+package useraccount
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	userv1 "github.com/openshift/api/user/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// StaleIdentitiesDeletedCondition is the type of the condition of the UserAccount which records the deletion of its stale
+	// identities, ie, the identities owned by the UserAccount which are not expected anymore (eg, after the `originalSub` claim was cleared,
+	// or after an identity provider was removed from the configuration).
+	// The condition is `True` when stale identities were just deleted, and it is reset to `False` (keeping the message about the last
+	// deletion) once the UserAccount has no stale identities anymore.
+	StaleIdentitiesDeletedCondition toolchainv1alpha1.ConditionType = "StaleIdentitiesDeleted"
+	// StaleIdentitiesDeletedReason is the reason of the StaleIdentitiesDeleted condition when stale identities were just deleted
+	StaleIdentitiesDeletedReason = "StaleIdentitiesDeleted"
+	// NoStaleIdentitiesReason is the reason of the StaleIdentitiesDeleted condition once there is no stale identity anymore
+	NoStaleIdentitiesReason = "NoStaleIdentities"
+)
+
+// deleteStaleIdentities deletes the identities which are owned by the given UserAccount (and provided by the toolchain) but which are not
+// expected for the given identity providers (see expectedIdentities), and records their deletion in the status of the UserAccount.
+// Returns `true` if some identities were deleted.
+func (r *Reconciler) deleteStaleIdentities(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount, idps []identityProvider) (bool, error) {
+	identityList := &userv1.IdentityList{}
+	if err := r.Client.List(ctx, identityList, listByOwnerLabel(userAcc.Name)); err != nil {
+		return false, r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusIdentityCreationFailed, err, "failed to list the identities")
+	}
+	expected := expectedIdentityNames(userAcc, idps)
+	var deleted []string
+	for i := range identityList.Items {
+		identity := &identityList.Items[i]
+		if identity.Labels[toolchainv1alpha1.ProviderLabelKey] != toolchainv1alpha1.ProviderLabelValue || slices.Contains(expected, identity.Name) {
+			continue
+		}
+		log.FromContext(ctx).Info("deleting stale identity", "identity", identity.Name, "user", identity.User.Name)
+		if err := r.Client.Delete(ctx, identity); err != nil {
+			return false, r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusIdentityCreationFailed, err, "failed to delete stale identity '%s'", identity.Name)
+		}
+		deleted = append(deleted, identity.Name)
+	}
+	if len(deleted) == 0 {
+		if cond, found := condition.FindConditionByType(userAcc.Status.Conditions, StaleIdentitiesDeletedCondition); found && cond.Status == corev1.ConditionTrue {
+			return false, r.updateStatusConditions(ctx, userAcc, toolchainv1alpha1.Condition{
+				Type:    StaleIdentitiesDeletedCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  NoStaleIdentitiesReason,
+				Message: cond.Message,
+			})
+		}
+		return false, nil
+	}
+	return true, r.updateStatusConditions(ctx, userAcc,
+		toolchainv1alpha1.Condition{
+			Type:   toolchainv1alpha1.ConditionReady,
+			Status: corev1.ConditionFalse,
+			Reason: toolchainv1alpha1.UserAccountProvisioningReason,
+		},
+		toolchainv1alpha1.Condition{
+			Type:    StaleIdentitiesDeletedCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  StaleIdentitiesDeletedReason,
+			Message: fmt.Sprintf("deleted stale identities: %s", strings.Join(deleted, ", ")),
+		})
+}
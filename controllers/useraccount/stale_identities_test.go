@@ -0,0 +1,196 @@
+package useraccount
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	userv1 "github.com/openshift/api/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDeleteStaleIdentities(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	username := "johnsmith"
+
+	// the user and identities of a UserAccount which had the `originalSub` claim and a `userID` claim different from the `sub` claim
+	preexisting := func(userAcc *toolchainv1alpha1.UserAccount) []client.Object {
+		user := &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   username,
+				UID:    types.UID(username + "user"),
+				Labels: map[string]string{toolchainv1alpha1.OwnerLabelKey: username, toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue},
+			},
+			Identities: []string{"rhd:sub-123", "rhd:original-sub", "rhd:123456"},
+		}
+		objs := []client.Object{userAcc, user}
+		for _, name := range user.Identities {
+			objs = append(objs, &userv1.Identity{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   name,
+					Labels: map[string]string{toolchainv1alpha1.OwnerLabelKey: username, toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue},
+				},
+				ProviderName:     "rhd",
+				ProviderUserName: strings.TrimPrefix(name, "rhd:"),
+				User:             corev1.ObjectReference{Name: user.Name, UID: user.UID},
+			})
+		}
+		return objs
+	}
+
+	t.Run("identity of the cleared originalSub claim", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, req, fakeClient, _ := prepareReconcile(t, username, preexisting(userAcc)...)
+
+		// when
+		for i := 0; i < 10 && fakeClient.Get(context.TODO(), types.NamespacedName{Name: "rhd:original-sub"}, &userv1.Identity{}) == nil; i++ {
+			_, err := r.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+		}
+
+		// then
+		assertIdentityDeleted(t, fakeClient, "rhd:original-sub")
+		assertConditions(t, fakeClient, userAcc, provisioning(), staleIdentitiesDeleted("rhd:original-sub"))
+
+		t.Run("condition reset once there is no stale identity anymore", func(t *testing.T) {
+			// when
+			reconcileUntilProvisioned(t, r, req)
+
+			// then
+			user := assertUser(t, r, userAcc)
+			checkMapping(t, user, assertIdentityForUserID(t, r, userAcc, "sub-123", "rhd"), assertIdentityForUserID(t, r, userAcc, "123456", "rhd"))
+			assertConditions(t, fakeClient, userAcc, provisioned(), noStaleIdentities("rhd:original-sub"))
+		})
+	})
+
+	t.Run("identity of the userID claim which is now the same as the sub claim", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		userAcc.Spec.PropagatedClaims.OriginalSub = "original-sub"
+		userAcc.Spec.PropagatedClaims.UserID = "sub-123"
+		r, req, fakeClient, _ := prepareReconcile(t, username, preexisting(userAcc)...)
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		assertIdentityDeleted(t, fakeClient, "rhd:123456")
+		user := assertUser(t, r, userAcc)
+		checkMapping(t, user, assertIdentityForUserID(t, r, userAcc, "sub-123", "rhd"), assertIdentityForUserID(t, r, userAcc, "original-sub", "rhd"))
+		assertConditions(t, fakeClient, userAcc, provisioned(), noStaleIdentities("rhd:123456"))
+	})
+
+	t.Run("several stale identities at once", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		userAcc.Spec.PropagatedClaims.UserID = "sub-123"
+		r, req, fakeClient, _ := prepareReconcile(t, username, preexisting(userAcc)...)
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		assertIdentityDeleted(t, fakeClient, "rhd:original-sub")
+		assertIdentityDeleted(t, fakeClient, "rhd:123456")
+		assertConditions(t, fakeClient, userAcc, provisioned(), noStaleIdentities("rhd:123456", "rhd:original-sub"))
+	})
+
+	t.Run("identities not provided by the toolchain are not deleted", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		objs := preexisting(userAcc)
+		for _, obj := range objs {
+			if identity, ok := obj.(*userv1.Identity); ok {
+				delete(identity.Labels, toolchainv1alpha1.ProviderLabelKey)
+			}
+		}
+		r, req, fakeClient, _ := prepareReconcile(t, username, objs...)
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "rhd:original-sub"}, &userv1.Identity{}))
+		assertConditions(t, fakeClient, userAcc, provisioned())
+	})
+
+	t.Run("identities of a removed identity provider", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123")
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t, testconfig.Auth().Idp("sso"))
+		cfg.Annotations = map[string]string{
+			IdentityProvidersAnnotationKey: `[{"name":"sso","claims":["sub"]},{"name":"github","claims":["userID"]}]`,
+		}
+		r, req, fakeClient, _ := prepareReconcile(t, username, cfg, userAcc)
+		reconcileUntilProvisioned(t, r, req)
+		ssoIdentity := assertIdentityForUserID(t, r, userAcc, "sub-123", "sso")
+		assertIdentityForUserID(t, r, userAcc, "123456", "github")
+		cfg.Annotations[IdentityProvidersAnnotationKey] = `[{"name":"sso","claims":["sub"]}]`
+		require.NoError(t, fakeClient.Update(context.TODO(), cfg))
+		_, err := membercfg.ForceLoadConfiguration(fakeClient)
+		require.NoError(t, err)
+
+		// when
+		reconcileUntilProvisioned(t, r, req)
+
+		// then
+		user := assertUser(t, r, userAcc)
+		checkMapping(t, user, ssoIdentity)
+		assertIdentityDeleted(t, fakeClient, "github:123456")
+		assertConditions(t, fakeClient, userAcc, provisioned(), noStaleIdentities("github:123456"))
+	})
+
+	t.Run("failed to delete a stale identity", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer())
+		r, req, fakeClient, _ := prepareReconcile(t, username, preexisting(userAcc)...)
+		fakeClient.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*userv1.Identity); ok {
+				return apierrors.NewInternalError(assert.AnError)
+			}
+			return fakeClient.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		var err error
+		for i := 0; i < 5 && err == nil; i++ {
+			_, err = r.Reconcile(context.TODO(), req)
+		}
+
+		// then
+		require.ErrorContains(t, err, "failed to delete stale identity 'rhd:original-sub'")
+		assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountUnableToCreateIdentityReason, apierrors.NewInternalError(assert.AnError).Error()))
+	})
+}
+
+func staleIdentitiesDeleted(identities ...string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    StaleIdentitiesDeletedCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  StaleIdentitiesDeletedReason,
+		Message: "deleted stale identities: " + strings.Join(identities, ", "),
+	}
+}
+
+func noStaleIdentities(lastDeleted ...string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    StaleIdentitiesDeletedCondition,
+		Status:  corev1.ConditionFalse,
+		Reason:  NoStaleIdentitiesReason,
+		Message: "deleted stale identities: " + strings.Join(lastDeleted, ", "),
+	}
+}
@@ -2,11 +2,10 @@ package useraccount
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"time"
 
-	rbac "k8s.io/api/rbac/v1"
-
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	commoncontroller "github.com/codeready-toolchain/toolchain-common/controllers"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
@@ -37,7 +36,8 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	mapToOwnerByLabel := handler.EnqueueRequestsFromMapFunc(commoncontroller.MapToOwnerByLabel("", toolchainv1alpha1.OwnerLabelKey))
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&toolchainv1alpha1.UserAccount{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// the annotations of the UserAccount are watched too, since they declare the groups of the user
+		For(&toolchainv1alpha1.UserAccount{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		// TODO remove NSTemplateSet watch once appstudio workarounds are removed
 		// UserAccount does not contain NSTemplateSet details in its Spec anymore but this controller must still watch NSTemplateSet due to appstudio cases
 		// See https://github.com/codeready-toolchain/member-operator/blob/147dbe58f4923b9d936a21995be8b0c084544c6d/controllers/useraccount/useraccount_controller.go#L167-L172
@@ -50,15 +50,19 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 // Reconciler reconciles a UserAccount object
 type Reconciler struct {
 	Client client.Client
-	Scheme *runtime.Scheme
+	// APIReader is an uncached reader, used to list the console user settings resources (which are not in the watched namespace)
+	// without starting an informer for each of their kinds in all the namespaces
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
 }
 
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=useraccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=useraccounts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=useraccounts/finalizers,verbs=update
 
-//+kubebuilder:rbac:groups=user.openshift.io,resources=identities;users;useridentitymappings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=user.openshift.io,resources=identities;users;useridentitymappings;groups,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;update;patch;create;delete
+//+kubebuilder:rbac:groups=oauth.openshift.io,resources=oauthaccesstokens;oauthauthorizetokens,verbs=get;list;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;delete;deletecollection
 
 // Reconcile reads that state of the cluster for a UserAccount object and makes changes based on the state read
@@ -117,19 +121,16 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		}
 	} else {
 		logger.Info("Disabling UserAccount")
+		// the OAuth tokens were already deleted if the UserAccount was disabled before
+		alreadyDisabled := isDisabled(userAcc)
 		if err := r.setStatusDisabling(ctx, userAcc, "deleting user/identity"); err != nil {
 			logger.Error(err, "error updating status")
 			return reconcile.Result{}, err
 		}
-		deleted, err := r.deleteIdentityAndUser(ctx, userAcc)
-		if err != nil {
+		if _, err := r.deleteIdentityAndUser(ctx, userAcc, !alreadyDisabled); err != nil {
 			return reconcile.Result{}, r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusDisabling, err, "failed to delete user/identity")
 		}
-
-		if !deleted {
-			return reconcile.Result{}, r.setStatusDisabled(ctx, userAcc)
-		}
-		return reconcile.Result{}, nil
+		return reconcile.Result{}, r.setStatusDisabled(ctx, userAcc)
 	}
 
 	// check what the current ready condition is set to
@@ -151,16 +152,28 @@ func (r *Reconciler) ensureUserAndIdentity(ctx context.Context, userAcc *toolcha
 	var err error
 	// create User & Identity resources unless configured otherwise, SkipUserCreation will be set mainly for early appstudio development clusters
 	if !config.SkipUserCreation() {
-		if user, createdOrUpdated, err = r.ensureUser(ctx, config, userAcc); err != nil || createdOrUpdated {
+		idps, err := getIdentityProviders(config)
+		if err != nil {
+			return false, r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusIdentityCreationFailed, err, "failed to get the identity providers")
+		}
+		if user, createdOrUpdated, err = r.ensureUser(ctx, idps, userAcc); err != nil || createdOrUpdated {
+			return createdOrUpdated, err
+		}
+		if createdOrUpdated, err = r.ensureIdentity(ctx, idps, userAcc, user); err != nil || createdOrUpdated {
 			return createdOrUpdated, err
 		}
-		_, createdOrUpdated, err = r.ensureIdentity(ctx, config, userAcc, user)
-		return createdOrUpdated, err
+		if deleted, err := r.deleteStaleIdentities(ctx, userAcc, idps); err != nil || deleted {
+			return deleted, err
+		}
+		if err := r.recordProvisionedResources(ctx, userAcc, user); err != nil {
+			return false, errs.Wrap(err, "failed to record the provisioned resources")
+		}
+		return false, r.ensureGroupMemberships(ctx, userAcc)
 	}
 	// we don't expect User nor Identity resources to be present for AppStudio tier
 	// This can be removed as soon as we don't create UserAccounts in AppStudio environment.
 	// Should also remove the NSTemplateSet watch once this is removed.
-	deleted, err := r.deleteIdentityAndUser(ctx, userAcc)
+	deleted, err := r.deleteIdentityAndUser(ctx, userAcc, true)
 	if err != nil {
 		return deleted, r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusUserCreationFailed, err, "failed to delete redundant user or identity")
 	}
@@ -183,17 +196,9 @@ func (r *Reconciler) ensureUserAccountDeletion(ctx context.Context, userAcc *too
 		// In turn, the MUR controller may decide to recreate the UserAccount resource on the
 		// member cluster.
 
-		deleted, err := r.deleteIdentityAndUser(ctx, userAcc)
-		if err != nil {
+		if _, err := r.deleteIdentityAndUser(ctx, userAcc, !isDisabled(userAcc)); err != nil {
 			return r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusTerminating, err, "failed to delete user/identity")
 		}
-		if deleted {
-			if err := r.setStatusTerminating(ctx, userAcc, "deleting user/identity"); err != nil {
-				logger.Error(err, "error updating status")
-				return err
-			}
-			return nil
-		}
 
 		// Remove finalizer from UserAccount
 		util.RemoveFinalizer(userAcc, toolchainv1alpha1.FinalizerName)
@@ -207,7 +212,7 @@ func (r *Reconciler) ensureUserAccountDeletion(ctx context.Context, userAcc *too
 	return nil
 }
 
-func (r *Reconciler) ensureUser(ctx context.Context, config membercfg.Configuration, userAcc *toolchainv1alpha1.UserAccount) (*userv1.User, bool, error) {
+func (r *Reconciler) ensureUser(ctx context.Context, idps []identityProvider, userAcc *toolchainv1alpha1.UserAccount) (*userv1.User, bool, error) {
 	user := &userv1.User{}
 	logger := log.FromContext(ctx)
 	if err := r.Client.Get(ctx, types.NamespacedName{Name: userAcc.Name}, user); err != nil {
@@ -216,7 +221,7 @@ func (r *Reconciler) ensureUser(ctx context.Context, config membercfg.Configurat
 			if err := r.setStatusProvisioning(ctx, userAcc); err != nil {
 				return nil, false, err
 			}
-			user = newUser(userAcc, config)
+			user = newUser(userAcc, idps)
 			setLabelsAndAnnotations(user, userAcc, true)
 			if err := r.Client.Create(ctx, user); err != nil {
 				return nil, false, r.wrapErrorWithStatusUpdate(ctx, userAcc, r.setStatusUserCreationFailed, err, "failed to create user '%s'", userAcc.Name)
@@ -237,19 +242,8 @@ func (r *Reconciler) ensureUser(ctx context.Context, config membercfg.Configurat
 		logger.Error(err, "Unable to update labels to add provider")
 	}
 
-	// ensure mapping
-	expectedIdentities := []string{commonidentity.NewIdentityNamingStandard(userAcc.Spec.PropagatedClaims.Sub, config.Auth().Idp()).IdentityName()}
-
-	// If the OriginalSub property has been set also, then an additional identity is required to be created
-	if userAcc.Spec.PropagatedClaims.OriginalSub != "" {
-		expectedIdentities = append(expectedIdentities, commonidentity.NewIdentityNamingStandard(userAcc.Spec.PropagatedClaims.OriginalSub, config.Auth().Idp()).IdentityName())
-	}
-
-	// Also if the UserID property is set, then an additional identity is required if it is a different value to the Sub
-	if userAcc.Spec.PropagatedClaims.UserID != "" && userAcc.Spec.PropagatedClaims.UserID != userAcc.Spec.PropagatedClaims.Sub {
-		expectedIdentities = append(expectedIdentities, commonidentity.NewIdentityNamingStandard(
-			userAcc.Spec.PropagatedClaims.UserID, config.Auth().Idp()).IdentityName())
-	}
+	// ensure mapping (with the identities of all the identity providers, see expectedIdentities)
+	expectedIdentities := expectedIdentityNames(userAcc, idps)
 
 	stringSlicesEqual := func(a, b []string) bool {
 		if len(a) != len(b) {
@@ -282,40 +276,22 @@ func (r *Reconciler) ensureUser(ctx context.Context, config membercfg.Configurat
 	return user, false, nil
 }
 
-func (r *Reconciler) ensureIdentity(ctx context.Context, config membercfg.Configuration, userAcc *toolchainv1alpha1.UserAccount, user *userv1.User) (*userv1.Identity, bool, error) {
-	identity, createdOrUpdated, err := r.loadIdentityAndEnsureMapping(ctx, config, userAcc.Spec.PropagatedClaims.Sub, userAcc, user)
-	if createdOrUpdated || err != nil {
-		return nil, createdOrUpdated, err
-	}
-
-	// Check if the OriginalSub property is set, and if it is create additional identity/s as required
-	if userAcc.Spec.PropagatedClaims.OriginalSub != "" {
-		// Encoded the OriginalSub as an unpadded Base64 value
-		_, createdOrUpdated, err := r.loadIdentityAndEnsureMapping(ctx, config, userAcc.Spec.PropagatedClaims.OriginalSub, userAcc, user)
-		if createdOrUpdated || err != nil {
-			return nil, createdOrUpdated, err
-		}
-	}
-
-	// Check if the userID property is set, and if it is create an additional identity if it is a different value.
-	// So we always have an identity with the name generated out of SSO UserID (stored in the userID property) in
-	// addition to the identity with the name generated out of the SSO Token sub claim (stored as UserAccount.Spec.PropagatedClaims.Sub).
-	// This additional Identity is not created if the SSO UserID == SSO Token sub claim.
-	if userAcc.Spec.PropagatedClaims.UserID != "" && userAcc.Spec.PropagatedClaims.UserID != userAcc.Spec.PropagatedClaims.Sub {
-		_, createdOrUpdated, err := r.loadIdentityAndEnsureMapping(ctx, config, userAcc.Spec.PropagatedClaims.UserID, userAcc, user)
-		if createdOrUpdated || err != nil {
-			return nil, createdOrUpdated, err
+// ensureIdentity ensures that the identities of the user exist for all the identity providers (see expectedIdentities) and are mapped to the user.
+// For example, with the default identity provider, there is always an identity with the name generated out of the SSO Token sub claim
+// (stored as UserAccount.Spec.PropagatedClaims.Sub), along with an identity for the OriginalSub claim (used to migrate the users to a new
+// IdP provider client) and an identity with the name generated out of SSO UserID (if it is a different value than the sub claim).
+func (r *Reconciler) ensureIdentity(ctx context.Context, idps []identityProvider, userAcc *toolchainv1alpha1.UserAccount, user *userv1.User) (bool, error) {
+	for _, ins := range expectedIdentities(userAcc, idps) {
+		if _, createdOrUpdated, err := r.loadIdentityAndEnsureMapping(ctx, ins, userAcc, user); createdOrUpdated || err != nil {
+			return createdOrUpdated, err
 		}
 	}
-
-	return identity, false, nil
+	return false, nil
 }
 
-func (r *Reconciler) loadIdentityAndEnsureMapping(ctx context.Context, config membercfg.Configuration, username string,
+func (r *Reconciler) loadIdentityAndEnsureMapping(ctx context.Context, ins commonidentity.NamingStandard,
 	userAccount *toolchainv1alpha1.UserAccount, user *userv1.User) (*userv1.Identity, bool, error) {
 
-	ins := commonidentity.NewIdentityNamingStandard(username, config.Auth().Idp())
-
 	identity := &userv1.Identity{}
 
 	logger := log.FromContext(ctx)
@@ -326,7 +302,7 @@ func (r *Reconciler) loadIdentityAndEnsureMapping(ctx context.Context, config me
 				return nil, false, err
 			}
 			identity = newIdentity(user)
-			commonidentity.NewIdentityNamingStandard(username, config.Auth().Idp()).ApplyToIdentity(identity)
+			ins.ApplyToIdentity(identity)
 
 			setLabelsAndAnnotations(identity, userAccount, false)
 			if err := r.Client.Create(ctx, identity); err != nil {
@@ -447,16 +423,35 @@ func (r *Reconciler) addFinalizer(ctx context.Context, userAcc *toolchainv1alpha
 	return nil
 }
 
-// deleteIdentityAndUser deletes the identity and user.
-// Returns bool and error indicating that whether the user/identity were deleted.
-func (r *Reconciler) deleteIdentityAndUser(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount) (bool, error) {
-	if deleted, err := r.deleteIdentity(ctx, userAcc); err != nil || deleted {
-		return deleted, err
+// deleteIdentityAndUser deletes all the identities, then the user, then removes the user from its groups and deletes
+// its OAuth tokens (unless `deleteTokens` is false, eg, when they were already deleted), all in a single pass.
+// Returns `true` if some identities or the user were deleted, so that the caller can update the status accordingly.
+func (r *Reconciler) deleteIdentityAndUser(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount, deleteTokens bool) (bool, error) {
+	deletedIdentities, err := r.deleteIdentities(ctx, userAcc)
+	if err != nil {
+		return deletedIdentities, err
 	}
-	if deleted, err := r.deleteUser(ctx, userAcc); err != nil || deleted {
+	deletedUser, err := r.deleteUser(ctx, userAcc)
+	deleted := deletedIdentities || deletedUser
+	if err != nil {
 		return deleted, err
 	}
-	return false, nil
+	if err := r.removeGroupMemberships(ctx, userAcc.Name); err != nil {
+		return deleted, errs.Wrap(err, "failed to remove the user from its groups")
+	}
+	if !deleteTokens {
+		return deleted, nil
+	}
+	if err := r.deleteOAuthTokens(ctx, userAcc.Name); err != nil {
+		return deleted, errs.Wrap(err, "failed to delete the OAuth tokens of the user")
+	}
+	return deleted, nil
+}
+
+// isDisabled returns `true` if the given UserAccount is disabled, ie, if its user, identities and OAuth tokens were deleted
+func isDisabled(userAcc *toolchainv1alpha1.UserAccount) bool {
+	readyCond, found := condition.FindConditionByType(userAcc.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	return found && readyCond.Reason == toolchainv1alpha1.UserAccountDisabledReason
 }
 
 // deleteUser deletes the user resources associated with the specified UserAccount.
@@ -480,42 +475,25 @@ func (r *Reconciler) deleteUser(ctx context.Context, userAcc *toolchainv1alpha1.
 	// TO DO: this is a workaround for breaking change introduced by console with upgrade to 4.15. This remove resources created for users logging in from OIDC which don't have owner references starting from OCP 4.15 version.
 	uid := userList[0].UID
 	logger.Info(fmt.Sprintf("checking for user settings resources for the user with UID [%s] to be deleted", uid))
-	if err := r.deleteUserResources(ctx, string(uid)); err != nil {
+	deletedSettings, err := r.deleteUserResources(ctx, string(uid))
+	if err != nil {
 		return false, err
 	}
 	// Delete User associated with UserAccount
 	if err := r.Client.Delete(ctx, &userList[0]); err != nil {
 		return false, err
 	}
-	// Return here, as deleting the user should cause another reconcile of the UserAccount
+	if err := r.recordConsoleSettingsCleanup(ctx, userAcc, deletedSettings); err != nil {
+		return false, errs.Wrap(err, "failed to record the cleanup of the console user settings")
+	}
 	logger.Info(fmt.Sprintf("deleted User resource [%s]", userList[0].Name))
 	return true, nil
 }
 
-// deleteUserResources deletes the user settings resources (configmap, role and role-binding) associated with the specified user, created for a user by Openshift Web Console.
-// This function only looks for these resources in the namespace - openshift-console-user-settings
-// Returns an error if any of the deletion operations fail.
-func (r *Reconciler) deleteUserResources(ctx context.Context, userUID string) error {
-
-	// Users which were created in the cluster with the OCP versions which includes https://issues.redhat.com/browse/OCPBUGS-32321 fix
-	// will have a label which will help to map the User to the User settings resources.
-
-	// Users created before that won't have that label, and we have to rely on the name of the resource being of type `user-settings-<UserUID>`,
-	// delete ConfigMap, Role and RoleBinding
-
-	if err := deleteResource(ctx, r.Client, userUID, &corev1.ConfigMap{}); err != nil {
-		return err
-	}
-	if err := deleteResource(ctx, r.Client, userUID, &rbac.Role{TypeMeta: metav1.TypeMeta{Kind: "Role"}}); err != nil {
-		return err
-	}
-	return deleteResource(ctx, r.Client, userUID, &rbac.RoleBinding{TypeMeta: metav1.TypeMeta{Kind: "RoleBinding"}})
-}
-
-// deleteIdentity deletes the Identity resources owned by the specified UserAccount.
-// Returns `true` if one or more identities were deleted, `false` otherwise, with the underlying error
-// if the identity existed and something wrong happened.
-func (r *Reconciler) deleteIdentity(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount) (bool, error) {
+// deleteIdentities deletes all the Identity resources owned by the specified UserAccount.
+// Returns `true` if one or more identities were deleted, `false` otherwise, with the (aggregated) errors
+// of the identities which existed but could not be deleted.
+func (r *Reconciler) deleteIdentities(ctx context.Context, userAcc *toolchainv1alpha1.UserAccount) (bool, error) {
 	identityList := &userv1.IdentityList{}
 	err := r.Client.List(ctx, identityList, listByOwnerLabel(userAcc.Name))
 	if err != nil {
@@ -529,13 +507,20 @@ func (r *Reconciler) deleteIdentity(ctx context.Context, userAcc *toolchainv1alp
 	logger := log.FromContext(ctx)
 	logger.Info("deleting Identity resources")
 
-	// Delete first Identity in the list associated with UserAccount
-	if err := r.Client.Delete(ctx, &identityList.Items[0]); err != nil {
-		return false, err
+	var deleted bool
+	var deletionErrors []error
+	for i := range identityList.Items {
+		identity := &identityList.Items[i]
+		if err := r.Client.Delete(ctx, identity); err != nil {
+			if !errors.IsNotFound(err) {
+				deletionErrors = append(deletionErrors, errs.Wrapf(err, "failed to delete identity '%s'", identity.Name))
+			}
+			continue
+		}
+		deleted = true
+		logger.Info(fmt.Sprintf("deleted Identity resource [%s]", identity.Name))
 	}
-	// Return here, as deleting the identity should cause another reconcile of the UserAccount
-	logger.Info(fmt.Sprintf("deleted Identity resource [%s]", identityList.Items[0].Name))
-	return true, nil
+	return deleted, stderrors.Join(deletionErrors...)
 }
 
 // wrapErrorWithStatusUpdate wraps the error and update the user account status. If the update failed then logs the error.
@@ -653,20 +638,12 @@ func (r *Reconciler) updateStatusConditions(ctx context.Context, userAcc *toolch
 	return r.Client.Status().Update(ctx, userAcc)
 }
 
-func newUser(userAcc *toolchainv1alpha1.UserAccount, config membercfg.Configuration) *userv1.User {
-	identities := []string{commonidentity.NewIdentityNamingStandard(userAcc.Spec.PropagatedClaims.Sub, config.Auth().Idp()).IdentityName()}
-	if userAcc.Spec.PropagatedClaims.OriginalSub != "" {
-		identities = append(identities, commonidentity.NewIdentityNamingStandard(userAcc.Spec.PropagatedClaims.OriginalSub, config.Auth().Idp()).IdentityName())
-	}
-	if userAcc.Spec.PropagatedClaims.UserID != "" && userAcc.Spec.PropagatedClaims.UserID != userAcc.Spec.PropagatedClaims.Sub {
-		identities = append(identities, commonidentity.NewIdentityNamingStandard(userAcc.Spec.PropagatedClaims.UserID, config.Auth().Idp()).IdentityName())
-	}
-
+func newUser(userAcc *toolchainv1alpha1.UserAccount, idps []identityProvider) *userv1.User {
 	user := &userv1.User{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: userAcc.Name,
 		},
-		Identities: identities,
+		Identities: expectedIdentityNames(userAcc, idps),
 	}
 
 	return user
@@ -7,12 +7,16 @@ import (
 	"fmt"
 	rbac "k8s.io/api/rbac/v1"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/member-operator/pkg/apis"
+	"github.com/codeready-toolchain/member-operator/pkg/useraccountstatus"
 	. "github.com/codeready-toolchain/member-operator/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
 	identity2 "github.com/codeready-toolchain/toolchain-common/pkg/identity"
@@ -402,8 +406,7 @@ func TestReconcile(t *testing.T) {
 			//then
 			require.NoError(t, err)
 			assert.Equal(t, reconcile.Result{}, res)
-			useraccount.AssertThatUserAccount(t, userAcc.Name, cl).
-				HasConditions(provisioned())
+			assertConditions(t, cl, userAcc, provisioned())
 		})
 	})
 
@@ -482,7 +485,7 @@ func TestReconcile(t *testing.T) {
 		userAcc := newUserAccount(username, userID, withFinalizer())
 		r, req, cl, _ := prepareReconcile(t, username, cfg, userAcc, preexistingUser, preexistingIdentity, configMap, role, rb, noiseRole, noiseRb, noiseCm)
 
-		t.Run("first reconcile deletes identity", func(t *testing.T) {
+		t.Run("reconcile deletes identity and user and removes the finalizer", func(t *testing.T) {
 			// given
 			// Set the deletionTimestamp by calling delete
 			err = r.Client.Delete(context.TODO(), userAcc)
@@ -495,58 +498,30 @@ func TestReconcile(t *testing.T) {
 			assert.Equal(t, reconcile.Result{}, res)
 			require.NoError(t, err)
 
-			useraccount.AssertThatUserAccount(t, req.Name, cl).
-				HasConditions(notReady("Terminating", "deleting user/identity"))
-
 			// Check that the associated identity has been deleted
 			// when reconciling the useraccount with a deletion timestamp
 			assertIdentityNotFound(t, r, userAcc, config.Auth().Idp())
-			useraccount.AssertThatUserAccount(t, userAcc.Name, cl).HasConditions(terminating())
-
-			t.Run("second reconcile deletes user", func(t *testing.T) {
-				// when
-				res, err = r.Reconcile(context.TODO(), req)
-
-				// then
-				assert.Equal(t, reconcile.Result{}, res)
-				require.NoError(t, err)
 
-				useraccount.AssertThatUserAccount(t, req.Name, cl).
-					HasConditions(notReady("Terminating", "deleting user/identity"))
-
-				// Check that the associated console settings resources have been deleted
-				for _, obj := range []client.Object{&corev1.ConfigMap{}, &rbac.RoleBinding{}} {
-					AssertObjectNotFound(t, cl, UserSettingNS, resourceName, obj)
-				}
-				AssertObjectNotFound(t, cl, UserSettingNS, resourceName+"random", &rbac.Role{})
-
-				// Check that the noise resources are not deleted
-				for key, obj := range []client.Object{&corev1.ConfigMap{}, &rbac.Role{}, &rbac.RoleBinding{}} {
-					AssertObject(t, cl, UserSettingNS, noiseResourceName, obj, func() {
-						assert.Equal(t, noiseObjects[key], obj)
-					})
-				}
-
-				// Check that the associated user has been deleted
-				// when reconciling the useraccount with a deletion timestamp
-				assertUserNotFound(t, r, userAcc)
-				useraccount.AssertThatUserAccount(t, userAcc.Name, cl).HasConditions(terminating())
+			// Check that the associated console settings resources have been deleted
+			for _, obj := range []client.Object{&corev1.ConfigMap{}, &rbac.RoleBinding{}} {
+				AssertObjectNotFound(t, cl, UserSettingNS, resourceName, obj)
+			}
+			AssertObjectNotFound(t, cl, UserSettingNS, resourceName+"random", &rbac.Role{})
 
-				t.Run("third reconcile removes finalizer and triggers the deletion", func(t *testing.T) {
-					// when
-					res, err = r.Reconcile(context.TODO(), req)
+			// Check that the noise resources are not deleted
+			for key, obj := range []client.Object{&corev1.ConfigMap{}, &rbac.Role{}, &rbac.RoleBinding{}} {
+				AssertObject(t, cl, UserSettingNS, noiseResourceName, obj, func() {
+					assert.Equal(t, noiseObjects[key], obj)
+				})
+			}
 
-					// then
-					assert.Equal(t, reconcile.Result{}, res)
-					require.NoError(t, err)
+			// Check that the associated user has been deleted in the same reconcile
+			assertUserNotFound(t, r, userAcc)
 
-					// Check that the user account has been removed since the finalizer was deleted
-					// when reconciling the useraccount with a deletion timestamp
-					useraccount.AssertThatUserAccount(t, username, r.Client).
-						DoesNotExist()
-					require.Equal(t, 0, *mockCallsCounter)
-				})
-			})
+			// Check that the user account has been removed since the finalizer was deleted in the same reconcile
+			useraccount.AssertThatUserAccount(t, username, r.Client).
+				DoesNotExist()
+			require.Equal(t, 0, *mockCallsCounter)
 		})
 	})
 
@@ -573,7 +548,7 @@ func TestReconcile(t *testing.T) {
 				HasConditions(provisioned())
 		})
 
-		t.Run("user & identity are there - it should remove identity as it has the owner label set", func(t *testing.T) {
+		t.Run("user & identity are there - it should remove both as they have the owner label set", func(t *testing.T) {
 			// given
 			r, req, cl, _ := prepareReconcile(t, username, cfg, appStudioAccount, preexistingUser, preexistingIdentity)
 
@@ -584,10 +559,9 @@ func TestReconcile(t *testing.T) {
 			require.NoError(t, err)
 
 			assertIdentityNotFound(t, r, userAcc, config.Auth().Idp())
-			assertUser(t, r, userAcc)
+			assertUserNotFound(t, r, userAcc)
 
-			useraccount.AssertThatUserAccount(t, req.Name, cl).
-				HasConditions(provisioning())
+			assertConditions(t, cl, userAcc, provisioning())
 		})
 
 		t.Run("user is there - it should remove the user and not identity as it doesn't have owner label set", func(t *testing.T) {
@@ -607,8 +581,7 @@ func TestReconcile(t *testing.T) {
 			require.NoError(t, err)
 			assertUserNotFound(t, r, userAcc)
 
-			useraccount.AssertThatUserAccount(t, req.Name, cl).
-				HasConditions(provisioning())
+			assertConditions(t, cl, userAcc, provisioning())
 		})
 
 		t.Run("user & identity are there, but none of them should be removed - they don't have owner label set", func(t *testing.T) {
@@ -700,61 +673,31 @@ func TestReconcile(t *testing.T) {
 		err = r.Client.Delete(context.TODO(), userAcc)
 		require.NoError(t, err)
 
-		// trigger the deletion of the first `Identity` resource
-		t.Run("first reconcile with Deletion timestamp: deleting the first Identity resource", func(t *testing.T) {
-			res, err = r.Reconcile(context.TODO(), req)
-			require.NoError(t, err)
-			assert.Equal(t, reconcile.Result{}, res)
-			useraccount.AssertThatUserAccount(t, req.Name, fakeClient).
-				HasConditions(notReady("Terminating", "deleting user/identity"))
-
-			// trigger the deletion of the second `Identity` resource
-			t.Run("second reconcile with Deletion timestamp: deleting the second Identity resource", func(t *testing.T) {
-				res, err = r.Reconcile(context.TODO(), req)
-				require.NoError(t, err)
-				assert.Equal(t, reconcile.Result{}, res)
-				useraccount.AssertThatUserAccount(t, req.Name, fakeClient).
-					HasConditions(notReady("Terminating", "deleting user/identity"))
-
-				// trigger the deletion of the `User` resource
-				t.Run("third reconcile with Deletion timestamp: deleting the User resource", func(t *testing.T) {
-					res, err = r.Reconcile(context.TODO(), req)
-					require.NoError(t, err)
-					assert.Equal(t, reconcile.Result{}, res)
-					useraccount.AssertThatUserAccount(t, req.Name, fakeClient).
-						HasConditions(notReady("Terminating", "deleting user/identity"))
-
-					t.Run("forth reconcile with Deletion timestamp: attempt to delete the UserAccount", func(t *testing.T) {
-						// Mock finalizer removal failure
-						fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
-							if userAcc, ok := obj.(*toolchainv1alpha1.UserAccount); ok {
-								userAcc.Finalizers = []string{toolchainv1alpha1.FinalizerName} // restore finalizers
-								return fmt.Errorf("unable to remove finalizer for user account %s", userAcc.Name)
-							}
-							return fakeClient.Client.Update(ctx, obj, opts...)
-						}
-						res, err = r.Reconcile(context.TODO(), req)
-						assert.Equal(t, reconcile.Result{}, res)
-						require.EqualError(t, err, fmt.Sprintf("failed to remove finalizer: unable to remove finalizer for user account %s", userAcc.Name))
+		// Mock finalizer removal failure
+		fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			if userAcc, ok := obj.(*toolchainv1alpha1.UserAccount); ok {
+				userAcc.Finalizers = []string{toolchainv1alpha1.FinalizerName} // restore finalizers
+				return fmt.Errorf("unable to remove finalizer for user account %s", userAcc.Name)
+			}
+			return fakeClient.Client.Update(ctx, obj, opts...)
+		}
 
-						useraccount.AssertThatUserAccount(t, req.Name, fakeClient).
-							HasConditions(notReady("Terminating", fmt.Sprintf("unable to remove finalizer for user account %s", userAcc.Name)))
+		// when
+		res, err = r.Reconcile(context.TODO(), req)
 
-						// Check that the associated identity has been deleted
-						// when reconciling the useraccount with a deletion timestamp
-						assertIdentityNotFound(t, r, userAcc, config.Auth().Idp())
+		// then
+		assert.Equal(t, reconcile.Result{}, res)
+		require.EqualError(t, err, fmt.Sprintf("failed to remove finalizer: unable to remove finalizer for user account %s", userAcc.Name))
+		assertConditions(t, fakeClient, userAcc, notReady("Terminating", fmt.Sprintf("unable to remove finalizer for user account %s", userAcc.Name)))
 
-						// Check that the associated user has been deleted
-						// when reconciling the useraccount with a deletion timestamp
-						assertUserNotFound(t, r, userAcc)
+		// Check that all the `Identity` resources and the `User` resource were deleted in the same reconcile
+		identities := &userv1.IdentityList{}
+		require.NoError(t, fakeClient.List(context.TODO(), identities, listByOwnerLabel(username)))
+		assert.Empty(t, identities.Items)
+		assertUserNotFound(t, r, userAcc)
 
-						// Check that the user account finalizer has not been removed
-						// when reconciling the useraccount with a deletion timestamp
-						useraccount.AssertThatUserAccount(t, username, r.Client).HasFinalizer(toolchainv1alpha1.FinalizerName)
-					})
-				})
-			})
-		})
+		// Check that the user account finalizer has not been removed
+		useraccount.AssertThatUserAccount(t, username, r.Client).HasFinalizer(toolchainv1alpha1.FinalizerName)
 	})
 
 	// delete identity fails
@@ -784,14 +727,20 @@ func TestReconcile(t *testing.T) {
 
 		res, err = r.Reconcile(context.TODO(), req)
 		assert.Equal(t, reconcile.Result{}, res)
-		require.EqualError(t, err, fmt.Sprintf("failed to delete user/identity: unable to delete identity for user account %s", userAcc.Name))
+		// the errors of all the identities are aggregated
+		require.ErrorContains(t, err, "failed to delete user/identity: ")
+		var identityErrs []string
+		for _, sub := range []string{"123456", userID} {
+			identityErr := fmt.Sprintf("failed to delete identity '%s': unable to delete identity for user account %s", ToIdentityName(sub, config.Auth().Idp()), userAcc.Name)
+			require.ErrorContains(t, err, identityErr)
+			identityErrs = append(identityErrs, identityErr)
+		}
 
 		// Check that the associated identity has not been deleted
 		// when reconciling the useraccount with a deletion timestamp
 		assertIdentity(t, r, userAcc, config.Auth().Idp())
 
-		useraccount.AssertThatUserAccount(t, req.Name, fakeClient).
-			HasConditions(notReady("Terminating", fmt.Sprintf("unable to delete identity for user account %s", userAcc.Name)))
+		assertTerminatingWithErrors(t, fakeClient, userAcc, identityErrs...)
 	})
 
 	// delete identity fails when list identity call returns error
@@ -906,10 +855,16 @@ func TestReconcile(t *testing.T) {
 
 		res, err = r.Reconcile(context.TODO(), req)
 		assert.Equal(t, reconcile.Result{}, res)
-		require.EqualError(t, err, fmt.Sprintf("failed to delete user/identity: unable to delete user/identity for user account %s", userAcc.Name))
+		// the errors of all the identities are aggregated
+		require.ErrorContains(t, err, "failed to delete user/identity: ")
+		var identityErrs []string
+		for _, sub := range []string{"123456", userID} {
+			identityErr := fmt.Sprintf("failed to delete identity '%s': unable to delete user/identity for user account %s", ToIdentityName(sub, config.Auth().Idp()), userAcc.Name)
+			require.ErrorContains(t, err, identityErr)
+			identityErrs = append(identityErrs, identityErr)
+		}
 
-		useraccount.AssertThatUserAccount(t, req.Name, fakeClient).
-			HasConditions(notReady("Terminating", fmt.Sprintf("unable to delete user/identity for user account %s", userAcc.Name)))
+		assertTerminatingWithErrors(t, fakeClient, userAcc, identityErrs...)
 
 		// Check that the associated identity has not been deleted
 		// when reconciling the useraccount with a deletion timestamp
@@ -1441,23 +1396,11 @@ func TestDisabledUserAccount(t *testing.T) {
 		require.NoError(t, err)
 
 		//then
-		assertIdentityNotFound(t, r, userAcc, config.Auth().Idp())
-		useraccount.AssertThatUserAccount(t, req.Name, cl).
-			HasConditions(notReady("Disabling", "deleting user/identity"))
-
-		res, err = r.Reconcile(context.TODO(), req)
-		assert.Equal(t, reconcile.Result{}, res)
-		require.NoError(t, err)
-
-		useraccount.AssertThatUserAccount(t, req.Name, cl).
-			HasConditions(notReady("Disabling", "deleting user/identity"))
+		assertConditions(t, cl, userAcc, notReady("Disabled", ""))
 
-		// Check that the associated identity has been deleted
+		// Check that the associated identity and user have been deleted in the same reconcile
 		// since disabled has been set to true
 		assertIdentityNotFound(t, r, userAcc, config.Auth().Idp())
-
-		// Check that the associated user has been deleted
-		// since disabled has been set to true
 		assertUserNotFound(t, r, userAcc)
 	})
 
@@ -1520,8 +1463,7 @@ func TestDisabledUserAccount(t *testing.T) {
 		require.NoError(t, err)
 
 		// then
-		useraccount.AssertThatUserAccount(t, req.Name, cl).
-			HasConditions(notReady("Disabling", "deleting user/identity"))
+		assertConditions(t, cl, userAcc, notReady("Disabled", ""))
 
 		// Check that the associated identity has been deleted
 		// since disabled has been set to true
@@ -1542,8 +1484,7 @@ func TestDisabledUserAccount(t *testing.T) {
 		assert.Equal(t, reconcile.Result{}, res)
 		require.NoError(t, err)
 
-		useraccount.AssertThatUserAccount(t, req.Name, cl).
-			HasConditions(notReady("Disabling", "deleting user/identity"))
+		assertConditions(t, cl, userAcc, notReady("Disabled", ""))
 
 		// Check that the associated identity has been deleted
 		// since disabled has been set to true
@@ -1566,30 +1507,10 @@ func TestDisabledUserAccount(t *testing.T) {
 		// then
 		require.NoError(t, err)
 
-		// Check that the associated identity has been deleted
-		// since disabled has been set to true
+		// Check that the associated identity and user have been deleted and the finalizer removed in the same reconcile
 		assertIdentityNotFound(t, r, userAcc, config.Auth().Idp())
-
-		t.Run("deleting user for disabled useraccount", func(t *testing.T) {
-			// when
-			_, err := r.Reconcile(context.TODO(), req)
-
-			// then
-			require.NoError(t, err)
-
-			// Check that the associated user has been deleted
-			// since disabled has been set to true
-			assertUserNotFound(t, r, userAcc)
-
-			t.Run("remove finalizer and thus delete for disabled useraccount", func(t *testing.T) {
-				// when
-				_, err := r.Reconcile(context.TODO(), req)
-
-				// then
-				require.NoError(t, err)
-				useraccount.AssertThatUserAccount(t, username, r.Client).DoesNotExist()
-			})
-		})
+		assertUserNotFound(t, r, userAcc)
+		useraccount.AssertThatUserAccount(t, username, r.Client).DoesNotExist()
 	})
 }
 
@@ -1707,12 +1628,14 @@ func prepareReconcile(t *testing.T, username string, initObjs ...client.Object)
 	err := apis.AddToScheme(s)
 	require.NoError(t, err)
 	fakeClient := test.NewFakeClient(t, initObjs...)
+	fakeClient.MockList = listOAuthTokensByUserName(fakeClient)
 	config, err := membercfg.GetConfiguration(fakeClient)
 	require.NoError(t, err)
 
 	r := &Reconciler{
-		Client: fakeClient,
-		Scheme: s,
+		Client:    fakeClient,
+		APIReader: fakeClient,
+		Scheme:    s,
 	}
 	return r, newReconcileRequest(username), fakeClient, config
 }
@@ -1742,11 +1665,99 @@ func provisioning() toolchainv1alpha1.Condition {
 	}
 }
 
-func terminating() toolchainv1alpha1.Condition {
-	return toolchainv1alpha1.Condition{
-		Type:    toolchainv1alpha1.ConditionReady,
-		Status:  corev1.ConditionFalse,
-		Reason:  toolchainv1alpha1.UserAccountTerminatingReason,
-		Message: "deleting user/identity",
+func assertIdentityDeleted(t *testing.T, cl client.Client, name string) {
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: name}, &userv1.Identity{})
+	require.Error(t, err)
+	assert.True(t, apierros.IsNotFound(err), "identity %s", name)
+}
+
+func assertConditions(t *testing.T, cl client.Client, userAcc *toolchainv1alpha1.UserAccount, expected ...toolchainv1alpha1.Condition) {
+	actual := &toolchainv1alpha1.UserAccount{}
+	require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(userAcc), actual))
+	// the provisioned resources are asserted on their own (see AssertThatUserAccount)
+	conditions := slices.DeleteFunc(actual.Status.Conditions, func(c toolchainv1alpha1.Condition) bool {
+		return c.Type == useraccountstatus.ProvisionedResourcesCondition
+	})
+	test.AssertConditionsMatch(t, conditions, expected...)
+}
+
+// assertTerminatingWithErrors asserts that the UserAccount is terminating, with all the given (aggregated) errors in the message of its Ready condition
+func assertTerminatingWithErrors(t *testing.T, cl client.Client, userAcc *toolchainv1alpha1.UserAccount, errs ...string) {
+	actual := useraccount.AssertThatUserAccount(t, userAcc.Name, cl).Get()
+	ready, found := condition.FindConditionByType(actual.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	require.True(t, found)
+	assert.Equal(t, corev1.ConditionFalse, ready.Status)
+	assert.Equal(t, toolchainv1alpha1.UserAccountTerminatingReason, ready.Reason)
+	assert.ElementsMatch(t, errs, strings.Split(ready.Message, "\n"))
+}
+
+func TestDeleteIdentitiesAndUserInOnePass(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+	username := "johnsmith"
+	preexisting := func() []client.Object {
+		labels := map[string]string{toolchainv1alpha1.OwnerLabelKey: username, toolchainv1alpha1.ProviderLabelKey: toolchainv1alpha1.ProviderLabelValue}
+		user := &userv1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   username,
+				UID:    types.UID(username + "user"),
+				Labels: labels,
+			},
+			Identities: []string{"rhd:sub-123", "rhd:original-sub", "rhd:123456"},
+		}
+		objs := []client.Object{user}
+		for _, name := range user.Identities {
+			objs = append(objs, &userv1.Identity{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   name,
+					Labels: labels,
+				},
+				User: corev1.ObjectReference{Name: user.Name, UID: user.UID},
+			})
+		}
+		return objs
 	}
+
+	t.Run("all identities and user deleted in one reconcile when disabled", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer(), disabled())
+		r, req, fakeClient, _ := prepareReconcile(t, username, append(preexisting(), userAcc)...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		for _, name := range []string{"rhd:sub-123", "rhd:original-sub", "rhd:123456"} {
+			assertIdentityDeleted(t, fakeClient, name)
+		}
+		assertUserNotFound(t, r, userAcc)
+		// disabled in the same reconcile
+		assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisabledReason, ""))
+	})
+
+	t.Run("other identities deleted and errors aggregated when some identity deletion fails", func(t *testing.T) {
+		// given
+		userAcc := newUserAccount(username, "sub-123", withFinalizer(), disabled())
+		r, req, fakeClient, _ := prepareReconcile(t, username, append(preexisting(), userAcc)...)
+		fakeClient.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+			if obj.GetName() == "rhd:original-sub" {
+				return errors.New("mock error")
+			}
+			return fakeClient.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.EqualError(t, err, "failed to delete user/identity: failed to delete identity 'rhd:original-sub': mock error")
+		assertIdentityDeleted(t, fakeClient, "rhd:sub-123")
+		assertIdentityDeleted(t, fakeClient, "rhd:123456")
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "rhd:original-sub"}, &userv1.Identity{}))
+		// the user is only deleted once all its identities are gone
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: username}, &userv1.User{}))
+		assertConditions(t, fakeClient, userAcc, notReady(toolchainv1alpha1.UserAccountDisablingReason, "failed to delete identity 'rhd:original-sub': mock error"))
+	})
 }
@@ -23,6 +23,11 @@ $(OUT_DIR)/operator:
 		-o $(OUT_DIR)/bin/member-operator-webhook \
 		cmd/webhook/main.go
 
+.PHONY: tier-render
+## Build the CLI which renders a TierTemplate or TierTemplateRevision locally (for the template authors)
+tier-render:
+	$(Q)go build ${V_FLAG} -o $(OUT_DIR)/bin/tier-render ./cmd/tier-render
+
 .PHONY: vendor
 vendor:
 	$(Q)go mod vendor
@@ -7,6 +7,7 @@ import (
 
 	openshiftappsv1 "github.com/openshift/api/apps/v1"
 	authv1 "github.com/openshift/api/authorization/v1"
+	oauthv1 "github.com/openshift/api/oauth/v1"
 	projectv1 "github.com/openshift/api/project/v1"
 	quotav1 "github.com/openshift/api/quota/v1"
 	routev1 "github.com/openshift/api/route/v1"
@@ -30,6 +31,7 @@ func AddToScheme(s *runtime.Scheme) error {
 		templatev1.Install,
 		projectv1.Install,
 		authv1.Install,
+		oauthv1.Install,
 		quotav1.Install,
 		extensionsv1.AddToScheme,
 		rbacv1.AddToScheme,
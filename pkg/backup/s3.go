@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3RequestTimeout is the maximum duration of an upload or a download, so that a slow or unresponsive
+// endpoint does not block the reconcile loop forever
+const s3RequestTimeout = 2 * time.Minute
+
+// S3Config contains the settings of an S3-compatible bucket
+type S3Config struct {
+	// Endpoint is the URL of the S3-compatible service, eg `https://s3.us-east-1.amazonaws.com`
+	Endpoint string
+	// Bucket is the name of the bucket (addressed with the path-style)
+	Bucket string
+	// Region is the region of the bucket, defaults to `us-east-1`
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// NewS3Store returns a Store which uploads the archives in an S3-compatible bucket.
+// The given transport is used to send the requests, or the default transport if it is nil.
+func NewS3Store(config S3Config, transport http.RoundTripper) (Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("the endpoint, bucket, access key ID and secret access key of the S3 bucket must be set")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint of the S3 bucket '%s'", config.Endpoint)
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+		Secure:       endpoint.Scheme != "http",
+		Region:       config.Region,
+		Transport:    transport,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the client of the S3 bucket: %w", err)
+	}
+	return &s3Store{
+		config: config,
+		client: client,
+	}, nil
+}
+
+type s3Store struct {
+	config S3Config
+	client *minio.Client
+}
+
+func (s *s3Store) Store(ctx context.Context, key string, archive []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	location := s.location(key)
+	if _, err := s.client.PutObject(ctx, s.config.Bucket, strings.TrimPrefix(key, "/"), bytes.NewReader(archive), int64(len(archive)), minio.PutObjectOptions{
+		ContentType: "application/gzip",
+	}); err != nil {
+		return "", fmt.Errorf("unable to upload the backup archive to '%s': %w", location, err)
+	}
+	return location, nil
+}
+
+func (s *s3Store) Load(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	location := s.location(key)
+	object, err := s.client.GetObject(ctx, s.config.Bucket, strings.TrimPrefix(key, "/"), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to download the backup archive from '%s': %w", location, err)
+	}
+	defer object.Close()
+	archive, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download the backup archive from '%s': %w", location, err)
+	}
+	return archive, nil
+}
+
+func (s *s3Store) location(key string) string {
+	return strings.TrimSuffix(s.config.Endpoint, "/") + "/" + s.config.Bucket + "/" + strings.TrimPrefix(key, "/")
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Store(t *testing.T) {
+	config := S3Config{
+		Bucket:          "backups",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+	}
+
+	t.Run("upload succeeds", func(t *testing.T) {
+		// given
+		var method, path, authorization string
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path, authorization = r.Method, r.URL.Path, r.Header.Get("Authorization")
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer server.Close()
+		config.Endpoint = server.URL
+		store, err := NewS3Store(config, server.Client().Transport)
+		require.NoError(t, err)
+
+		// when
+		location, err := store.Store(context.TODO(), "johnsmith/johnsmith-dev-20250101T000000Z.tar.gz", []byte("archive"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, server.URL+"/backups/johnsmith/johnsmith-dev-20250101T000000Z.tar.gz", location)
+		assert.Equal(t, http.MethodPut, method)
+		assert.Equal(t, "/backups/johnsmith/johnsmith-dev-20250101T000000Z.tar.gz", path)
+		assert.Contains(t, authorization, "AWS4-HMAC-SHA256 Credential=access/")
+		assert.Contains(t, authorization, "/us-east-1/s3/aws4_request")
+		assert.Contains(t, string(body), "archive") // streamed in signed chunks over plain HTTP
+	})
+
+	t.Run("upload fails", func(t *testing.T) {
+		// given
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("AccessDenied"))
+		}))
+		defer server.Close()
+		config.Endpoint = server.URL
+		store, err := NewS3Store(config, server.Client().Transport)
+		require.NoError(t, err)
+
+		// when
+		_, err = store.Store(context.TODO(), "johnsmith/johnsmith-dev.tar.gz", []byte("archive"))
+
+		// then
+		require.ErrorContains(t, err, "unable to upload the backup archive to '"+server.URL+"/backups/johnsmith/johnsmith-dev.tar.gz': ")
+		require.ErrorContains(t, err, "Access Denied")
+	})
+
+	t.Run("download succeeds", func(t *testing.T) {
+		// given
+		var method, path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			_, _ = w.Write([]byte("archive"))
+		}))
+		defer server.Close()
+		config.Endpoint = server.URL
+		store, err := NewS3Store(config, server.Client().Transport)
+		require.NoError(t, err)
+
+		// when
+		archive, err := store.Load(context.TODO(), "migrations/johnsmith/bundle.tar.gz")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "archive", string(archive))
+		assert.Equal(t, http.MethodGet, method)
+		assert.Equal(t, "/backups/migrations/johnsmith/bundle.tar.gz", path)
+	})
+
+	t.Run("download fails", func(t *testing.T) {
+		// given
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("NoSuchKey"))
+		}))
+		defer server.Close()
+		config.Endpoint = server.URL
+		store, err := NewS3Store(config, server.Client().Transport)
+		require.NoError(t, err)
+
+		// when
+		_, err = store.Load(context.TODO(), "migrations/johnsmith/bundle.tar.gz")
+
+		// then
+		require.ErrorContains(t, err, "unable to download the backup archive from '"+server.URL+"/backups/migrations/johnsmith/bundle.tar.gz': ")
+		require.ErrorContains(t, err, "does not exist")
+	})
+
+	t.Run("missing settings", func(t *testing.T) {
+		// when
+		_, err := NewS3Store(S3Config{Bucket: "backups"}, nil)
+
+		// then
+		require.EqualError(t, err, "the endpoint, bucket, access key ID and secret access key of the S3 bucket must be set")
+	})
+}
@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store stores the backup archives
+type Store interface {
+	// Store stores the given archive under the given key (eg, `<space>/<namespace>.tar.gz`), replacing the archive
+	// previously stored under the same key (if any), and returns its location, for example a file path or a URL
+	Store(ctx context.Context, key string, archive []byte) (string, error)
+	// Load returns the archive stored under the given key
+	Load(ctx context.Context, key string) ([]byte, error)
+}
+
+// NewDirectoryStore returns a Store which writes the archives in the given directory,
+// typically the mount path of a PersistentVolumeClaim in the operator namespace.
+func NewDirectoryStore(dir string) Store {
+	return &directoryStore{dir: dir}
+}
+
+type directoryStore struct {
+	dir string
+}
+
+func (s *directoryStore) Store(_ context.Context, key string, archive []byte) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", fmt.Errorf("unable to create the backup directory: %w", err)
+	}
+	// write in a temporary file first, so that a partial archive is never left behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, archive, 0o600); err != nil {
+		return "", fmt.Errorf("unable to write the backup archive: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("unable to write the backup archive: %w", err)
+	}
+	return path, nil
+}
+
+func (s *directoryStore) Load(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the backup archive: %w", err)
+	}
+	return archive, nil
+}
+
+func (s *directoryStore) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid backup key '%s'", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
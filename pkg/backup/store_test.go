@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryStore(t *testing.T) {
+	t.Run("archive is written", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		store := NewDirectoryStore(dir)
+
+		// when
+		location, err := store.Store(context.TODO(), "johnsmith/johnsmith-dev-20250101T000000Z.tar.gz", []byte("archive"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "johnsmith", "johnsmith-dev-20250101T000000Z.tar.gz"), location)
+		content, err := os.ReadFile(location)
+		require.NoError(t, err)
+		assert.Equal(t, "archive", string(content))
+	})
+
+	t.Run("archive is loaded", func(t *testing.T) {
+		// given
+		store := NewDirectoryStore(t.TempDir())
+		_, err := store.Store(context.TODO(), "johnsmith/bundle.tar.gz", []byte("archive"))
+		require.NoError(t, err)
+
+		// when
+		archive, err := store.Load(context.TODO(), "johnsmith/bundle.tar.gz")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "archive", string(archive))
+	})
+
+	t.Run("missing archive", func(t *testing.T) {
+		// given
+		store := NewDirectoryStore(t.TempDir())
+
+		// when
+		_, err := store.Load(context.TODO(), "johnsmith/bundle.tar.gz")
+
+		// then
+		require.ErrorContains(t, err, "unable to read the backup archive")
+	})
+
+	t.Run("key outside of the directory", func(t *testing.T) {
+		// given
+		store := NewDirectoryStore(t.TempDir())
+
+		// when
+		_, err := store.Store(context.TODO(), "../johnsmith.tar.gz", []byte("archive"))
+
+		// then
+		require.EqualError(t, err, "invalid backup key '../johnsmith.tar.gz'")
+	})
+}
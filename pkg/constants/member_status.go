@@ -0,0 +1,4 @@
+package constants
+
+// MemberStatusName is the name of the MemberStatus resource in the namespace of the member operator
+const MemberStatusName = "toolchain-member-status"
@@ -8,6 +8,7 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	runtimecluster "sigs.k8s.io/controller-runtime/pkg/cluster"
@@ -21,9 +22,13 @@ type CachedHostClientInit struct {
 	scheme                  *runtime.Scheme
 	cachedHostClusterClient *NamespacedClient
 	getHostCluster          cluster.GetHostClusterFunc
-	initCachedClient        func(ctx context.Context, scheme *runtime.Scheme, cachedHostCluster *cluster.CachedToolchainCluster, hostNamespace string) (client.Client, error)
+	initCachedClient        func(ctx context.Context, scheme *runtime.Scheme, cachedHostCluster *cluster.CachedToolchainCluster, hostNamespace string, onTemplateChange TemplateChangeHandler) (client.Client, error)
+	onTemplateChange        TemplateChangeHandler
 }
 
+// TemplateChangeHandler is called with the name of a TierTemplate or TierTemplateRevision which was updated or deleted on the host cluster
+type TemplateChangeHandler func(name string)
+
 func NewCachedHostClientInitializer(scheme *runtime.Scheme, getHostCluster cluster.GetHostClusterFunc) *CachedHostClientInit {
 	return &CachedHostClientInit{
 		scheme:           scheme,
@@ -32,6 +37,12 @@ func NewCachedHostClientInitializer(scheme *runtime.Scheme, getHostCluster clust
 	}
 }
 
+// OnTemplateChange sets the handler to call when a TierTemplate or TierTemplateRevision is updated or deleted on the host cluster.
+// It must be set before the host client is initialized.
+func (c *CachedHostClientInit) OnTemplateChange(handler TemplateChangeHandler) {
+	c.onTemplateChange = handler
+}
+
 func NewNamespacedClient(client client.Client, namespace string) *NamespacedClient {
 	return &NamespacedClient{Client: client, Namespace: namespace}
 }
@@ -64,7 +75,7 @@ func (c *CachedHostClientInit) init(ctx context.Context) (*NamespacedClient, err
 		return nil, fmt.Errorf("host cluster not found")
 	}
 	hostNamespace := cachedHostCluster.OperatorNamespace
-	cachedClient, err := c.initCachedClient(ctx, c.scheme, cachedHostCluster, hostNamespace)
+	cachedClient, err := c.initCachedClient(ctx, c.scheme, cachedHostCluster, hostNamespace, c.onTemplateChange)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +98,7 @@ func (c *CachedHostClientInit) init(ctx context.Context) (*NamespacedClient, err
 	return c.cachedHostClusterClient, nil
 }
 
-func initCachedClient(ctx context.Context, scheme *runtime.Scheme, cachedHostCluster *cluster.CachedToolchainCluster, hostNamespace string) (client.Client, error) {
+func initCachedClient(ctx context.Context, scheme *runtime.Scheme, cachedHostCluster *cluster.CachedToolchainCluster, hostNamespace string, onTemplateChange TemplateChangeHandler) (client.Client, error) {
 
 	hostCluster, err := runtimecluster.New(cachedHostCluster.RestConfig, func(options *runtimecluster.Options) {
 		options.Scheme = scheme
@@ -106,5 +117,34 @@ func initCachedClient(ctx context.Context, scheme *runtime.Scheme, cachedHostClu
 	if !hostCluster.GetCache().WaitForCacheSync(ctx) {
 		return nil, fmt.Errorf("unable to sync the cache of the client")
 	}
+	if onTemplateChange != nil {
+		for _, obj := range []client.Object{&toolchainv1alpha1.TierTemplate{}, &toolchainv1alpha1.TierTemplateRevision{}} {
+			informer, err := hostCluster.GetCache().GetInformer(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := informer.AddEventHandler(templateChangeEventHandler(onTemplateChange)); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return hostCluster.GetClient(), nil
 }
+
+// templateChangeEventHandler returns the informer event handler which calls the given handler when a template is updated or deleted
+func templateChangeEventHandler(onTemplateChange TemplateChangeHandler) toolscache.ResourceEventHandlerFuncs {
+	notify := func(obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if o, ok := obj.(client.Object); ok {
+			onTemplateChange(o.GetName())
+		}
+	}
+	return toolscache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, newObj interface{}) {
+			notify(newObj)
+		},
+		DeleteFunc: notify,
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"sync"
 	"testing"
 
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/member-operator/pkg/apis"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	testcommon "github.com/codeready-toolchain/toolchain-common/pkg/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
 	"k8s.io/kubectl/pkg/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -23,7 +26,7 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 	require.NoError(t, err)
 	t.Run("success", func(t *testing.T) {
 		initializer := NewCachedHostClientInitializer(s, NewGetHostCluster(true))
-		initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string) (client.Client, error) {
+		initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string, _ TemplateChangeHandler) (client.Client, error) {
 			return testcommon.NewFakeClient(t), nil
 		}
 
@@ -37,6 +40,26 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 		require.Equal(t, testcommon.HostOperatorNs, hostClient.Namespace)
 	})
 
+	t.Run("with template change handler", func(t *testing.T) {
+		// given
+		initializer := NewCachedHostClientInitializer(s, NewGetHostCluster(true))
+		var changed []string
+		initializer.OnTemplateChange(func(name string) {
+			changed = append(changed, name)
+		})
+		initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string, onTemplateChange TemplateChangeHandler) (client.Client, error) {
+			onTemplateChange("base1ns-dev-123456a")
+			return testcommon.NewFakeClient(t), nil
+		}
+
+		// when
+		_, err := initializer.GetHostClient(context.TODO())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"base1ns-dev-123456a"}, changed)
+	})
+
 	t.Run("failure", func(t *testing.T) {
 		t.Run("host cluster not found", func(t *testing.T) {
 			initializer := NewCachedHostClientInitializer(s, NewGetHostCluster(false))
@@ -51,7 +74,7 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 
 		t.Run("init client fails", func(t *testing.T) {
 			initializer := NewCachedHostClientInitializer(s, NewGetHostCluster(true))
-			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string) (client.Client, error) {
+			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string, _ TemplateChangeHandler) (client.Client, error) {
 				return nil, errors.New("some error")
 			}
 
@@ -69,7 +92,7 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
 				return errors.New("some list error")
 			}
-			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string) (client.Client, error) {
+			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string, _ TemplateChangeHandler) (client.Client, error) {
 				return cl, nil
 			}
 
@@ -89,7 +112,7 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 			initializer := NewCachedHostClientInitializer(s, NewGetHostCluster(false))
 			cachedClient := NewNamespacedClient(testcommon.NewFakeClient(t), testcommon.HostOperatorNs)
 			initializer.cachedHostClusterClient = cachedClient
-			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string) (client.Client, error) {
+			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string, _ TemplateChangeHandler) (client.Client, error) {
 				return nil, errors.New("shouldn't be called")
 			}
 
@@ -107,7 +130,7 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 			gate.Add(1)
 			var waitForFinished sync.WaitGroup
 			initializer := NewCachedHostClientInitializer(s, NewGetHostCluster(true))
-			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string) (client.Client, error) {
+			initializer.initCachedClient = func(_ context.Context, _ *runtime.Scheme, _ *cluster.CachedToolchainCluster, _ string, _ TemplateChangeHandler) (client.Client, error) {
 				return testcommon.NewFakeClient(t), nil
 			}
 
@@ -138,6 +161,24 @@ func TestNewCachedHostClientInitializer(t *testing.T) {
 	})
 }
 
+func TestTemplateChangeEventHandler(t *testing.T) {
+	// given
+	var changed []string
+	handler := templateChangeEventHandler(func(name string) {
+		changed = append(changed, name)
+	})
+	ttr := &toolchainv1alpha1.TierTemplateRevision{ObjectMeta: metav1.ObjectMeta{Name: "base1ns-dev-123456a-ttr"}}
+
+	// when
+	handler.OnAdd(ttr, true)
+	handler.OnUpdate(ttr, ttr)
+	handler.OnDelete(ttr)
+	handler.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "host/base1ns-dev-123456a-ttr", Obj: ttr})
+
+	// then
+	assert.Equal(t, []string{"base1ns-dev-123456a-ttr", "base1ns-dev-123456a-ttr", "base1ns-dev-123456a-ttr"}, changed)
+}
+
 func NewGetHostCluster(ok bool) cluster.GetHostClusterFunc {
 	if !ok {
 		return func() (*cluster.CachedToolchainCluster, bool) {
@@ -9,17 +9,53 @@ import (
 
 var log = logf.Log.WithName("toolchain_metrics")
 
+// gauges
+var (
+	// NSTemplateSetsPausedGauge reflects the number of NSTemplateSets whose reconciliation is paused
+	NSTemplateSetsPausedGauge prometheus.Gauge
+)
+
 // gauge with labels
 var (
 	// MemberOperatorVersionGaugeVec reflects the current version of the member-operator (via the `version` label)
 	MemberOperatorVersionGaugeVec *prometheus.GaugeVec
+	// NSTemplateSetsReadyReasonGaugeVec reflects the number of NSTemplateSets per reason of their Ready condition (via the `reason` label)
+	NSTemplateSetsReadyReasonGaugeVec *prometheus.GaugeVec
+)
+
+// counters
+var (
+	// TierTemplateRenderCacheHitsCounter is the number of tier templates whose rendered objects were found in the cache
+	TierTemplateRenderCacheHitsCounter prometheus.Counter
+	// TierTemplateRenderCacheMissesCounter is the number of tier templates which were rendered because they were not found in the cache
+	TierTemplateRenderCacheMissesCounter prometheus.Counter
+)
+
+// counters with labels
+var (
+	// NSTemplateSetFailuresCounterVec is the number of failures of the provisioning, update or deletion of the NSTemplateSets (via the `reason` label)
+	NSTemplateSetFailuresCounterVec *prometheus.CounterVec
+)
+
+// histograms with labels
+var (
+	// NSTemplateSetProvisioningDurationHistogramVec is the time (in seconds) from the creation or the spec change of a NSTemplateSet
+	// to the end of each phase of its provisioning or update (via the `tier`, `operation` and `phase` labels)
+	NSTemplateSetProvisioningDurationHistogramVec *prometheus.HistogramVec
 )
 
 // collections
 var (
-	allGaugeVecs = []*prometheus.GaugeVec{}
+	allGauges        = []prometheus.Gauge{}
+	allGaugeVecs     = []*prometheus.GaugeVec{}
+	allCounters      = []prometheus.Counter{}
+	allCounterVecs   = []*prometheus.CounterVec{}
+	allHistogramVecs = []*prometheus.HistogramVec{}
 )
 
+// provisioningDurationBuckets are the buckets (in seconds) of the provisioning duration histogram
+var provisioningDurationBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600}
+
 func init() {
 	initMetrics()
 }
@@ -29,6 +65,13 @@ const metricsPrefix = "sandbox_"
 func initMetrics() {
 	log.Info("initializing custom metrics")
 	MemberOperatorVersionGaugeVec = newGaugeVec("member_operator_version", "Current version of the member operator", "commit")
+	NSTemplateSetsPausedGauge = newGauge("nstemplatesets_paused", "Number of NSTemplateSets whose reconciliation is paused")
+	NSTemplateSetsReadyReasonGaugeVec = newGaugeVec("nstemplatesets_ready_reason", "Number of NSTemplateSets per reason of their Ready condition", "reason")
+	NSTemplateSetFailuresCounterVec = newCounterVec("nstemplateset_failures_total", "Number of failures of the provisioning, update or deletion of the NSTemplateSets", "reason")
+	NSTemplateSetProvisioningDurationHistogramVec = newHistogramVec("nstemplateset_provisioning_duration_seconds",
+		"Time from the creation or the spec change of a NSTemplateSet to the end of each phase of its provisioning or update", provisioningDurationBuckets, "tier", "operation", "phase")
+	TierTemplateRenderCacheHitsCounter = newCounter("tier_template_render_cache_hits_total", "Number of tier templates whose rendered objects were found in the cache")
+	TierTemplateRenderCacheMissesCounter = newCounter("tier_template_render_cache_misses_total", "Number of tier templates which were rendered because they were not found in the cache")
 	log.Info("custom metrics initialized")
 }
 
@@ -38,6 +81,15 @@ func Reset() {
 	initMetrics()
 }
 
+func newGauge(name, help string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: metricsPrefix + name,
+		Help: help,
+	})
+	allGauges = append(allGauges, g)
+	return g
+}
+
 func newGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
 	v := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: metricsPrefix + name,
@@ -47,12 +99,52 @@ func newGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
 	return v
 }
 
+func newCounter(name, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: metricsPrefix + name,
+		Help: help,
+	})
+	allCounters = append(allCounters, c)
+	return c
+}
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + name,
+		Help: help,
+	}, labels)
+	allCounterVecs = append(allCounterVecs, c)
+	return c
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	v := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricsPrefix + name,
+		Help:    help,
+		Buckets: buckets,
+	}, labels)
+	allHistogramVecs = append(allHistogramVecs, v)
+	return v
+}
+
 // RegisterCustomMetrics registers the custom metrics
 func RegisterCustomMetrics() {
 	// register metrics
+	for _, g := range allGauges {
+		k8smetrics.Registry.MustRegister(g)
+	}
 	for _, v := range allGaugeVecs {
 		k8smetrics.Registry.MustRegister(v)
 	}
+	for _, c := range allCounters {
+		k8smetrics.Registry.MustRegister(c)
+	}
+	for _, c := range allCounterVecs {
+		k8smetrics.Registry.MustRegister(c)
+	}
+	for _, v := range allHistogramVecs {
+		k8smetrics.Registry.MustRegister(v)
+	}
 
 	// expose the MemberOperatorVersionGaugeVec metric (static ie, 1 value per build/deployment)
 	MemberOperatorVersionGaugeVec.WithLabelValues(version.Commit[0:7]).Set(1)
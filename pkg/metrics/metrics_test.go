@@ -8,6 +8,17 @@ import (
 	k8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+func TestInitGauge(t *testing.T) {
+	// given
+	m := newGauge("test_gauge", "test gauge description")
+
+	// when
+	m.Set(2)
+
+	// then
+	assert.InDelta(t, float64(2), promtestutil.ToFloat64(m), 0.01)
+}
+
 func TestInitGaugeVec(t *testing.T) {
 	// given
 	m := newGaugeVec("test_gauge_vec", "test gauge description", "cluster_name")
@@ -21,13 +32,62 @@ func TestInitGaugeVec(t *testing.T) {
 	assert.InDelta(t, float64(2), promtestutil.ToFloat64(m.WithLabelValues("member-2")), 0.01)
 }
 
+func TestInitCounter(t *testing.T) {
+	// given
+	m := newCounter("test_counter", "test counter description")
+
+	// when
+	m.Inc()
+	m.Inc()
+
+	// then
+	assert.InDelta(t, float64(2), promtestutil.ToFloat64(m), 0.01)
+}
+
+func TestInitCounterVec(t *testing.T) {
+	// given
+	m := newCounterVec("test_counter_vec", "test counter vec description", "reason")
+
+	// when
+	m.WithLabelValues("UpdateFailed").Inc()
+	m.WithLabelValues("UpdateFailed").Inc()
+
+	// then
+	assert.InDelta(t, float64(2), promtestutil.ToFloat64(m.WithLabelValues("UpdateFailed")), 0.01)
+	assert.InDelta(t, float64(0), promtestutil.ToFloat64(m.WithLabelValues("UnableToProvision")), 0.01)
+}
+
+func TestInitHistogramVec(t *testing.T) {
+	// given
+	m := newHistogramVec("test_histogram_vec", "test histogram vec description", []float64{1, 10}, "tier")
+
+	// when
+	m.WithLabelValues("base").Observe(0.5)
+	m.WithLabelValues("base").Observe(5)
+
+	// then
+	assert.Equal(t, 1, promtestutil.CollectAndCount(m))
+}
+
 func TestRegisterCustomMetrics(t *testing.T) {
 	// when
 	RegisterCustomMetrics()
 
 	// then
 	// verify all metrics were registered successfully
+	for _, m := range allGauges {
+		assert.True(t, k8smetrics.Registry.Unregister(m))
+	}
 	for _, m := range allGaugeVecs {
 		assert.True(t, k8smetrics.Registry.Unregister(m))
 	}
+	for _, m := range allCounters {
+		assert.True(t, k8smetrics.Registry.Unregister(m))
+	}
+	for _, m := range allCounterVecs {
+		assert.True(t, k8smetrics.Registry.Unregister(m))
+	}
+	for _, m := range allHistogramVecs {
+		assert.True(t, k8smetrics.Registry.Unregister(m))
+	}
 }
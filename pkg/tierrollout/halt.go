@@ -0,0 +1,48 @@
+package tierrollout
+
+import (
+	"encoding/json"
+	"fmt"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HaltedAnnotationKey is the annotation set on the MemberStatus by the NSTemplateSet controller with the (JSON-encoded)
+// Halt when the tier updates of the spaces were halted because too many of them failed.
+// The annotation is removed by the NSTemplateSet controller (and the tier updates which did not start yet are resumed)
+// once the ratio of failures drops below the threshold, eg, when the failed updates were fixed with a new revision of the tier.
+// Removing the annotation manually resumes the rollout as well, and overrides the halt until the ratio of failures drops below the threshold.
+const HaltedAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "tier-rollout-halted"
+
+const (
+	// HaltedConditionType is the type of the condition set on the MemberStatus while the tier rollout is halted.
+	// This condition is informational only: it does not affect the readiness of the MemberStatus, since the existing spaces are healthy.
+	HaltedConditionType toolchainv1alpha1.ConditionType = "TierRolloutHalted"
+	// HaltedReason is the reason of the HaltedConditionType condition
+	HaltedReason = "TierRolloutHalted"
+)
+
+// Halt describes why the tier updates of the spaces on the member cluster were halted
+type Halt struct {
+	// FailedSpaces is the number of NSTemplateSets whose update failed when the rollout was halted
+	FailedSpaces int `json:"failedSpaces"`
+	// TotalSpaces is the number of NSTemplateSets on the member cluster when the rollout was halted
+	TotalSpaces int         `json:"totalSpaces"`
+	HaltTime    metav1.Time `json:"haltTime"`
+}
+
+// GetHalt returns the halt of the tier rollout stored in the annotation of the given MemberStatus,
+// and `false` if the rollout is not halted
+func GetHalt(memberStatus *toolchainv1alpha1.MemberStatus) (Halt, bool, error) {
+	value, found := memberStatus.GetAnnotations()[HaltedAnnotationKey]
+	if !found {
+		return Halt{}, false, nil
+	}
+	halt := Halt{}
+	if err := json.Unmarshal([]byte(value), &halt); err != nil {
+		// the rollout is still halted, even if the details are not readable
+		return Halt{}, true, fmt.Errorf("invalid value of the '%s' annotation: %w", HaltedAnnotationKey, err)
+	}
+	return halt, true, nil
+}
@@ -0,0 +1,54 @@
+package tierrollout
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetHalt(t *testing.T) {
+	withAnnotation := func(value string) *toolchainv1alpha1.MemberStatus {
+		return &toolchainv1alpha1.MemberStatus{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{HaltedAnnotationKey: value},
+			},
+		}
+	}
+
+	t.Run("not halted", func(t *testing.T) {
+		// when
+		halt, halted, err := GetHalt(&toolchainv1alpha1.MemberStatus{})
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, halted)
+		assert.Equal(t, Halt{}, halt)
+	})
+
+	t.Run("halted", func(t *testing.T) {
+		// when
+		halt, halted, err := GetHalt(withAnnotation(`{"failedSpaces":3,"totalSpaces":10,"haltTime":"2024-01-02T03:04:05Z"}`))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, halted)
+		assert.Equal(t, 3, halt.FailedSpaces)
+		assert.Equal(t, 10, halt.TotalSpaces)
+		assert.True(t, halt.HaltTime.Equal(ptr.To(metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))))
+	})
+
+	t.Run("halted with invalid details", func(t *testing.T) {
+		// when
+		halt, halted, err := GetHalt(withAnnotation("true"))
+
+		// then
+		require.ErrorContains(t, err, "invalid value of the '"+HaltedAnnotationKey+"' annotation")
+		assert.True(t, halted)
+		assert.Equal(t, Halt{}, halt)
+	})
+}
@@ -0,0 +1,78 @@
+package useraccountstatus
+
+import (
+	"encoding/json"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ProvisionedResourcesCondition is the type of the condition set in the status of the UserAccount (by the member operator),
+	// whose message is the (JSON-encoded) ProvisionedResources, ie, the User and Identities which were provisioned for the UserAccount,
+	// or the console user settings which were cleaned up when the User was deleted.
+	ProvisionedResourcesCondition toolchainv1alpha1.ConditionType = "ProvisionedResources"
+	// ProvisionedResourcesRecordedReason is the reason of the ProvisionedResources condition
+	ProvisionedResourcesRecordedReason = "Recorded"
+)
+
+// ProvisionedResources contains the details about the resources which were provisioned (or deleted) for a UserAccount
+type ProvisionedResources struct {
+	// User is the provisioned User, if any
+	User *ProvisionedUser `json:"user,omitempty"`
+	// Identities are the provisioned Identities
+	Identities []ProvisionedIdentity `json:"identities,omitempty"`
+	// ConsoleSettings contains the details about the cleanup of the console user settings, once the User was deleted
+	ConsoleSettings *ConsoleSettings `json:"consoleSettings,omitempty"`
+}
+
+// ProvisionedUser is a User provisioned for a UserAccount
+type ProvisionedUser struct {
+	Name string    `json:"name"`
+	UID  types.UID `json:"uid"`
+}
+
+// ProvisionedIdentity is an Identity provisioned for a UserAccount
+type ProvisionedIdentity struct {
+	Name string `json:"name"`
+	// Provider is the name of the identity provider of the Identity
+	Provider string `json:"provider"`
+	// Mapped is `true` if the Identity refers to the provisioned User and the User lists the Identity
+	Mapped bool `json:"mapped"`
+}
+
+// ConsoleSettings contains the details about the cleanup of the console user settings of a deleted User
+type ConsoleSettings struct {
+	CleanedUp bool `json:"cleanedUp"`
+	// Deleted are the kinds and names of the deleted resources, eg, `ConfigMap/user-settings-<UserUID>`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// Get returns the provisioned resources stored in the status of the given UserAccount, and `false` if there is no such condition
+func Get(userAcc *toolchainv1alpha1.UserAccount) (ProvisionedResources, bool, error) {
+	resources := ProvisionedResources{}
+	cond, found := condition.FindConditionByType(userAcc.Status.Conditions, ProvisionedResourcesCondition)
+	if !found {
+		return resources, false, nil
+	}
+	if err := json.Unmarshal([]byte(cond.Message), &resources); err != nil {
+		return ProvisionedResources{}, true, err
+	}
+	return resources, true, nil
+}
+
+// NewCondition returns the ProvisionedResources condition which contains the given resources
+func NewCondition(resources ProvisionedResources) (toolchainv1alpha1.Condition, error) {
+	value, err := json.Marshal(resources)
+	if err != nil {
+		return toolchainv1alpha1.Condition{}, err
+	}
+	return toolchainv1alpha1.Condition{
+		Type:    ProvisionedResourcesCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  ProvisionedResourcesRecordedReason,
+		Message: string(value),
+	}, nil
+}
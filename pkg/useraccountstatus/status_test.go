@@ -0,0 +1,73 @@
+package useraccountstatus
+
+import (
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGet(t *testing.T) {
+	withCondition := func(message string) *toolchainv1alpha1.UserAccount {
+		return &toolchainv1alpha1.UserAccount{
+			Status: toolchainv1alpha1.UserAccountStatus{
+				Conditions: []toolchainv1alpha1.Condition{{Type: ProvisionedResourcesCondition, Status: corev1.ConditionTrue, Message: message}},
+			},
+		}
+	}
+
+	t.Run("no condition", func(t *testing.T) {
+		// when
+		resources, found, err := Get(&toolchainv1alpha1.UserAccount{})
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Equal(t, ProvisionedResources{}, resources)
+	})
+
+	t.Run("valid condition", func(t *testing.T) {
+		// when
+		resources, found, err := Get(withCondition(`{"user":{"name":"johnsmith","uid":"123"},"identities":[{"name":"rhd:sub-123","provider":"rhd","mapped":true}]}`))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, ProvisionedResources{
+			User:       &ProvisionedUser{Name: "johnsmith", UID: "123"},
+			Identities: []ProvisionedIdentity{{Name: "rhd:sub-123", Provider: "rhd", Mapped: true}},
+		}, resources)
+	})
+
+	t.Run("invalid condition", func(t *testing.T) {
+		// when
+		_, found, err := Get(withCondition(`{"user":`))
+
+		// then
+		require.Error(t, err)
+		assert.True(t, found)
+	})
+}
+
+func TestNewCondition(t *testing.T) {
+	// given
+	resources := ProvisionedResources{
+		User:       &ProvisionedUser{Name: "johnsmith", UID: "123"},
+		Identities: []ProvisionedIdentity{{Name: "rhd:sub-123", Provider: "rhd", Mapped: true}},
+	}
+
+	// when
+	cond, err := NewCondition(resources)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ProvisionedResourcesCondition, cond.Type)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, ProvisionedResourcesRecordedReason, cond.Reason)
+	actual, found, err := Get(&toolchainv1alpha1.UserAccount{Status: toolchainv1alpha1.UserAccountStatus{Conditions: []toolchainv1alpha1.Condition{cond}}})
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, resources, actual)
+}
@@ -0,0 +1,16 @@
+package memberconfig
+
+import (
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+)
+
+// Annotation returns the value of the given annotation on the (cached) MemberOperatorConfig, or an empty string if the
+// annotation or the config is missing. Such annotations hold the settings which are not (yet) part of the MemberOperatorConfig spec.
+func Annotation(key string) string {
+	cfg, _ := configuration.GetCachedConfig()
+	if memberCfg, ok := cfg.(*toolchainv1alpha1.MemberOperatorConfig); ok && memberCfg != nil {
+		return memberCfg.GetAnnotations()[key]
+	}
+	return ""
+}
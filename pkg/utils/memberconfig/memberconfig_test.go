@@ -0,0 +1,55 @@
+package memberconfig
+
+import (
+	"testing"
+
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	membercfg "github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotation(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.MemberOperatorNs)
+	t.Cleanup(restore)
+
+	t.Run("annotation set", func(t *testing.T) {
+		// given
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t)
+		cfg.Annotations = map[string]string{"toolchain.dev.openshift.com/foo": "bar"}
+		_, err := membercfg.ForceLoadConfiguration(test.NewFakeClient(t, cfg))
+		require.NoError(t, err)
+
+		// when
+		value := Annotation("toolchain.dev.openshift.com/foo")
+
+		// then
+		assert.Equal(t, "bar", value)
+	})
+
+	t.Run("annotation not set", func(t *testing.T) {
+		// given
+		cfg := commonconfig.NewMemberOperatorConfigWithReset(t)
+		_, err := membercfg.ForceLoadConfiguration(test.NewFakeClient(t, cfg))
+		require.NoError(t, err)
+
+		// when
+		value := Annotation("toolchain.dev.openshift.com/foo")
+
+		// then
+		assert.Empty(t, value)
+	})
+
+	t.Run("no config", func(t *testing.T) {
+		// given
+		commonconfig.ResetCache()
+
+		// when
+		value := Annotation("toolchain.dev.openshift.com/foo")
+
+		// then
+		assert.Empty(t, value)
+	})
+}
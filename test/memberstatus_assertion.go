@@ -56,6 +56,13 @@ func (a *MemberStatusAssertion) HasCondition(expected toolchainv1alpha1.Conditio
 	return a
 }
 
+func (a *MemberStatusAssertion) HasConditions(expected ...toolchainv1alpha1.Condition) *MemberStatusAssertion {
+	err := a.loadMemberStatus()
+	require.NoError(a.t, err)
+	test.AssertConditionsMatch(a.t, a.memberStatus.Status.Conditions, expected...)
+	return a
+}
+
 func (a *MemberStatusAssertion) HasMemberOperatorConditionErrorMsg(expected string) *MemberStatusAssertion {
 	err := a.loadMemberStatus()
 	require.NoError(a.t, err)
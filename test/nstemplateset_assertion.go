@@ -311,3 +311,10 @@ func (a *NSTemplateSetAssertion) DoesNotHaveFinalizer() *NSTemplateSetAssertion
 	assert.Empty(a.t, a.nsTmplSet.Finalizers)
 	return a
 }
+
+func (a *NSTemplateSetAssertion) HasNoAnnotation(key string) *NSTemplateSetAssertion {
+	err := a.loadNSTemplateSet()
+	require.NoError(a.t, err)
+	assert.NotContains(a.t, a.nsTmplSet.Annotations, key)
+	return a
+}
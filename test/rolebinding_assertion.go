@@ -54,3 +54,14 @@ func (a *RoleBindingAssertion) HasLabel(key, value string) *RoleBindingAssertion
 	assert.Equal(a.t, value, a.rolebinding.Labels[key])
 	return a
 }
+
+func (a *RoleBindingAssertion) HasSubject(kind, name string) *RoleBindingAssertion {
+	err := a.loadRoleBinding()
+	require.NoError(a.t, err)
+	assert.Contains(a.t, a.rolebinding.Subjects, rbacv1.Subject{
+		APIGroup: rbacv1.GroupName,
+		Kind:     kind,
+		Name:     name,
+	})
+	return a
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/member-operator/pkg/useraccountstatus"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type UserAccountAssertion struct {
+	userAcc        *toolchainv1alpha1.UserAccount
+	client         client.Client
+	namespacedName types.NamespacedName
+	t              test.T
+}
+
+func (a *UserAccountAssertion) loadUserAccount() error {
+	userAcc := &toolchainv1alpha1.UserAccount{}
+	err := a.client.Get(context.TODO(), a.namespacedName, userAcc)
+	a.userAcc = userAcc
+	return err
+}
+
+func (a *UserAccountAssertion) provisionedResources() useraccountstatus.ProvisionedResources {
+	err := a.loadUserAccount()
+	require.NoError(a.t, err)
+	resources, found, err := useraccountstatus.Get(a.userAcc)
+	require.NoError(a.t, err)
+	require.True(a.t, found, "expected the '%s' condition to be set", useraccountstatus.ProvisionedResourcesCondition)
+	return resources
+}
+
+func AssertThatUserAccount(t test.T, namespace, name string, client client.Client) *UserAccountAssertion {
+	return &UserAccountAssertion{
+		client:         client,
+		namespacedName: test.NamespacedName(namespace, name),
+		t:              t,
+	}
+}
+
+func (a *UserAccountAssertion) HasNoProvisionedResources() *UserAccountAssertion {
+	err := a.loadUserAccount()
+	require.NoError(a.t, err)
+	_, found, err := useraccountstatus.Get(a.userAcc)
+	require.NoError(a.t, err)
+	assert.False(a.t, found, "expected no '%s' condition", useraccountstatus.ProvisionedResourcesCondition)
+	return a
+}
+
+func (a *UserAccountAssertion) HasProvisionedUser(name string, uid types.UID) *UserAccountAssertion {
+	resources := a.provisionedResources()
+	require.NotNil(a.t, resources.User, "expected a provisioned user")
+	assert.Equal(a.t, name, resources.User.Name)
+	assert.Equal(a.t, uid, resources.User.UID)
+	return a
+}
+
+func (a *UserAccountAssertion) HasNoProvisionedUser() *UserAccountAssertion {
+	resources := a.provisionedResources()
+	assert.Nil(a.t, resources.User, "expected no provisioned user")
+	return a
+}
+
+func (a *UserAccountAssertion) HasProvisionedIdentities(expected ...useraccountstatus.ProvisionedIdentity) *UserAccountAssertion {
+	resources := a.provisionedResources()
+	assert.ElementsMatch(a.t, expected, resources.Identities)
+	return a
+}
+
+func (a *UserAccountAssertion) HasConsoleSettingsCleanedUp(deleted ...string) *UserAccountAssertion {
+	resources := a.provisionedResources()
+	require.NotNil(a.t, resources.ConsoleSettings, "expected the console settings cleanup to be recorded")
+	assert.True(a.t, resources.ConsoleSettings.CleanedUp)
+	assert.ElementsMatch(a.t, deleted, resources.ConsoleSettings.Deleted)
+	return a
+}